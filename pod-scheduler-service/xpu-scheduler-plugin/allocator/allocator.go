@@ -1,282 +1,601 @@
 package allocator
 
 import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
 )
 
-type nodeAssignment [][]int
+const (
+	// DefaultMaxIterations default number of nodes and device groups tried by one Allocate call
+	DefaultMaxIterations = 100000
+	// DefaultTimeout default time budget of one Allocate call
+	DefaultTimeout = 200 * time.Millisecond
+	// timeCheckInterval check the deadline once every this number of iterations
+	timeCheckInterval = 64
+)
 
-type nodeAllocation struct {
-	nodeResource NodeResource
-	podRequest   []PodCardRequest
+// podPlacement a pod placed on a node with its devices
+type podPlacement struct {
+	nodeIdx        int
+	deviceIds      []int
+	groups         [][]int
+	invalid        bool
+	intraReward    float64
+	minIntraReward float64
+	interReward    float64
+	interPairs     int
+	typePenalty    float64
 }
 
+// searchState branch-and-bound search of pod placements. Pods are placed one by one, most cards first,
+// each pod on every node and every candidate device group of that node, and branches that can not
+// beat the best placement found so far by the objective function are cut.
+type searchState struct {
+	nodes                []NodeResource
+	podRequests          []PodCardRequest
+	order                []int
+	reqXPUInterBandwidth map[string]map[string]int
+	maxNodeBandwidth     int
+	interPairs           int
+	minNodes             int
+	// uuidFiltered some request accepts devices by uuid, so nodes alike but for the uuids differ
+	uuidFiltered bool
+
+	usedDevices      []map[int]struct{}
+	podsOfNode       []int
+	placements       []podPlacement
+	invalid          int
+	nodesUsed        int
+	intraReward      float64
+	minIntraReward   float64
+	interReward      float64
+	interPairsPlaced int
+	typePenalty      float64
+
+	best     []podPlacement
+	bestCost float64
+	found    bool
+
+	iterations    int
+	maxIterations int
+	deadline      time.Time
+	stopped       bool
+}
+
+// Allocate place all the pod requests on nodes. The placement with the lowest cost of the objective function
+// found within the search budget is returned, the search ends early when no placement can do better.
 func Allocate(nodes []NodeResource, podRequests []PodCardRequest, reqXPUInterBandwidth map[string]map[string]int) ([]PodAllocation, error) {
-	var allocations []PodAllocation
+	if len(podRequests) == 0 || len(nodes) == 0 {
+		return nil, ErrCannotAllocation
+	}
+	if len(reqXPUInterBandwidth) != 0 && nodeBandwidth.IsEmpty() {
+		return nil, ErrCannotAllocation
+	}
 
-	minInvalidPodCount := len(podRequests) + 1
-	combinations := allCombinationOfNodeAllocation(nodes, podRequests)
+	s := newSearchState(nodes, podRequests, reqXPUInterBandwidth)
+	s.search(0)
+	if !s.found {
+		return nil, ErrCannotAllocation
+	}
 
-	for _, combination := range combinations {
-		valid, allocation := validNodeAllocation(combination, nodes, podRequests)
-		if !valid {
-			continue
-		}
-		if len(reqXPUInterBandwidth) != 0 && (len(util.XPUTopologyNodeBandwidth) == 0 || !varifyInterBandwidth(allocation, reqXPUInterBandwidth)) {
-			continue
+	allocations := make([]PodAllocation, len(podRequests))
+	for i, placement := range s.best {
+		podIdx := s.order[i]
+		allocations[podIdx] = PodAllocation{
+			TaskId:             podRequests[podIdx].TaskId,
+			NodeName:           nodes[placement.nodeIdx].NodeName,
+			DeviceIds:          placement.deviceIds,
+			ContainerDeviceIds: placement.groups,
 		}
+	}
+	return allocations, nil
+}
 
-		if ok, PodAllocations, count := tryMakePodAllocation(allocation); ok {
-			if count == 0 {
-				return PodAllocations, nil
-			}
-			if count < minInvalidPodCount {
-				minInvalidPodCount = count
-				allocations = PodAllocations
+func newSearchState(nodes []NodeResource, podRequests []PodCardRequest,
+	reqXPUInterBandwidth map[string]map[string]int) *searchState {
+	order := make([]int, len(podRequests))
+	for i := range order {
+		order[i] = i
+	}
+	// most constrained pods first, they are the most likely to fail
+	sort.SliceStable(order, func(i, j int) bool {
+		return podRequests[order[i]].cardNumber() > podRequests[order[j]].cardNumber()
+	})
+	usedDevices := make([]map[int]struct{}, len(nodes))
+	for i := range usedDevices {
+		usedDevices[i] = make(map[int]struct{})
+	}
+	interPairs := 0
+	for i := 0; i < len(podRequests)-1; i++ {
+		for j := i + 1; j < len(podRequests); j++ {
+			if getInterBandwidth(reqXPUInterBandwidth, podRequests[i].TaskName, podRequests[j].TaskName) > 0 {
+				interPairs++
 			}
 		}
 	}
-	if len(allocations) != 0 {
-		return allocations, nil
+	maxNodeBandwidth := nodeBandwidth.Max()
+	cards, maxFree := 0, 0
+	for _, request := range podRequests {
+		cards += request.cardNumber()
 	}
-	return nil, ErrCannotAllocation
-}
-
-func tryMakePodAllocation(allocations []nodeAllocation) (bool, []PodAllocation, int) {
-	result := make([]PodAllocation, 0)
-	invalidPodTotalCount := 0
-	for _, allocation := range allocations {
-		PodAllocation, invalidPodCount := performPodAllocation(allocation.nodeResource, allocation.podRequest)
-		if len(PodAllocation) == 0 {
-			return false, nil, 0
+	for _, node := range nodes {
+		if len(node.UnuseDevices) > maxFree {
+			maxFree = len(node.UnuseDevices)
 		}
-		invalidPodTotalCount += invalidPodCount
-		result = append(result, PodAllocation...)
 	}
-	return true, result, invalidPodTotalCount
+	minNodes := 1
+	if maxFree > 0 && cards > maxFree {
+		minNodes = (cards + maxFree - 1) / maxFree
+	}
+	uuidFiltered := false
+	for _, request := range podRequests {
+		for _, container := range request.containerRequests() {
+			uuidFiltered = uuidFiltered || !container.DeviceUUID.IsEmpty()
+		}
+	}
+	return &searchState{
+		nodes:                nodes,
+		podRequests:          podRequests,
+		order:                order,
+		reqXPUInterBandwidth: reqXPUInterBandwidth,
+		maxNodeBandwidth:     maxNodeBandwidth,
+		interPairs:           interPairs,
+		minNodes:             minNodes,
+		uuidFiltered:         uuidFiltered,
+		usedDevices:          usedDevices,
+		podsOfNode:           make([]int, len(nodes)),
+		placements:           make([]podPlacement, len(podRequests)),
+		maxIterations:        maxIterations,
+		deadline:             time.Now().Add(timeout),
+	}
 }
 
-func allCombinationOfNodeAllocation(nodes []NodeResource, pods []PodCardRequest) []nodeAssignment {
-	result := make([]nodeAssignment, 0, len(nodes))
-	generateAllCombinations(len(nodes), len(pods), 0, make(nodeAssignment, len(nodes)), &result)
-	return result
+func (s *searchState) budgetExhausted() bool {
+	if s.stopped {
+		return true
+	}
+	s.iterations++
+	if s.iterations > s.maxIterations ||
+		(s.iterations%timeCheckInterval == 0 && time.Now().After(s.deadline)) {
+		s.stopped = true
+	}
+	return s.stopped
 }
 
-func generateAllCombinations(nodeCnt int, podCnt int, idx int, contents nodeAssignment, result *[]nodeAssignment) {
-	if idx == podCnt {
-		*result = append(*result, cloneNodeAssignment(contents))
-		return
+// search place the pod at position depth of the order, return true when the search should end
+func (s *searchState) search(depth int) bool {
+	if depth == len(s.order) {
+		cost := s.partialCost() + weights.Fragmentation*s.fragmentation()
+		if !s.found || cost < s.bestCost-objectiveEpsilon {
+			s.best = append([]podPlacement(nil), s.placements...)
+			s.bestCost = cost
+			s.found = true
+		}
+		return s.bestCost <= s.idealCost()+objectiveEpsilon
 	}
-
-	for i := 0; i < nodeCnt; i++ {
-		if i >= len(contents) {
+	request := s.podRequests[s.order[depth]]
+	tried := make(map[string]struct{})
+	for _, nodeIdx := range s.nodeOrder() {
+		if s.budgetExhausted() {
+			return true
+		}
+		// nodes with the same free devices and topology give the same sub search
+		signature := s.nodeSignature(nodeIdx)
+		if _, ok := tried[signature]; ok {
+			continue
+		}
+		tried[signature] = struct{}{}
+		if !s.interBandwidthSatisfied(depth, nodeIdx) {
 			continue
 		}
-		contents[i] = append(contents[i], idx)
-		generateAllCombinations(nodeCnt, podCnt, idx+1, contents, result)
-		contents[i] = contents[i][:len(contents[i])-1]
+		done := s.forEachPodGroups(nodeIdx, request, func(groups [][]int) bool {
+			s.place(depth, nodeIdx, groups)
+			if s.found && s.lowerBound(depth+1) >= s.bestCost-objectiveEpsilon {
+				s.remove(depth)
+				return false
+			}
+			done := s.search(depth + 1)
+			s.remove(depth)
+			return done
+		})
+		if done {
+			return true
+		}
 	}
+	return false
 }
 
-func cloneNodeAssignment(contents nodeAssignment) nodeAssignment {
-	duplicate := make(nodeAssignment, len(contents))
-	for i := range contents {
-		duplicate[i] = make([]int, len(contents[i]))
-		copy(duplicate[i], contents[i])
+func (s *searchState) place(depth int, nodeIdx int, groups [][]int) {
+	node := s.nodes[nodeIdx]
+	placement := podPlacement{
+		nodeIdx:        nodeIdx,
+		groups:         groups,
+		minIntraReward: 1,
 	}
-	return duplicate
-}
-
-func validNodeAllocation(assignments nodeAssignment, nodes []NodeResource, podRequests []PodCardRequest) (bool, []nodeAllocation) {
-	result := make([]nodeAllocation, 0, len(assignments))
-	for nodeIdx, podIndexes := range assignments {
-		if len(podIndexes) == 0 {
-			continue
+	// every container group is judged on its own, the pod gets the average and lowest bandwidth rewards
+	containers := s.podRequests[s.order[depth]].containerRequests()
+	for i, group := range groups {
+		placement.deviceIds = append(placement.deviceIds, group...)
+		if numa && !sameNuma(node, group) {
+			placement.invalid = true
 		}
-
-		requests := make([]PodCardRequest, 0, len(podIndexes))
-		deviceRequestOnNode := 0
-		for _, podIdx := range podIndexes {
-			if podIdx < len(podRequests) {
-				deviceRequestOnNode += podRequests[podIdx].NumberOfCard
-				requests = append(requests, podRequests[podIdx])
-			}
+		intra, minIntra := intraBandwidthReward(node, group)
+		placement.intraReward += intra / float64(len(groups))
+		if minIntra < placement.minIntraReward {
+			placement.minIntraReward = minIntra
 		}
-		if nodeIdx >= len(nodes) {
-			return false, nil
+		if i < len(containers) {
+			placement.typePenalty += cardTypePenalty(node, group, containers[i]) / float64(len(groups))
 		}
-		if deviceRequestOnNode > len(nodes[nodeIdx].UnuseDevices) {
-			return false, nil
+	}
+	request := s.podRequests[s.order[depth]]
+	for i := 0; i < depth; i++ {
+		if getInterBandwidth(s.reqXPUInterBandwidth, request.TaskName, s.podRequests[s.order[i]].TaskName) > 0 {
+			placement.interReward += s.interBandwidthReward(nodeIdx, s.placements[i].nodeIdx)
+			placement.interPairs++
 		}
-		result = append(result, nodeAllocation{
-			nodeResource: nodes[nodeIdx],
-			podRequest:   requests,
-		})
 	}
+	s.placements[depth] = placement
+
+	for _, id := range placement.deviceIds {
+		s.usedDevices[nodeIdx][id] = struct{}{}
+	}
+	if s.podsOfNode[nodeIdx] == 0 {
+		s.nodesUsed++
+	}
+	s.podsOfNode[nodeIdx]++
+	if placement.invalid {
+		s.invalid++
+	}
+	s.intraReward += placement.intraReward
+	s.minIntraReward += placement.minIntraReward
+	s.interReward += placement.interReward
+	s.interPairsPlaced += placement.interPairs
+	s.typePenalty += placement.typePenalty
+}
 
-	return true, result
+func (s *searchState) remove(depth int) {
+	placement := s.placements[depth]
+	for _, id := range placement.deviceIds {
+		delete(s.usedDevices[placement.nodeIdx], id)
+	}
+	s.podsOfNode[placement.nodeIdx]--
+	if s.podsOfNode[placement.nodeIdx] == 0 {
+		s.nodesUsed--
+	}
+	if placement.invalid {
+		s.invalid--
+	}
+	s.intraReward -= placement.intraReward
+	s.minIntraReward -= placement.minIntraReward
+	s.interReward -= placement.interReward
+	s.interPairsPlaced -= placement.interPairs
+	s.typePenalty -= placement.typePenalty
 }
 
-func varifyInterBandwidth(allocation []nodeAllocation, reqXPUInterBandwidth map[string]map[string]int) bool {
-	for i := range allocation {
-		if len(allocation[i].podRequest) == 0 {
-			continue
+// nodeOrder try the nodes already hosting pods of the job first, then the nodes with more free devices
+func (s *searchState) nodeOrder() []int {
+	order := make([]int, len(s.nodes))
+	for i := range order {
+		order[i] = i
+	}
+	free := func(idx int) int {
+		return len(s.nodes[idx].UnuseDevices) - len(s.usedDevices[idx])
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		hostI, hostJ := s.podsOfNode[order[i]] > 0, s.podsOfNode[order[j]] > 0
+		if hostI != hostJ {
+			return hostI
 		}
-		nodei := allocation[i].nodeResource.NodeName
-		_, ok1 := util.XPUTopologyNodeBandwidth[nodei]
-		for j := i + 1; j < len(allocation); j++ {
-			nodej := allocation[j].nodeResource.NodeName
-			_, ok2 := util.XPUTopologyNodeBandwidth[nodej]
-			nodeBandwidth := 0
-			if ok1 && ok2 {
-				nodeBandwidth = util.XPUTopologyNodeBandwidth[nodei][nodej]
-			}
-			if !varifyPodInterBandwidth(allocation[i].podRequest, allocation[j].podRequest, nodeBandwidth, reqXPUInterBandwidth) {
-				return false
-			}
+		return free(order[i]) > free(order[j])
+	})
+	return order
+}
+
+func (s *searchState) nodeSignature(nodeIdx int) string {
+	node := s.nodes[nodeIdx]
+	var ids []int
+	for id := range node.UnuseDevices {
+		if _, used := s.usedDevices[nodeIdx][id]; !used {
+			ids = append(ids, id)
 		}
 	}
-	return true
+	sort.Ints(ids)
+	var signature strings.Builder
+	for _, id := range ids {
+		device := node.UnuseDevices[id]
+		signature.WriteString(strconv.Itoa(id) + "," + device.Type + "," + strconv.Itoa(device.Numa))
+		if s.uuidFiltered {
+			signature.WriteString("," + device.Id)
+		}
+		signature.WriteString(";")
+	}
+	signature.WriteString("|")
+	for _, row := range node.Topology {
+		for _, bandwidth := range row {
+			signature.WriteString(strconv.Itoa(bandwidth) + ",")
+		}
+	}
+	// the bandwidth to the other nodes differs unless there is no inter bandwidth requirement
+	if len(s.reqXPUInterBandwidth) != 0 || s.podsOfNode[nodeIdx] != 0 {
+		signature.WriteString("|" + node.NodeName)
+	}
+	return signature.String()
 }
 
-func varifyPodInterBandwidth(podRequests1 []PodCardRequest, podRequests2 []PodCardRequest, nodeBandwidth int, reqXPUInterBandwidth map[string]map[string]int) bool {
-	for i := range podRequests1 {
-		taski := podRequests1[i].TaskName
-		if _, ok := reqXPUInterBandwidth[taski]; !ok {
+// interBandwidthSatisfied check the bandwidth between the node and nodes of the pods already placed
+func (s *searchState) interBandwidthSatisfied(depth int, nodeIdx int) bool {
+	if len(s.reqXPUInterBandwidth) == 0 {
+		return true
+	}
+	request := s.podRequests[s.order[depth]]
+	for i := 0; i < depth; i++ {
+		other := s.placements[i]
+		if other.nodeIdx == nodeIdx {
 			continue
 		}
-		for j := range podRequests2 {
-			taskj := podRequests2[j].TaskName
-			if _, ok := reqXPUInterBandwidth[taskj]; !ok {
-				continue
-			}
-			needBandwidth := reqXPUInterBandwidth[taski][taskj]
-			if needBandwidth > nodeBandwidth {
-				return false
-			}
+		nodeBandwidth := getNodeBandwidth(s.nodes[nodeIdx].NodeName, s.nodes[other.nodeIdx].NodeName)
+		needBandwidth := getInterBandwidth(s.reqXPUInterBandwidth, request.TaskName,
+			s.podRequests[s.order[i]].TaskName)
+		if needBandwidth > nodeBandwidth {
+			return false
 		}
 	}
 	return true
 }
 
-func performPodAllocation(node NodeResource, podRequests []PodCardRequest) ([]PodAllocation, int) {
-	var successfulAllocations []PodAllocation
-
-	mask := initializeAllocatedMask(node.Topology, podRequests)
-	masks := permuteUniqueAllocation(mask)
-	minInvalidPodCount := len(podRequests) + 1
+func getNodeBandwidth(nodei string, nodej string) int {
+	return nodeBandwidth.Get(nodei, nodej)
+}
 
-	for _, mask := range masks {
-		deviceIds := buildDeviceAllocation(mask, podRequests)
-		isGoodAllocation, allocations := goodPodAllocation(deviceIds, node, podRequests)
+func getInterBandwidth(reqXPUInterBandwidth map[string]map[string]int, taski string, taskj string) int {
+	_, ok1 := reqXPUInterBandwidth[taski]
+	_, ok2 := reqXPUInterBandwidth[taskj]
+	if !ok1 || !ok2 {
+		return 0
+	}
+	need := reqXPUInterBandwidth[taski][taskj]
+	if reqXPUInterBandwidth[taskj][taski] > need {
+		need = reqXPUInterBandwidth[taskj][taski]
+	}
+	return need
+}
 
-		if !isGoodAllocation {
-			continue
+// forEachPodGroups call visit with the device groups of every container of the pod request on the node,
+// until visit returns true or the search budget runs out. The containers are given their groups in turn,
+// the most constrained first, one asking for a card type or else for most cards, and every group of a
+// container is tried with the groups of the next ones among the devices left. Return true when the
+// enumeration was stopped.
+func (s *searchState) forEachPodGroups(nodeIdx int, request PodCardRequest, visit func(groups [][]int) bool) bool {
+	containers := request.containerRequests()
+	order := make([]int, len(containers))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		typedI, typedJ := !containers[order[i]].CardType.IsEmpty(), !containers[order[j]].CardType.IsEmpty()
+		if typedI != typedJ {
+			return typedI
 		}
-
-		if !numa {
-			return allocations, 0
+		return containers[order[i]].NumberOfCard > containers[order[j]].NumberOfCard
+	})
+
+	groups := make([][]int, len(containers))
+	excluded := make(map[int]struct{})
+	var assign func(k int) bool
+	assign = func(k int) bool {
+		if k == len(order) {
+			return visit(append([][]int(nil), groups...))
 		}
+		idx := order[k]
+		return s.forEachDeviceGroup(nodeIdx, containers[idx], excluded, func(group []int) bool {
+			groups[idx] = group
+			for _, id := range group {
+				excluded[id] = struct{}{}
+			}
+			stop := assign(k + 1)
+			for _, id := range group {
+				delete(excluded, id)
+			}
+			return stop
+		})
+	}
+	return assign(0)
+}
 
-		meetsNumaContraints, invalidPodCount := checkNumaConstraints(node, allocations)
-
-		if !meetsNumaContraints {
-			continue
+// forEachDeviceGroup call visit with the device groups of the node able to hold the container request,
+// until visit returns true or the search budget runs out. The groups grown greedily from each free device
+// come first, the ones lowering the cost most first, so that good placements are found early and prune
+// more. Every other combination of the devices follows, so no group is missed while the budget lasts.
+// Return true when the enumeration was stopped.
+func (s *searchState) forEachDeviceGroup(nodeIdx int, request ContainerCardRequest, excluded map[int]struct{},
+	visit func(group []int) bool) bool {
+	if request.NumberOfCard == 0 {
+		return s.budgetExhausted() || visit([]int{})
+	}
+	node := s.nodes[nodeIdx]
+	eligible := s.eligibleDevices(nodeIdx, request, excluded)
+	if len(eligible) < request.NumberOfCard {
+		return false
+	}
+	greedy := greedyDeviceGroups(node, eligible, request)
+	seen := make(map[string]struct{}, len(greedy))
+	for _, group := range greedy {
+		seen[groupKey(group)] = struct{}{}
+		if s.budgetExhausted() || visit(group) {
+			return true
 		}
+	}
 
-		if invalidPodCount == 0 {
-			return allocations, 0
+	// the combinations in the order of the eligible devices, a device joins only with enough bandwidth
+	// to the devices already in the group
+	group := make([]int, 0, request.NumberOfCard)
+	var combine func(start int) bool
+	combine = func(start int) bool {
+		if len(group) == request.NumberOfCard {
+			sorted := append([]int(nil), group...)
+			sort.Ints(sorted)
+			if _, ok := seen[groupKey(sorted)]; ok {
+				return false
+			}
+			return s.budgetExhausted() || visit(sorted)
 		}
-
-		if invalidPodCount < minInvalidPodCount {
-			minInvalidPodCount = invalidPodCount
-			successfulAllocations = allocations
+		for i := start; i <= len(eligible)-(request.NumberOfCard-len(group)); i++ {
+			if _, ok := bandwidthToGroup(node.Topology, group, eligible[i], request.IntraBandWidth); !ok {
+				continue
+			}
+			group = append(group, eligible[i])
+			stop := combine(i + 1)
+			group = group[:len(group)-1]
+			if stop {
+				return true
+			}
 		}
+		return false
 	}
-	return successfulAllocations, minInvalidPodCount
+	return combine(0)
 }
 
-func initializeAllocatedMask(topology [][]int, podRequests []PodCardRequest) []int {
-	var (
-		i      = 0
-		result = make([]int, len(topology))
-	)
-	for idx, req := range podRequests {
-		for cnt := req.NumberOfCard; cnt > 0; cnt-- {
-			result[i] = idx
-			i++
+// eligibleDevices the free devices of the node accepted by the container request, the devices of
+// the preferred card types first
+func (s *searchState) eligibleDevices(nodeIdx int, request ContainerCardRequest, excluded map[int]struct{}) []int {
+	node := s.nodes[nodeIdx]
+	var eligible []int
+	for id, device := range node.UnuseDevices {
+		if _, used := s.usedDevices[nodeIdx][id]; used || id >= len(node.Topology) {
+			continue
 		}
+		if _, ok := excluded[id]; ok {
+			continue
+		}
+		if !request.CardType.Accept(device.Type) || !request.DeviceUUID.Accept(device.Id) {
+			continue
+		}
+		eligible = append(eligible, id)
 	}
-	for i < len(topology) {
-		result[i] = len(podRequests)
-		i++
-	}
-	return result
-}
-
-func permuteUniqueAllocation(mask []int) [][]int {
-	result := make([][]int, 0)
-	generateUniqueAllocationPermutation(mask, 0, make([]bool, len(mask)), []int{}, &result)
-	return result
+	sort.Slice(eligible, func(i, j int) bool {
+		pi := request.CardType.Penalty(node.UnuseDevices[eligible[i]].Type)
+		pj := request.CardType.Penalty(node.UnuseDevices[eligible[j]].Type)
+		if pi != pj {
+			return pi < pj
+		}
+		return eligible[i] < eligible[j]
+	})
+	return eligible
 }
 
-func generateUniqueAllocationPermutation(nums []int, idx int, visited []bool, contents []int, result *[][]int) {
-	if idx == len(nums) {
-		*result = append(*result, append([]int(nil), contents...))
-		return
+// greedyDeviceGroups the first eligible devices, and the groups each eligible device seeds grown by the
+// device with the largest bandwidth to the group, inside the NUMA node of the seed first when NUMA is
+// enabled. The groups lowering the cost most come first
+func greedyDeviceGroups(node NodeResource, eligible []int, request ContainerCardRequest) [][]int {
+	var groups [][]int
+	seen := make(map[string]struct{})
+	addGroup := func(group []int) {
+		if len(group) != request.NumberOfCard || !goodPodAllocation(group, node, request) {
+			return
+		}
+		key := groupKey(group)
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		groups = append(groups, group)
 	}
-
-	for i := 0; i < len(nums); i++ {
-		if i >= len(visited) || visited[i] {
-			continue
+	first := append([]int(nil), eligible[:request.NumberOfCard]...)
+	sort.Ints(first)
+	addGroup(first)
+	for _, seed := range eligible {
+		if numa {
+			addGroup(growDeviceGroup(node, eligible, seed, request, true))
 		}
-		if i > 0 && nums[i] == nums[i-1] && !visited[i-1] {
-			continue
+		addGroup(growDeviceGroup(node, eligible, seed, request, false))
+	}
+	costs := make(map[string]float64, len(groups))
+	for _, group := range groups {
+		intra, minIntra := intraBandwidthReward(node, group)
+		cost := -weights.IntraBandwidth*intra - weights.MinIntraBandwidth*minIntra +
+			weights.CardType*cardTypePenalty(node, group, request)
+		if numa && !sameNuma(node, group) {
+			cost += weights.Numa
 		}
-
-		visited[i] = true
-		generateUniqueAllocationPermutation(nums, idx+1, visited, append(contents, nums[i]), result)
-		visited[i] = false
+		costs[groupKey(group)] = cost
 	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		return costs[groupKey(groups[i])] < costs[groupKey(groups[j])]
+	})
+	return groups
 }
 
-func buildDeviceAllocation(mask []int, podRequests []PodCardRequest) [][]int {
-	deviceIds := make([][]int, len(podRequests))
-	for xpuId, podId := range mask {
-		if podId >= len(podRequests) {
-			continue
+func growDeviceGroup(node NodeResource, eligible []int, seed int, request ContainerCardRequest,
+	sameNumaOnly bool) []int {
+	group := []int{seed}
+	inGroup := map[int]struct{}{seed: {}}
+	for len(group) < request.NumberOfCard {
+		bestId, bestBandwidth := -1, -1
+		for _, id := range eligible {
+			if _, ok := inGroup[id]; ok {
+				continue
+			}
+			if sameNumaOnly && node.UnuseDevices[id].Numa != node.UnuseDevices[seed].Numa {
+				continue
+			}
+			total, ok := bandwidthToGroup(node.Topology, group, id, request.IntraBandWidth)
+			if ok && total > bestBandwidth {
+				bestId, bestBandwidth = id, total
+			}
 		}
-		deviceIds[podId] = append(deviceIds[podId], xpuId)
+		if bestId < 0 {
+			return nil
+		}
+		group = append(group, bestId)
+		inGroup[bestId] = struct{}{}
 	}
-	return deviceIds
+	sort.Ints(group)
+	return group
 }
 
-func goodPodAllocation(deviceIds [][]int, node NodeResource, podRequests []PodCardRequest) (bool, []PodAllocation) {
-	allocates := make([]PodAllocation, len(podRequests))
-	for i := range deviceIds {
-		if i >= len(podRequests) {
-			return false, nil
-		}
-		if !checkTopology(node.Topology, deviceIds[i], podRequests[i]) {
-			return false, nil
+// bandwidthToGroup sum of bandwidth between the device and the group, false if any link is below minimum
+func bandwidthToGroup(topology [][]int, group []int, id int, minBandwidth int) (int, bool) {
+	total := 0
+	for _, member := range group {
+		row, col := member, id
+		if row > col {
+			row, col = col, row
 		}
-		for _, id := range deviceIds[i] {
-			if device, ok := node.UnuseDevices[id]; !ok ||
-				(len(podRequests[i].CardType) != 0 && device.Type != podRequests[i].CardType) {
-				return false, nil
-			}
+		if row >= len(topology) || col >= len(topology[row]) || topology[row][col] < minBandwidth {
+			return 0, false
 		}
+		total += topology[row][col]
+	}
+	return total, true
+}
+
+func groupKey(group []int) string {
+	keys := make([]string, len(group))
+	for i, id := range group {
+		keys[i] = strconv.Itoa(id)
+	}
+	return strings.Join(keys, ",")
+}
 
-		allocates[i] = PodAllocation{
-			DeviceIds: deviceIds[i],
-			NodeName:  node.NodeName,
-			TaskId:    podRequests[i].TaskId,
+func goodPodAllocation(deviceIds []int, node NodeResource, podRequest ContainerCardRequest) bool {
+	if !checkTopology(node.Topology, deviceIds, podRequest) {
+		return false
+	}
+	for _, id := range deviceIds {
+		if device, ok := node.UnuseDevices[id]; !ok || !podRequest.CardType.Accept(device.Type) ||
+			!podRequest.DeviceUUID.Accept(device.Id) {
+			return false
 		}
 	}
-	return true, allocates
+	return true
 }
 
-func checkTopology(topology [][]int, xpuIds []int, podRequest PodCardRequest) bool {
+func checkTopology(topology [][]int, xpuIds []int, podRequest ContainerCardRequest) bool {
 	for i := 0; i < len(xpuIds)-1; i++ {
 		for j := i + 1; j < len(xpuIds); j++ {
 			var (
@@ -304,23 +623,30 @@ func SetNumaConfig(enable bool) {
 	numa = enable
 }
 
-func checkNumaConstraints(resource NodeResource, allocations []PodAllocation) (bool, int) {
-	const noNumaNode = -1
-	var invalidPodCount = 0
+// SetNodeBandwidth set the bandwidth between the nodes of the session
+func SetNodeBandwidth(bandwidth *util.NodeBandwidth) {
+	nodeBandwidth = bandwidth
+}
 
-	for _, alloc := range allocations {
-		perNumaNode := noNumaNode
-		for _, id := range alloc.DeviceIds {
-			xpuDevice, exists := resource.UnuseDevices[id]
-			if !exists {
-				return false, 0
-			}
-			if perNumaNode != noNumaNode && xpuDevice.Numa != perNumaNode {
-				invalidPodCount++
-				break
-			}
-			perNumaNode = xpuDevice.Numa
+// SetSearchBudget set the maximum iterations and time of one Allocate call,
+// non-positive values mean the default budget
+func SetSearchBudget(iterations int, duration time.Duration) {
+	maxIterations = DefaultMaxIterations
+	if iterations > 0 {
+		maxIterations = iterations
+	}
+	timeout = DefaultTimeout
+	if duration > 0 {
+		timeout = duration
+	}
+}
+
+// sameNuma check all the devices are in one NUMA node
+func sameNuma(resource NodeResource, deviceIds []int) bool {
+	for i := 1; i < len(deviceIds); i++ {
+		if resource.UnuseDevices[deviceIds[i]].Numa != resource.UnuseDevices[deviceIds[0]].Numa {
+			return false
 		}
 	}
-	return true, invalidPodCount
+	return true
 }
@@ -0,0 +1,262 @@
+package allocator
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+func testNode(name string, ids ...string) NodeResource {
+	devices := make(map[int]*common.XPUDevice, len(ids))
+	topology := make([][]int, len(ids))
+	for i, id := range ids {
+		devices[i] = &common.XPUDevice{Index: i, Id: id, Type: "A100", Health: true, Count: 1}
+		topology[i] = make([]int, len(ids))
+		for j := range ids {
+			if i != j {
+				topology[i][j] = 100
+			}
+		}
+	}
+	return NodeResource{NodeName: name, Topology: topology, UnuseDevices: devices}
+}
+
+// TestAllocateUUIDOnAlikeNode the nodes alike but for their device uuids are all searched when a request
+// is pinned on a device uuid
+func TestAllocateUUIDOnAlikeNode(t *testing.T) {
+	nodes := []NodeResource{testNode("node-1", "GPU-a0", "GPU-a1"), testNode("node-2", "GPU-b0", "GPU-b1")}
+	tests := []struct {
+		name     string
+		uuids    util.XPUUUIDRequest
+		wantNode string
+		wantId   string
+	}{
+		{name: "use on the second node", uuids: util.XPUUUIDRequest{Use: []string{"GPU-b1"}},
+			wantNode: "node-2", wantId: "GPU-b1"},
+		{name: "use on the first node", uuids: util.XPUUUIDRequest{Use: []string{"GPU-a1"}},
+			wantNode: "node-1", wantId: "GPU-a1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := []PodCardRequest{{TaskId: "task-1", TaskName: "task-1", NumberOfCard: 1,
+				DeviceUUID: tt.uuids}}
+			result, err := Allocate(nodes, requests, nil)
+			if err != nil {
+				t.Fatalf("allocate: %v", err)
+			}
+			if len(result) != 1 || result[0].NodeName != tt.wantNode || len(result[0].DeviceIds) != 1 {
+				t.Fatalf("allocation %+v, want one device on %s", result, tt.wantNode)
+			}
+			node := nodes[0]
+			if tt.wantNode == nodes[1].NodeName {
+				node = nodes[1]
+			}
+			if id := node.UnuseDevices[result[0].DeviceIds[0]].Id; id != tt.wantId {
+				t.Errorf("allocated device %s, want %s", id, tt.wantId)
+			}
+		})
+	}
+}
+
+// testTopologyNode a node whose devices have the types and NUMA nodes given, linked by the bandwidth matrix
+func testTopologyNode(name string, types []string, numas []int, topology [][]int) NodeResource {
+	devices := make(map[int]*common.XPUDevice, len(types))
+	for i, cardType := range types {
+		devices[i] = &common.XPUDevice{Index: i, Id: name + "-" + strconv.Itoa(i), Type: cardType, Numa: numas[i],
+			Health: true, Count: 1}
+	}
+	return NodeResource{NodeName: name, Topology: topology, UnuseDevices: devices}
+}
+
+// initializeAllocatedMask, permuteUniqueAllocation and generateUniqueAllocationPermutation are the exhaustive
+// enumeration the allocator used before the budgeted search, every assignment of the devices to the pods
+func initializeAllocatedMask(topology [][]int, podRequests []PodCardRequest) []int {
+	var (
+		i      = 0
+		result = make([]int, len(topology))
+	)
+	for idx, req := range podRequests {
+		for cnt := req.NumberOfCard; cnt > 0; cnt-- {
+			result[i] = idx
+			i++
+		}
+	}
+	for i < len(topology) {
+		result[i] = len(podRequests)
+		i++
+	}
+	return result
+}
+
+func permuteUniqueAllocation(mask []int) [][]int {
+	result := make([][]int, 0)
+	generateUniqueAllocationPermutation(mask, 0, make([]bool, len(mask)), []int{}, &result)
+	return result
+}
+
+func generateUniqueAllocationPermutation(nums []int, idx int, visited []bool, contents []int, result *[][]int) {
+	if idx == len(nums) {
+		*result = append(*result, append([]int(nil), contents...))
+		return
+	}
+	for i := 0; i < len(nums); i++ {
+		if i >= len(visited) || visited[i] {
+			continue
+		}
+		if i > 0 && nums[i] == nums[i-1] && !visited[i-1] {
+			continue
+		}
+		visited[i] = true
+		generateUniqueAllocationPermutation(nums, idx+1, visited, append(contents, nums[i]), result)
+		visited[i] = false
+	}
+}
+
+// placementCost cost of the objective function of the pods placed on the device groups of the node
+func placementCost(node NodeResource, podRequests []PodCardRequest, groups [][]int) float64 {
+	s := newSearchState([]NodeResource{node}, podRequests, nil)
+	for depth, podIdx := range s.order {
+		s.place(depth, 0, [][]int{groups[podIdx]})
+	}
+	return s.partialCost() + weights.Fragmentation*s.fragmentation()
+}
+
+// enumerateAllocation the lowest cost of the valid assignments of the old enumeration, false if there is none
+func enumerateAllocation(node NodeResource, podRequests []PodCardRequest) (float64, bool) {
+	best, found := 0.0, false
+	for _, mask := range permuteUniqueAllocation(initializeAllocatedMask(node.Topology, podRequests)) {
+		groups := make([][]int, len(podRequests))
+		for id, podIdx := range mask {
+			if podIdx < len(podRequests) {
+				groups[podIdx] = append(groups[podIdx], id)
+			}
+		}
+		valid := true
+		for i, group := range groups {
+			valid = valid && goodPodAllocation(group, node, podRequests[i].containerRequests()[0])
+		}
+		if !valid {
+			continue
+		}
+		if cost := placementCost(node, podRequests, groups); !found || cost < best {
+			best, found = cost, true
+		}
+	}
+	return best, found
+}
+
+// TestAllocateGroupNotGreedilyReachable the only group meeting the intra bandwidth is found though growing
+// a group from any device by its best link leads elsewhere
+func TestAllocateGroupNotGreedilyReachable(t *testing.T) {
+	topology := [][]int{
+		{0, 300, 100, 100, 10, 10},
+		{300, 0, 10, 10, 10, 10},
+		{100, 10, 0, 100, 300, 10},
+		{100, 10, 100, 0, 10, 300},
+		{10, 10, 300, 10, 0, 10},
+		{10, 10, 10, 300, 10, 0},
+	}
+	node := testTopologyNode("node-1", []string{"A100", "A100", "A100", "A100", "A100", "A100"},
+		[]int{0, 0, 0, 0, 0, 0}, topology)
+	requests := []PodCardRequest{{TaskId: "task-1", TaskName: "task-1", NumberOfCard: 3, IntraBandWidth: 100}}
+	result, err := Allocate([]NodeResource{node}, requests, nil)
+	if err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+	if got := fmt.Sprint(result[0].DeviceIds); got != "[0 2 3]" {
+		t.Errorf("allocated devices %s, want [0 2 3]", got)
+	}
+}
+
+// TestAllocateAgainstEnumeration on small random topologies the search finds a placement exactly when the
+// old enumeration does, and one of the lowest cost
+func TestAllocateAgainstEnumeration(t *testing.T) {
+	defer SetNumaConfig(false)
+	random := rand.New(rand.NewSource(1))
+	bandwidths := []int{10, 50, 100}
+	for i := 0; i < 300; i++ {
+		size := 4 + random.Intn(3)
+		types, numas := make([]string, size), make([]int, size)
+		topology := make([][]int, size)
+		for d := 0; d < size; d++ {
+			types[d], numas[d] = "A100", random.Intn(2)
+			if random.Intn(4) == 0 {
+				types[d] = "H100"
+			}
+			topology[d] = make([]int, size)
+		}
+		for a := 0; a < size; a++ {
+			for b := a + 1; b < size; b++ {
+				topology[a][b] = bandwidths[random.Intn(len(bandwidths))]
+				topology[b][a] = topology[a][b]
+			}
+		}
+		node := testTopologyNode("node-1", types, numas, topology)
+		var requests []PodCardRequest
+		for cards, pods := 0, 1+random.Intn(3); len(requests) < pods; {
+			number := 1 + random.Intn(3)
+			if cards+number > size {
+				break
+			}
+			cards += number
+			request := PodCardRequest{TaskId: api.TaskID("task-" + strconv.Itoa(len(requests))),
+				NumberOfCard: number, IntraBandWidth: bandwidths[random.Intn(len(bandwidths))]}
+			request.TaskName = string(request.TaskId)
+			if random.Intn(3) == 0 {
+				request.CardType = util.XPUTypeRequest{Preferred: []string{"A100"}}
+			}
+			requests = append(requests, request)
+		}
+		if len(requests) == 0 {
+			continue
+		}
+		SetNumaConfig(i%2 == 0)
+		t.Run(fmt.Sprintf("case %d", i), func(t *testing.T) {
+			want, feasible := enumerateAllocation(node, requests)
+			result, err := Allocate([]NodeResource{node}, requests, nil)
+			if (err == nil) != feasible {
+				t.Fatalf("allocate error %v, enumeration feasible %v, topology %v, requests %+v",
+					err, feasible, topology, requests)
+			}
+			if err != nil {
+				return
+			}
+			groups := make([][]int, len(requests))
+			for j, allocation := range result {
+				groups[j] = allocation.DeviceIds
+				if !goodPodAllocation(allocation.DeviceIds, node, requests[j].containerRequests()[0]) {
+					t.Errorf("allocation %v of request %+v is not valid", allocation.DeviceIds, requests[j])
+				}
+			}
+			if got := placementCost(node, requests, groups); got > want+objectiveEpsilon {
+				t.Errorf("allocation %v cost %v, the enumeration finds cost %v", groups, got, want)
+			}
+		})
+	}
+}
+
+// TestAllocateContainersBranch the first choice of a container taking the only card another container
+// accepts is given up for its next choice
+func TestAllocateContainersBranch(t *testing.T) {
+	topology := [][]int{{0, 100, 100, 100}, {100, 0, 100, 100}, {100, 100, 0, 100}, {100, 100, 100, 0}}
+	node := testTopologyNode("node-1", []string{"A100", "A100", "A100", "H100"}, []int{0, 0, 0, 0}, topology)
+	containers := []ContainerCardRequest{
+		{NumberOfCard: 2, CardType: util.XPUTypeRequest{Preferred: []string{"A100"}}},
+		{NumberOfCard: 1, CardType: util.XPUTypeRequest{Preferred: []string{"A100", "H100"}}},
+		{NumberOfCard: 1, CardType: util.XPUTypeRequest{Preferred: []string{"A100"}}},
+	}
+	requests := []PodCardRequest{{TaskId: "task-1", TaskName: "task-1", NumberOfCard: 4, Containers: containers}}
+	result, err := Allocate([]NodeResource{node}, requests, nil)
+	if err != nil {
+		t.Fatalf("allocate: %v", err)
+	}
+	if got := result[0].ContainerDeviceIds[1]; len(got) != 1 || got[0] != 3 {
+		t.Errorf("container devices %v, want the H100 card 3 for the second container",
+			result[0].ContainerDeviceIds)
+	}
+}
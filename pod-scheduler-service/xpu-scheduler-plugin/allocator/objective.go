@@ -0,0 +1,167 @@
+package allocator
+
+// Weights of the terms of the objective function ranking the placements, every term is normalized
+// to [0, 1] so the weights tell how much one term matters against another
+type Weights struct {
+	// Numa penalty of the pods whose devices cross NUMA nodes
+	Numa float64
+	// IntraBandwidth reward of the average bandwidth between the devices of a pod
+	IntraBandwidth float64
+	// MinIntraBandwidth reward of the lowest bandwidth between the devices of a pod
+	MinIntraBandwidth float64
+	// NodeCount penalty of the number of nodes used by the job
+	NodeCount float64
+	// InterBandwidth reward of the bandwidth between the nodes of pods with inter bandwidth requirement
+	InterBandwidth float64
+	// Fragmentation penalty of the free devices left in NUMA nodes partly used by the job
+	Fragmentation float64
+	// CardType penalty of the devices of a less preferred card type than the first one a container accepts
+	CardType float64
+}
+
+// DefaultWeights NUMA alignment dominates, the other terms rank the placements with the same alignment
+var DefaultWeights = Weights{
+	Numa:              10,
+	IntraBandwidth:    1,
+	MinIntraBandwidth: 1,
+	NodeCount:         1,
+	InterBandwidth:    1,
+	Fragmentation:     1,
+	CardType:          1,
+}
+
+// objectiveEpsilon placements whose costs differ less than this are considered equal
+const objectiveEpsilon = 1e-9
+
+// SetObjectiveWeights set the weights of the objective function, negative weights are treated as zero
+func SetObjectiveWeights(w Weights) {
+	for _, value := range []*float64{&w.Numa, &w.IntraBandwidth, &w.MinIntraBandwidth, &w.NodeCount,
+		&w.InterBandwidth, &w.Fragmentation, &w.CardType} {
+		if *value < 0 {
+			*value = 0
+		}
+	}
+	weights = w
+}
+
+// intraBandwidthReward average and lowest bandwidth between the devices, relative to the best link of the node
+func intraBandwidthReward(node NodeResource, deviceIds []int) (float64, float64) {
+	if len(deviceIds) < 2 {
+		return 1, 1
+	}
+	maxLink := maxLinkBandwidth(node.Topology)
+	if maxLink <= 0 {
+		return 0, 0
+	}
+	total, links, lowest := 0, 0, -1
+	for i := 0; i < len(deviceIds)-1; i++ {
+		for j := i + 1; j < len(deviceIds); j++ {
+			bandwidth := node.Topology[deviceIds[i]][deviceIds[j]]
+			total += bandwidth
+			links++
+			if lowest < 0 || bandwidth < lowest {
+				lowest = bandwidth
+			}
+		}
+	}
+	return float64(total) / float64(links) / float64(maxLink), float64(lowest) / float64(maxLink)
+}
+
+// cardTypePenalty average penalty of the types of the devices against the preference of the request
+func cardTypePenalty(node NodeResource, deviceIds []int, request ContainerCardRequest) float64 {
+	if len(deviceIds) == 0 || len(request.CardType.Preferred) < 2 {
+		return 0
+	}
+	penalty := 0.0
+	for _, id := range deviceIds {
+		penalty += request.CardType.Penalty(node.UnuseDevices[id].Type)
+	}
+	return penalty / float64(len(deviceIds))
+}
+
+func maxLinkBandwidth(topology [][]int) int {
+	maxLink := 0
+	for i, row := range topology {
+		for j, bandwidth := range row {
+			if i != j && bandwidth > maxLink {
+				maxLink = bandwidth
+			}
+		}
+	}
+	return maxLink
+}
+
+// fragmentation share of the free devices left on the used nodes which sit in a NUMA node partly used by the job
+func (s *searchState) fragmentation() float64 {
+	left, fragmented := 0, 0
+	for nodeIdx, node := range s.nodes {
+		if len(s.usedDevices[nodeIdx]) == 0 {
+			continue
+		}
+		usedNuma := make(map[int]struct{})
+		for id := range s.usedDevices[nodeIdx] {
+			usedNuma[node.UnuseDevices[id].Numa] = struct{}{}
+		}
+		for id, device := range node.UnuseDevices {
+			if _, used := s.usedDevices[nodeIdx][id]; used {
+				continue
+			}
+			left++
+			if _, ok := usedNuma[device.Numa]; ok {
+				fragmented++
+			}
+		}
+	}
+	if left == 0 {
+		return 0
+	}
+	return float64(fragmented) / float64(left)
+}
+
+// partialCost cost of the pods placed so far, fragmentation excluded
+func (s *searchState) partialCost() float64 {
+	pods := float64(len(s.podRequests))
+	cost := weights.Numa*float64(s.invalid)/pods + weights.NodeCount*float64(s.nodesUsed)/pods -
+		weights.IntraBandwidth*s.intraReward/pods - weights.MinIntraBandwidth*s.minIntraReward/pods +
+		weights.CardType*s.typePenalty/pods
+	if s.interPairs > 0 {
+		cost -= weights.InterBandwidth * s.interReward / float64(s.interPairs)
+	}
+	return cost
+}
+
+// lowerBound the lowest cost any placement extending the current one can reach, the pods left
+// get full rewards and no penalty
+func (s *searchState) lowerBound(depth int) float64 {
+	pods := float64(len(s.podRequests))
+	left := float64(len(s.order) - depth)
+	bound := s.partialCost() - (weights.IntraBandwidth+weights.MinIntraBandwidth)*left/pods
+	if s.nodesUsed < s.minNodes {
+		bound += weights.NodeCount * float64(s.minNodes-s.nodesUsed) / pods
+	}
+	if s.interPairs > 0 {
+		bound -= weights.InterBandwidth * float64(s.interPairs-s.interPairsPlaced) / float64(s.interPairs)
+	}
+	return bound
+}
+
+// idealCost the cost of a placement on the fewest nodes with perfect bandwidth, no search can do better
+func (s *searchState) idealCost() float64 {
+	cost := weights.NodeCount*float64(s.minNodes)/float64(len(s.podRequests)) -
+		weights.IntraBandwidth - weights.MinIntraBandwidth
+	if s.interPairs > 0 {
+		cost -= weights.InterBandwidth
+	}
+	return cost
+}
+
+// interBandwidthReward bandwidth between two nodes relative to the best one, pods on the same node get full reward
+func (s *searchState) interBandwidthReward(nodei int, nodej int) float64 {
+	if nodei == nodej {
+		return 1
+	}
+	if s.maxNodeBandwidth <= 0 {
+		return 0
+	}
+	return float64(getNodeBandwidth(s.nodes[nodei].NodeName, s.nodes[nodej].NodeName)) / float64(s.maxNodeBandwidth)
+}
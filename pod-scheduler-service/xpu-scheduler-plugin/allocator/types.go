@@ -5,11 +5,16 @@ import (
 
 	"volcano.sh/volcano/pkg/scheduler/api"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
 )
 
 var (
 	ErrCannotAllocation = errors.New("cannot allocate")
 	numa                bool
+	maxIterations       = DefaultMaxIterations
+	timeout             = DefaultTimeout
+	weights             = DefaultWeights
+	nodeBandwidth       *util.NodeBandwidth
 )
 
 type NodeResource struct {
@@ -24,11 +29,43 @@ type PodCardRequest struct {
 	TaskName       string
 	NumberOfCard   int
 	IntraBandWidth int
-	CardType       string
+	CardType       util.XPUTypeRequest
+	DeviceUUID     util.XPUUUIDRequest
+	// Containers requests of the containers, each one is given its own device group,
+	// the pod fields above make a single group if it is empty
+	Containers []ContainerCardRequest
+}
+
+// ContainerCardRequest card request of one container of the pod
+type ContainerCardRequest struct {
+	NumberOfCard   int
+	IntraBandWidth int
+	CardType       util.XPUTypeRequest
+	DeviceUUID     util.XPUUUIDRequest
 }
 
 type PodAllocation struct {
 	TaskId    api.TaskID
 	NodeName  string
 	DeviceIds []int
+	// ContainerDeviceIds device ids of each container request, DeviceIds is their concatenation
+	ContainerDeviceIds [][]int
+}
+
+// containerRequests the device groups the pod asks for
+func (r PodCardRequest) containerRequests() []ContainerCardRequest {
+	if len(r.Containers) != 0 {
+		return r.Containers
+	}
+	return []ContainerCardRequest{{NumberOfCard: r.NumberOfCard, IntraBandWidth: r.IntraBandWidth,
+		CardType: r.CardType, DeviceUUID: r.DeviceUUID}}
+}
+
+// cardNumber total number of cards of the pod
+func (r PodCardRequest) cardNumber() int {
+	number := 0
+	for _, container := range r.containerRequests() {
+		number += container.NumberOfCard
+	}
+	return number
 }
@@ -17,6 +17,7 @@ function clean() {
   rm -f "${BASE_PATH}"/output/vc-controller-manager
   rm -f "${BASE_PATH}"/output/vc-scheduler
   rm -f "${BASE_PATH}"/output/*.so
+  rm -f "${BASE_PATH}"/output/xpu-simulator
 }
 
 function build() {
@@ -46,6 +47,12 @@ function build() {
   -X volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin.PluginName=${PLUGIN_NAME}" \
   -o "${PLUGIN_NAME}".so "${GOPATH}"/src/volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/
 
+  CGO_CFLAGS="-fstack-protector-strong -D_FORTIFY_SOURCE=2 -O2 -fPIC -ftrapv" \
+  CGO_CPPFLAGS="-fstack-protector-strong -D_FORTIFY_SOURCE=2 -O2 -fPIC -ftrapv" \
+  CC=${GCC_PATH} CGO_ENABLED=1 \
+  go build -mod=mod -buildmode=pie -buildvcs=false -ldflags "-s -linkmode=external -extldflags=-Wl,-z,relro,-z,now" \
+  -o xpu-simulator "${BASE_PATH}"/cmd/xpu-simulator
+
   if [ ! -f "${BASE_PATH}/output/${PLUGIN_NAME}.so" ]
   then
     echo "Failed to find huawei-xpu.so"
@@ -53,7 +60,7 @@ function build() {
   fi
 
   chmod 400 "${BASE_PATH}"/output/*.so
-  chmod 500 vc-controller-manager vc-scheduler
+  chmod 500 vc-controller-manager vc-scheduler xpu-simulator
 }
 
 function main() {
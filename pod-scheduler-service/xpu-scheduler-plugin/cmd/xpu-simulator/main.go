@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package main
+// xpu-simulator schedules the pending pods of a cluster snapshot with the xpu scheduler plugin offline
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/framework"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/internal/xpu"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/simulator"
+)
+
+const defaultPluginName = "huawei-xpu"
+
+var (
+	schedulerConf  string
+	pluginName     string
+	output         string
+	checkHandshake bool
+)
+
+// schedulerConfiguration the part of the volcano scheduler configuration holding the plugin arguments
+type schedulerConfiguration struct {
+	Tiers []struct {
+		Plugins []struct {
+			Name      string                 `yaml:"name"`
+			Arguments map[string]interface{} `yaml:"arguments"`
+		} `yaml:"plugins"`
+	} `yaml:"tiers"`
+}
+
+func init() {
+	klog.InitFlags(nil)
+	flag.StringVar(&schedulerConf, "scheduler-conf", "",
+		"The volcano scheduler configuration file giving the plugin arguments, default arguments if empty")
+	flag.StringVar(&pluginName, "plugin-name", defaultPluginName,
+		"The name of the xpu plugin in the scheduler configuration")
+	flag.StringVar(&output, "output", simulator.OutputText, "The output format, range[text,json]")
+	flag.BoolVar(&checkHandshake, "check-handshake", false,
+		"Skip the nodes whose device plugin handshake is older than the tolerance, off for captured snapshots")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] <snapshot file or directory>...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+}
+
+// loadArguments get the arguments of the plugin from the scheduler configuration file
+func loadArguments(file string, name string) (framework.Arguments, error) {
+	args := framework.Arguments{}
+	if file == "" {
+		return args, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	conf := &schedulerConfiguration{}
+	if err := yaml.Unmarshal(data, conf); err != nil {
+		return nil, fmt.Errorf("decode %s failed: %v", file, err)
+	}
+	for _, tier := range conf.Tiers {
+		for _, plugin := range tier.Plugins {
+			if plugin.Name == name {
+				for key, value := range plugin.Arguments {
+					args[key] = value
+				}
+				return args, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("plugin %s is not in %s", name, file)
+}
+
+func run() error {
+	if flag.NArg() == 0 {
+		flag.Usage()
+		return fmt.Errorf("no snapshot given")
+	}
+	args, err := loadArguments(schedulerConf, pluginName)
+	if err != nil {
+		return err
+	}
+	snapshot, err := simulator.LoadSnapshot(flag.Args())
+	if err != nil {
+		return err
+	}
+	sh := xpu.NewScheduleHandler()
+	xpu.InitArguments(sh, args)
+	xpu.Config.TestEnable = !checkHandshake
+	result, err := simulator.Run(sh, snapshot)
+	if err != nil {
+		return err
+	}
+	return result.Print(os.Stdout, output)
+}
+
+func main() {
+	flag.Parse()
+	defer klog.Flush()
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
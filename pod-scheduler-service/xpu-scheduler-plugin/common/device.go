@@ -1,5 +1,7 @@
 package common
 
+import "fmt"
+
 type XPUDevice struct {
 	Index      int
 	Id         string
@@ -11,37 +13,91 @@ type XPUDevice struct {
 	Count      int
 	UsedCores  int
 	UsedMemory uint64
-	UsedVids   uint
+	UsedVids   VidBitmap
 	InUse      bool
 	Numa       int
+	// MemoryRatio memory oversubscription ratio registered by the device plugin, 0 is read as 1
+	MemoryRatio float64
+	// UsedQos number of the containers of each qos class placed on the device
+	UsedQos map[string]int
+}
+
+// MemoryCapacity the memory in MiB the vxpus on the device may be granted, Memory oversubscribed by MemoryRatio
+func (x *XPUDevice) MemoryCapacity() uint64 {
+	if x.MemoryRatio <= 1 {
+		return x.Memory
+	}
+	return uint64(float64(x.Memory) * x.MemoryRatio)
 }
 
 func (x *XPUDevice) GetVidBound() uint {
-	count := uint(0)
-	vids := x.UsedVids
-	for vids != 0 {
-		count += vids & 1
-		vids >>= 1
+	return x.UsedVids.Count()
+}
+
+// HasFreeVid check a vid below Count is free
+func (x *XPUDevice) HasFreeVid() bool {
+	return x.UsedVids.FirstFree() < uint(x.Count)
+}
+
+// AllocVid take the lowest free vid, false if all the Count vids of the device are used
+func (x *XPUDevice) AllocVid() (uint, bool) {
+	vid := x.UsedVids.FirstFree()
+	if vid >= uint(x.Count) {
+		return 0, false
 	}
-	return count
+	x.UsedVids.Set(vid)
+	return vid, true
 }
 
-func (x *XPUDevice) AllocVid() uint {
-	vid := uint(0)
-	vidBit := (x.UsedVids + 1) ^ x.UsedVids
-	x.UsedVids |= vidBit
-	for vidBit > 1 {
-		vid++
-		vidBit >>= 1
+// OccupyVid mark the vid of a placed container in use, the vid is kept even when it is beyond
+// Count or used twice so that it is not handed out again, an error reports the inconsistency
+func (x *XPUDevice) OccupyVid(vid uint) error {
+	var err error
+	if vid >= uint(x.Count) {
+		err = fmt.Errorf("vid %d is beyond the %d vids of device %s", vid, x.Count, x.Id)
+	} else if x.UsedVids.Has(vid) {
+		err = fmt.Errorf("vid %d of device %s is used twice", vid, x.Id)
 	}
-	return vid
+	x.UsedVids.Set(vid)
+	return err
+}
+
+func (x *XPUDevice) ReleaseVid(vid uint) {
+	x.UsedVids.Clear(vid)
 }
 
-func (x *XPUDevice) OccupyVid(vid uint) bool {
-	vidBit := uint(1) << vid
-	ok := x.UsedVids&vidBit != 0
-	x.UsedVids |= vidBit
-	return ok
+// AddQos count a container of the qos class placed on the device
+func (x *XPUDevice) AddQos(class string) {
+	if x.UsedQos == nil {
+		x.UsedQos = map[string]int{}
+	}
+	x.UsedQos[class]++
+}
+
+// RemoveQos uncount a container of the qos class released from the device
+func (x *XPUDevice) RemoveQos(class string) {
+	if x.UsedQos[class] <= 1 {
+		delete(x.UsedQos, class)
+		return
+	}
+	x.UsedQos[class]--
+}
+
+// HasQos check whether a container of the qos class is placed on the device
+func (x *XPUDevice) HasQos(class string) bool {
+	return x.UsedQos[class] > 0
+}
+
+func (x *XPUDevice) Clone() *XPUDevice {
+	clone := *x
+	clone.UsedVids = x.UsedVids.Clone()
+	if x.UsedQos != nil {
+		clone.UsedQos = make(map[string]int, len(x.UsedQos))
+		for class, count := range x.UsedQos {
+			clone.UsedQos[class] = count
+		}
+	}
+	return &clone
 }
 
 type ContainerDevice struct {
@@ -51,4 +107,5 @@ type ContainerDevice struct {
 	UsedMemory uint64
 	UsedCores  int
 	Vid        uint
+	Qos        string
 }
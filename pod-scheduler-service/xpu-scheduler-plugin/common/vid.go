@@ -0,0 +1,85 @@
+package common
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+const vidWordBits = 64
+
+// VidBitmap the vids in use on a device, one bit per vid, it grows with the highest vid set
+type VidBitmap []uint64
+
+// Has check the vid is in use
+func (b VidBitmap) Has(vid uint) bool {
+	word := vid / vidWordBits
+	return word < uint(len(b)) && b[word]&(uint64(1)<<(vid%vidWordBits)) != 0
+}
+
+// Set mark the vid in use
+func (b *VidBitmap) Set(vid uint) {
+	word := vid / vidWordBits
+	for uint(len(*b)) <= word {
+		*b = append(*b, 0)
+	}
+	(*b)[word] |= uint64(1) << (vid % vidWordBits)
+}
+
+// Clear mark the vid free
+func (b VidBitmap) Clear(vid uint) {
+	word := vid / vidWordBits
+	if word < uint(len(b)) {
+		b[word] &^= uint64(1) << (vid % vidWordBits)
+	}
+}
+
+// Count number of vids in use
+func (b VidBitmap) Count() uint {
+	count := 0
+	for _, word := range b {
+		count += bits.OnesCount64(word)
+	}
+	return uint(count)
+}
+
+// Empty check no vid is in use
+func (b VidBitmap) Empty() bool {
+	for _, word := range b {
+		if word != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// FirstFree the lowest vid not in use
+func (b VidBitmap) FirstFree() uint {
+	for i, word := range b {
+		if word != ^uint64(0) {
+			return uint(i)*vidWordBits + uint(bits.TrailingZeros64(^word))
+		}
+	}
+	return uint(len(b)) * vidWordBits
+}
+
+// Clone copy of the bitmap not sharing its words
+func (b VidBitmap) Clone() VidBitmap {
+	if b == nil {
+		return nil
+	}
+	return append(VidBitmap{}, b...)
+}
+
+// String the vids in use like "0,1,5"
+func (b VidBitmap) String() string {
+	var vids []string
+	for i, word := range b {
+		for word != 0 {
+			bit := bits.TrailingZeros64(word)
+			vids = append(vids, fmt.Sprint(uint(i)*vidWordBits+uint(bit)))
+			word &^= uint64(1) << bit
+		}
+	}
+	return strings.Join(vids, ",")
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package common
+
+import "testing"
+
+func TestVidBitmap(t *testing.T) {
+	tests := []struct {
+		name          string
+		set           []uint
+		clear         []uint
+		wantFirstFree uint
+		wantCount     uint
+		wantString    string
+	}{
+		{name: "empty", wantFirstFree: 0, wantCount: 0, wantString: ""},
+		{name: "first word partly used", set: []uint{0, 1, 3}, wantFirstFree: 2, wantCount: 3,
+			wantString: "0,1,3"},
+		{name: "first word full", set: vidRange(0, 64), wantFirstFree: 64, wantCount: 64},
+		{name: "last bit of the first word", set: []uint{63}, wantFirstFree: 0, wantCount: 1, wantString: "63"},
+		{name: "first bit of the second word", set: []uint{64}, wantFirstFree: 0, wantCount: 1, wantString: "64"},
+		{name: "free across the word boundary", set: vidRange(0, 65), clear: []uint{63}, wantFirstFree: 63,
+			wantCount: 64},
+		{name: "second word partly used", set: vidRange(0, 66), wantFirstFree: 66, wantCount: 66},
+		{name: "clear in the second word", set: vidRange(0, 70), clear: []uint{64}, wantFirstFree: 64,
+			wantCount: 69},
+		{name: "clear beyond the words", set: []uint{1}, clear: []uint{200}, wantFirstFree: 0, wantCount: 1,
+			wantString: "1"},
+		{name: "two words full", set: vidRange(0, 128), wantFirstFree: 128, wantCount: 128},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b VidBitmap
+			for _, vid := range tt.set {
+				b.Set(vid)
+			}
+			for _, vid := range tt.clear {
+				b.Clear(vid)
+			}
+			if got := b.FirstFree(); got != tt.wantFirstFree {
+				t.Errorf("FirstFree() = %d, want %d", got, tt.wantFirstFree)
+			}
+			if got := b.Count(); got != tt.wantCount {
+				t.Errorf("Count() = %d, want %d", got, tt.wantCount)
+			}
+			if got := b.Empty(); got != (tt.wantCount == 0) {
+				t.Errorf("Empty() = %v with %d vids in use", got, tt.wantCount)
+			}
+			if tt.wantString != "" || tt.wantCount == 0 {
+				if got := b.String(); got != tt.wantString {
+					t.Errorf("String() = %q, want %q", got, tt.wantString)
+				}
+			}
+			for _, vid := range tt.set {
+				if !b.Has(vid) && !contains(tt.clear, vid) {
+					t.Errorf("Has(%d) = false after Set", vid)
+				}
+			}
+			for _, vid := range tt.clear {
+				if b.Has(vid) {
+					t.Errorf("Has(%d) = true after Clear", vid)
+				}
+			}
+		})
+	}
+}
+
+func TestVidBitmapClone(t *testing.T) {
+	var b VidBitmap
+	b.Set(64)
+	clone := b.Clone()
+	clone.Set(0)
+	clone.Clear(64)
+	if !b.Has(64) || b.Has(0) {
+		t.Errorf("clone shares the words of the bitmap, bitmap %s", b)
+	}
+	if VidBitmap(nil).Clone() != nil {
+		t.Errorf("clone of a nil bitmap is not nil")
+	}
+}
+
+func TestAllocVid(t *testing.T) {
+	device := &XPUDevice{Id: "GPU-a", Count: 66}
+	for i := 0; i < device.Count; i++ {
+		vid, ok := device.AllocVid()
+		if !ok || vid != uint(i) {
+			t.Fatalf("AllocVid() = %d, %v, want %d, true", vid, ok, i)
+		}
+	}
+	if vid, ok := device.AllocVid(); ok {
+		t.Errorf("AllocVid() = %d beyond the %d vids of the device", vid, device.Count)
+	}
+	device.ReleaseVid(64)
+	if vid, ok := device.AllocVid(); !ok || vid != 64 {
+		t.Errorf("AllocVid() = %d, %v after release, want 64, true", vid, ok)
+	}
+}
+
+func vidRange(from uint, to uint) []uint {
+	vids := make([]uint, 0, to-from)
+	for vid := from; vid < to; vid++ {
+		vids = append(vids, vid)
+	}
+	return vids
+}
+
+func contains(vids []uint, vid uint) bool {
+	for _, v := range vids {
+		if v == vid {
+			return true
+		}
+	}
+	return false
+}
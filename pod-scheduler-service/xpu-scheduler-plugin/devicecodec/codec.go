@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+// Package devicecodec implements the encoding of the xpu device annotations written on nodes
+// and pods and the XPUNode resource, read by both the scheduler plugin and the device plugin.
+// The package only depends on the standard library, the same files are kept in both components, the tests
+// of the device plugin copy and the ci build fail when they drift.
+package devicecodec
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Version version of the annotations written by the encoders
+	Version = 2
+
+	legacyNodeDeviceFields      = 7
+	legacyContainerDeviceFields = 6
+	legacyDeviceSeparator       = ":"
+	legacyFieldSeparator        = ","
+	legacyContainerSeparator    = ";"
+)
+
+// NodeDevice xpu device registered on the node annotation. MemoryRatio is the memory oversubscription
+// ratio of the device, the vxpus on it may be granted up to Memory*MemoryRatio, 0 is read as 1
+type NodeDevice struct {
+	Index       int     `json:"index"`
+	Id          string  `json:"id"`
+	Count       int     `json:"count"`
+	Memory      uint64  `json:"memory"`
+	Type        string  `json:"type"`
+	Health      bool    `json:"health"`
+	Numa        int     `json:"numa"`
+	MemoryRatio float64 `json:"memoryRatio,omitempty"`
+}
+
+// MemoryCapacity the memory in MiB the vxpus on the device may be granted in total
+func (d NodeDevice) MemoryCapacity() uint64 {
+	if d.MemoryRatio <= 1 {
+		return d.Memory
+	}
+	return uint64(float64(d.Memory) * d.MemoryRatio)
+}
+
+// ContainerDevice xpu slice allocated to a container in the pod annotation. Qos is the qos class
+// of the container, empty in the annotations of older schedulers
+type ContainerDevice struct {
+	Index      int    `json:"index"`
+	UUID       string `json:"uuid"`
+	Type       string `json:"type"`
+	UsedMemory uint64 `json:"usedMemory"`
+	UsedCores  int    `json:"usedCores"`
+	Vid        int    `json:"vid"`
+	Qos        string `json:"qos,omitempty"`
+}
+
+// nodeDevices versioned node annotation
+type nodeDevices struct {
+	Version int          `json:"version"`
+	Devices []NodeDevice `json:"devices"`
+}
+
+// podDevices versioned pod annotation, one device list per container
+type podDevices struct {
+	Version    int                 `json:"version"`
+	Containers [][]ContainerDevice `json:"containers"`
+}
+
+// isVersioned the versioned annotations are json objects, the legacy ones start with a device index
+func isVersioned(str string) bool {
+	return strings.HasPrefix(strings.TrimSpace(str), "{")
+}
+
+// EncodeNodeDevices encode the xpu devices of a node to the versioned annotation
+func EncodeNodeDevices(devices []NodeDevice) (string, error) {
+	data, err := json.Marshal(nodeDevices{Version: Version, Devices: devices})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// DecodeNodeDevices decode the node annotation, both versioned and legacy
+// "index,id,count,memory,type,health,numa:" formats are accepted
+func DecodeNodeDevices(str string) ([]NodeDevice, error) {
+	if !isVersioned(str) {
+		return decodeLegacyNodeDevices(str)
+	}
+	var nd nodeDevices
+	if err := json.Unmarshal([]byte(str), &nd); err != nil {
+		return nil, fmt.Errorf("invalid node devices %s: %v", str, err)
+	}
+	if nd.Version <= 0 {
+		return nil, fmt.Errorf("invalid node devices version %d", nd.Version)
+	}
+	return nd.Devices, nil
+}
+
+func decodeLegacyNodeDevices(str string) ([]NodeDevice, error) {
+	if !strings.Contains(str, legacyDeviceSeparator) {
+		return nil, fmt.Errorf("invalid node devices %s", str)
+	}
+	var devices []NodeDevice
+	for _, val := range strings.Split(str, legacyDeviceSeparator) {
+		if !strings.Contains(val, legacyFieldSeparator) {
+			continue
+		}
+		items := strings.Split(val, legacyFieldSeparator)
+		if len(items) != legacyNodeDeviceFields {
+			return nil, fmt.Errorf("invalid node device %s", val)
+		}
+		index, err := strconv.Atoi(items[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid node device %s", val)
+		}
+		count, err := strconv.Atoi(items[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid node device %s", val)
+		}
+		memory, err := strconv.ParseUint(items[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid node device %s", val)
+		}
+		health, err := strconv.ParseBool(items[5])
+		if err != nil {
+			return nil, fmt.Errorf("invalid node device %s", val)
+		}
+		numa, err := strconv.Atoi(items[6])
+		if err != nil {
+			return nil, fmt.Errorf("invalid node device %s", val)
+		}
+		devices = append(devices, NodeDevice{Index: index, Id: items[1], Count: count, Memory: memory,
+			Type: items[4], Health: health, Numa: numa})
+	}
+	return devices, nil
+}
+
+// EncodePodDevices encode the xpu slices of the containers of a pod to the versioned annotation
+func EncodePodDevices(containers [][]ContainerDevice) (string, error) {
+	if containers == nil {
+		containers = [][]ContainerDevice{}
+	}
+	for i := range containers {
+		if containers[i] == nil {
+			containers[i] = []ContainerDevice{}
+		}
+	}
+	data, err := json.Marshal(podDevices{Version: Version, Containers: containers})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// DecodePodDevices decode the pod annotation, both versioned and legacy
+// "index,uuid,type,memory,cores,vid:" formats are accepted, legacy containers are separated by ";"
+func DecodePodDevices(str string) ([][]ContainerDevice, error) {
+	if len(strings.TrimSpace(str)) == 0 {
+		return [][]ContainerDevice{}, nil
+	}
+	if !isVersioned(str) {
+		return decodeLegacyPodDevices(str)
+	}
+	var pd podDevices
+	if err := json.Unmarshal([]byte(str), &pd); err != nil {
+		return nil, fmt.Errorf("invalid pod devices %s: %v", str, err)
+	}
+	if pd.Version <= 0 {
+		return nil, fmt.Errorf("invalid pod devices version %d", pd.Version)
+	}
+	return pd.Containers, nil
+}
+
+func decodeLegacyPodDevices(str string) ([][]ContainerDevice, error) {
+	// older device plugins joined the containers with "," instead of ";"
+	str = strings.ReplaceAll(str, legacyDeviceSeparator+legacyFieldSeparator,
+		legacyDeviceSeparator+legacyContainerSeparator)
+	var containers [][]ContainerDevice
+	for _, s := range strings.Split(str, legacyContainerSeparator) {
+		devices, err := decodeLegacyContainerDevices(s)
+		if err != nil {
+			return nil, err
+		}
+		containers = append(containers, devices)
+	}
+	return containers, nil
+}
+
+func decodeLegacyContainerDevices(str string) ([]ContainerDevice, error) {
+	devices := []ContainerDevice{}
+	for _, val := range strings.Split(str, legacyDeviceSeparator) {
+		if !strings.Contains(val, legacyFieldSeparator) {
+			continue
+		}
+		fields := strings.Split(val, legacyFieldSeparator)
+		if len(fields) != legacyContainerDeviceFields {
+			return nil, fmt.Errorf("invalid container device %s", val)
+		}
+		index, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid container device %s", val)
+		}
+		memory, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid container device %s", val)
+		}
+		cores, err := strconv.Atoi(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("invalid container device %s", val)
+		}
+		vid, err := strconv.Atoi(fields[5])
+		if err != nil {
+			return nil, fmt.Errorf("invalid container device %s", val)
+		}
+		devices = append(devices, ContainerDevice{Index: index, UUID: fields[1], Type: fields[2],
+			UsedMemory: memory, UsedCores: cores, Vid: vid})
+	}
+	return devices, nil
+}
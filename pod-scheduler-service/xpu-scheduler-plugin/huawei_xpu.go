@@ -5,32 +5,20 @@
 package main
 
 import (
-	"errors"
-	"strings"
 	"sync"
 
 	"k8s.io/klog/v2"
 	"volcano.sh/volcano/pkg/scheduler/api"
 	"volcano.sh/volcano/pkg/scheduler/framework"
-	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/internal/xpu"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/plugin"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+	vcutil "volcano.sh/volcano/pkg/scheduler/util"
 )
 
 const (
 	// PluginName huawei xpu plugin name, it must be the same as the so package name
 	PluginName = "huawei-xpu"
-	// TopologyEnable topology setting
-	TopologyEnable = "TopologyEnable"
-	// NumaEnable numa setting
-	NumaEnable = "NumaEnable"
-	// TestEnable test mode setting
-	TestEnable = "TestEnable"
-	// XPUTopologyNodeList node list setting
-	XPUTopologyNodeList = "XPUTopologyNodeList"
-	// XPUTopologyNodeBandwidth bandwidth setting between nodes
-	XPUTopologyNodeBandwidth = "XPUTopologyNodeBandwidth"
 )
 
 var (
@@ -62,56 +50,6 @@ func New(arguments framework.Arguments) framework.Plugin {
 	return &huaweiXPUPlugin{Scheduler: GetScheduleHandler(), Arguments: arguments}
 }
 
-func getCommonConfig(args framework.Arguments) {
-	args.GetBool(&xpu.Config.TopologyEnable, TopologyEnable)
-	args.GetBool(&xpu.Config.NumaEnable, NumaEnable)
-	args.GetBool(&xpu.Config.TestEnable, TestEnable)
-}
-
-func getNodeBandwidthConf(args framework.Arguments) {
-	argv, ok := args[XPUTopologyNodeList]
-	if !ok {
-		return
-	}
-	value, ok := argv.(string)
-	if !ok {
-		klog.V(util.LogErrorLevel).Infof("XPUTopologyNodeList in args is not string")
-		return
-	}
-	tmp := strings.Split(value, util.Comma)
-	topologyNodeList := tmp
-
-	err := getNodeBandwidth(args, topologyNodeList)
-	if err != nil {
-		klog.V(util.LogErrorLevel).Infof("get node bandwidth failed, err: %v", err.Error())
-		util.XPUTopologyNodeBandwidth = nil
-	}
-	return
-}
-
-func getNodeBandwidth(args framework.Arguments, topologyNodeList []string) error {
-	argv, ok := args[XPUTopologyNodeBandwidth]
-	if !ok {
-		return errors.New("XPUTopologyNodeBandwidth not exist")
-	}
-	value, ok := argv.(string)
-	if !ok {
-		return errors.New("XPUTopologyNodeBandwidth is not string")
-	}
-	matrix := strings.Split(value, util.Semicolon)
-	if len(matrix) != len(topologyNodeList) {
-		return errors.New("length of node bandwidth matrix is different from length of node list")
-	}
-	nodeBandWidth, err := util.ConvertMatrix2Map(matrix, topologyNodeList)
-	if err != nil {
-		util.XPUTopologyNodeBandwidth = nil
-		return err
-	}
-	util.XPUTopologyNodeBandwidth = nodeBandWidth
-	klog.V(util.LogInfoLevel).Infof("XPUTopologyNodeBandwidth: +%v", util.XPUTopologyNodeBandwidth)
-	return nil
-}
-
 func addJobValidFn(ssn *framework.Session, xp *huaweiXPUPlugin) {
 	// check job npu resource, if illegal return failed
 	ssn.AddJobValidFn(xp.Name(), func(obj interface{}) *api.ValidateResult {
@@ -123,10 +61,8 @@ func addPredicateFn(ssn *framework.Session, xp *huaweiXPUPlugin) {
 	// if node not meet the task require, the task will be failed. so need to intercept in advance
 	ssn.AddPredicateFn(xp.Name(), func(taskInfo *api.TaskInfo, nodeInfo *api.NodeInfo) error {
 		err := xp.Scheduler.NodePredicate(taskInfo, nodeInfo)
-		if err != nil {
-			xp.Scheduler.Jobs[taskInfo.Job].Lock()
-			xp.Scheduler.Jobs[taskInfo.Job].Reason[err.Error()] += nodeInfo.Name + " "
-			xp.Scheduler.Jobs[taskInfo.Job].Unlock()
+		if sJob, ok := xp.Scheduler.Jobs[taskInfo.Job]; ok && err != nil {
+			sJob.AddNodeFailure(nodeInfo.Name, err)
 		}
 		return err
 	})
@@ -166,7 +102,7 @@ func addEventHandler(ssn *framework.Session, xp *huaweiXPUPlugin) {
 				klog.V(util.LogErrorLevel).Infof("DeallocateFunc event nil.")
 				return
 			}
-			xp.Scheduler.XPUDeallocateFunc(event.Task)
+			xp.Scheduler.XPUDeallocateFunc(event.Task, ssn)
 		},
 	})
 }
@@ -182,7 +118,37 @@ func addJobReadyFn(ssn *framework.Session, xp *huaweiXPUPlugin) {
 		if !ok {
 			return true
 		}
-		return job.JobReadyTag
+		return job.JobReadyTag && job.IsJobReady(ji)
+	})
+}
+
+func addJobEnqueueableFn(ssn *framework.Session, xp *huaweiXPUPlugin) {
+	// keep the job out of the queue while its pending tasks go beyond the xpu quota
+	ssn.AddJobEnqueueableFn(xp.Name(), func(obj interface{}) int {
+		job, ok := obj.(*api.JobInfo)
+		if !ok {
+			klog.V(util.LogErrorLevel).Info("obj assertion failed.")
+			return vcutil.Abstain
+		}
+		if err := xp.Scheduler.JobEnqueueable(job); err != nil {
+			klog.V(util.LogInfoLevel).Infof("job %s/%s is not enqueueable: %v", job.Namespace, job.Name, err)
+			return vcutil.Reject
+		}
+		return vcutil.Abstain
+	})
+}
+
+func addPreemptableFn(ssn *framework.Session, xp *huaweiXPUPlugin) {
+	// select the victims whose released xpu slices make the preemptor fit
+	ssn.AddPreemptableFn(xp.Name(), func(preemptor *api.TaskInfo, preemptees []*api.TaskInfo) ([]*api.TaskInfo, int) {
+		return xp.Scheduler.XPUVictimsFn(preemptor, preemptees)
+	})
+}
+
+func addReclaimableFn(ssn *framework.Session, xp *huaweiXPUPlugin) {
+	// reclaim takes back xpu slices the same way as preemption, but across queues
+	ssn.AddReclaimableFn(xp.Name(), func(reclaimer *api.TaskInfo, reclaimees []*api.TaskInfo) ([]*api.TaskInfo, int) {
+		return xp.Scheduler.XPUVictimsFn(reclaimer, reclaimees)
 	})
 }
 
@@ -193,8 +159,7 @@ func (xp *huaweiXPUPlugin) OnSessionOpen(ssn *framework.Session) {
 		klog.V(util.LogErrorLevel).Infof("OnSessionOpen: %s.", util.ArgumentError)
 		return
 	}
-	getCommonConfig(xp.Arguments)
-	getNodeBandwidthConf(xp.Arguments)
+	xpu.InitArguments(xp.Scheduler, xp.Arguments)
 	// Init xpu plugin and nodes.
 	if err := xp.Scheduler.InitXPUSession(ssn); err != nil {
 		klog.V(util.LogErrorLevel).Infof("InitXPUSession: %s, xpu plugin will not be initialized.", err)
@@ -206,6 +171,9 @@ func (xp *huaweiXPUPlugin) OnSessionOpen(ssn *framework.Session) {
 	addBatchNodeOrderFn(ssn, xp)
 	addEventHandler(ssn, xp)
 	addJobReadyFn(ssn, xp)
+	addJobEnqueueableFn(ssn, xp)
+	addPreemptableFn(ssn, xp)
+	addReclaimableFn(ssn, xp)
 }
 
 func (xp *huaweiXPUPlugin) OnSessionClose(ssn *framework.Session) {
@@ -227,6 +195,7 @@ func (xp *huaweiXPUPlugin) OnSessionClose(ssn *framework.Session) {
 			job.PodGroup.Status.Phase == util.PodGroupPending {
 			// if all nodes not meet job require failed
 			xp.Scheduler.SetJobPendingReasonByNodesCase(job)
+			xp.Scheduler.RecordUnschedulableReason(ssn, job)
 		}
 		if len(job.PodGroup.Annotations) != 0 && job.PodGroup.Annotations[util.PodDeleteTimes] == util.TagOfPodPending {
 			xp.Scheduler.UpdatePodGroupPendingReason(job, util.JobRestartReason)
@@ -239,18 +208,7 @@ func (xp *huaweiXPUPlugin) OnSessionClose(ssn *framework.Session) {
 
 // HandlerCreate Huawei XPU scheduler plugin start by frame.
 func HandlerCreate() *plugin.ScheduleHandler {
-	sh := &plugin.ScheduleHandler{
-		XPUPlugins:     map[string]plugin.XPUBuilder{},
-		XPUDevices:     map[string]map[int]*common.XPUDevice{},
-		Jobs:           map[api.JobID]*plugin.SchedulerJob{},
-		DeleteJobInfos: map[api.JobID]*api.JobInfo{},
-		SessionID:      "",
-		Mutex:          &sync.Mutex{},
-	}
-
-	// Register new xpu scheduler strategy.
-	sh.RegisterXPUScheduler(util.GPUPluginName, xpu.GetGPUPlugin)
-	sh.RegisterXPUScheduler(util.NPUPluginName, xpu.GetNPUPlugin)
+	sh := xpu.NewScheduleHandler()
 	klog.V(util.LogDebugLevel).Infof("HandlerCreate %#v.", sh.XPUPlugins)
 	return sh
 }
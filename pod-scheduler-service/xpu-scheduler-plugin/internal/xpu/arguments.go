@@ -0,0 +1,236 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+package xpu
+
+import (
+	"errors"
+	"strings"
+
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/framework"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/allocator"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/plugin"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const (
+	// TopologyEnable topology setting
+	TopologyEnable = "TopologyEnable"
+	// NumaEnable numa setting
+	NumaEnable = "NumaEnable"
+	// VxpuNumaPolicy NUMA alignment of the devices of a vxpu container, none, preferred or strict
+	VxpuNumaPolicy = "VxpuNumaPolicy"
+	// VxpuQosIsolation pairs of qos classes whose vxpu containers may not share a card,
+	// for example "guaranteed:best-effort,burstable:best-effort"
+	VxpuQosIsolation = "VxpuQosIsolation"
+	// TestEnable test mode setting
+	TestEnable = "TestEnable"
+	// XPUTopologyNodeList node list setting
+	XPUTopologyNodeList = "XPUTopologyNodeList"
+	// XPUTopologyNodeBandwidth bandwidth setting between nodes
+	XPUTopologyNodeBandwidth = "XPUTopologyNodeBandwidth"
+	// XPUTopologyNodeBandwidthLevels bandwidth between nodes sharing a node label value, closest level first,
+	// for example "example.com/switch=400,example.com/rack=200,example.com/spine=100"
+	XPUTopologyNodeBandwidthLevels = "XPUTopologyNodeBandwidthLevels"
+	// TopologySearchMaxIterations maximum placements tried by one topology allocation
+	TopologySearchMaxIterations = "TopologySearchMaxIterations"
+	// TopologySearchTimeout time budget of one topology allocation in milliseconds
+	TopologySearchTimeout = "TopologySearchTimeout"
+	// TopologyNumaWeight weight of the NUMA violations in the topology objective
+	TopologyNumaWeight = "TopologyNumaWeight"
+	// TopologyIntraBandwidthWeight weight of the average bandwidth inside a pod in the topology objective
+	TopologyIntraBandwidthWeight = "TopologyIntraBandwidthWeight"
+	// TopologyMinIntraBandwidthWeight weight of the lowest bandwidth inside a pod in the topology objective
+	TopologyMinIntraBandwidthWeight = "TopologyMinIntraBandwidthWeight"
+	// TopologyNodeCountWeight weight of the number of nodes used in the topology objective
+	TopologyNodeCountWeight = "TopologyNodeCountWeight"
+	// TopologyInterBandwidthWeight weight of the bandwidth between nodes in the topology objective
+	TopologyInterBandwidthWeight = "TopologyInterBandwidthWeight"
+	// TopologyFragmentationWeight weight of the devices fragmentation in the topology objective
+	TopologyFragmentationWeight = "TopologyFragmentationWeight"
+	// TopologyCardTypeWeight weight of the less preferred card types in the topology objective
+	TopologyCardTypeWeight = "TopologyCardTypeWeight"
+	// XPUQueueQuota xpu quotas of queues, for example "queue1:cores=400,memory=80,cards=2;queue2:cores=100"
+	XPUQueueQuota = "XPUQueueQuota"
+	// XPUNamespaceQuota xpu quotas of namespaces, in the same format as XPUQueueQuota
+	XPUNamespaceQuota = "XPUNamespaceQuota"
+	// XPUScoreStrategy score strategy setting, binpack, spread or least-fragmentation
+	XPUScoreStrategy = "XPUScoreStrategy"
+	// XPUNodeSource where the device inventory of the nodes is read, crd (default) or annotation
+	XPUNodeSource = "XPUNodeSource"
+	// XPUReservationEnable reserve devices for the starving topology job of the highest priority then the oldest
+	XPUReservationEnable = "XPUReservationEnable"
+	// XPUReservationTimeout seconds the devices stay reserved for a starving topology job
+	XPUReservationTimeout = "XPUReservationTimeout"
+)
+
+// InitArguments apply the plugin arguments of the scheduler configuration to the plugins and the handler
+func InitArguments(sh *plugin.ScheduleHandler, args framework.Arguments) {
+	getCommonConfig(args)
+	getNodeBandwidthConf(args)
+	getQuotaConf(sh, args)
+	getXPUNodeConf(sh, args)
+}
+
+func getCommonConfig(args framework.Arguments) {
+	args.GetBool(&Config.TopologyEnable, TopologyEnable)
+	args.GetBool(&Config.NumaEnable, NumaEnable)
+	Config.VxpuNumaPolicy = plugin.NumaPolicyNone
+	if Config.NumaEnable && Config.TopologyEnable {
+		Config.VxpuNumaPolicy = plugin.NumaPolicyPreferred
+	}
+	if policy, ok := args[VxpuNumaPolicy].(string); ok {
+		if plugin.IsNumaPolicy(policy) {
+			Config.VxpuNumaPolicy = policy
+		} else {
+			klog.V(util.LogWarningLevel).Infof("unknown %s %s, use %s", VxpuNumaPolicy, policy, Config.VxpuNumaPolicy)
+		}
+	}
+	Config.VxpuQosIsolation = getQosIsolation(args)
+	args.GetBool(&Config.TestEnable, TestEnable)
+	args.GetInt(&Config.TopologySearchMaxIterations, TopologySearchMaxIterations)
+	args.GetInt(&Config.TopologySearchTimeout, TopologySearchTimeout)
+	Config.TopologyWeights = allocator.DefaultWeights
+	args.GetFloat64(&Config.TopologyWeights.Numa, TopologyNumaWeight)
+	args.GetFloat64(&Config.TopologyWeights.IntraBandwidth, TopologyIntraBandwidthWeight)
+	args.GetFloat64(&Config.TopologyWeights.MinIntraBandwidth, TopologyMinIntraBandwidthWeight)
+	args.GetFloat64(&Config.TopologyWeights.NodeCount, TopologyNodeCountWeight)
+	args.GetFloat64(&Config.TopologyWeights.InterBandwidth, TopologyInterBandwidthWeight)
+	args.GetFloat64(&Config.TopologyWeights.Fragmentation, TopologyFragmentationWeight)
+	args.GetFloat64(&Config.TopologyWeights.CardType, TopologyCardTypeWeight)
+	args.GetBool(&Config.ReservationEnable, XPUReservationEnable)
+	Config.ReservationTimeout = plugin.DefaultReservationTimeout
+	args.GetInt(&Config.ReservationTimeout, XPUReservationTimeout)
+	if Config.ReservationTimeout <= 0 {
+		klog.V(util.LogWarningLevel).Infof("%s %d is invalid, use %d", XPUReservationTimeout,
+			Config.ReservationTimeout, plugin.DefaultReservationTimeout)
+		Config.ReservationTimeout = plugin.DefaultReservationTimeout
+	}
+	Config.ScoreStrategy = plugin.DefaultScoreStrategy
+	if strategy, ok := args[XPUScoreStrategy].(string); ok {
+		Config.ScoreStrategy = strategy
+	}
+}
+
+func getQosIsolation(args framework.Arguments) plugin.QosIsolation {
+	argv, ok := args[VxpuQosIsolation]
+	if !ok {
+		return nil
+	}
+	value, ok := argv.(string)
+	if !ok {
+		klog.V(util.LogErrorLevel).Infof("VxpuQosIsolation in args is not string")
+		return nil
+	}
+	isolation, err := plugin.ParseQosIsolation(value)
+	if err != nil {
+		klog.V(util.LogErrorLevel).Infof("get qos isolation failed, err: %v", err.Error())
+		return nil
+	}
+	klog.V(util.LogInfoLevel).Infof("VxpuQosIsolation: %+v", isolation)
+	return isolation
+}
+
+func getXPUNodeConf(sh *plugin.ScheduleHandler, args framework.Arguments) {
+	sh.XPUNodeSource = plugin.XPUNodeSourceCRD
+	source, ok := args[XPUNodeSource].(string)
+	if !ok {
+		return
+	}
+	if source != plugin.XPUNodeSourceCRD && source != plugin.XPUNodeSourceAnnotation {
+		klog.V(util.LogErrorLevel).Infof("XPUNodeSource %s is invalid, use %s", source, plugin.XPUNodeSourceCRD)
+		return
+	}
+	sh.XPUNodeSource = source
+}
+
+func getNodeBandwidthConf(args framework.Arguments) {
+	getNodeBandwidthLevels(args)
+	argv, ok := args[XPUTopologyNodeList]
+	if !ok {
+		return
+	}
+	value, ok := argv.(string)
+	if !ok {
+		klog.V(util.LogErrorLevel).Infof("XPUTopologyNodeList in args is not string")
+		return
+	}
+	tmp := strings.Split(value, util.Comma)
+	topologyNodeList := tmp
+
+	err := getNodeBandwidth(args, topologyNodeList)
+	if err != nil {
+		klog.V(util.LogErrorLevel).Infof("get node bandwidth failed, err: %v", err.Error())
+		util.XPUTopologyNodeBandwidth = nil
+	}
+	return
+}
+
+func getNodeBandwidthLevels(args framework.Arguments) {
+	util.XPUTopologyNodeBandwidthLevels = nil
+	argv, ok := args[XPUTopologyNodeBandwidthLevels]
+	if !ok {
+		return
+	}
+	value, ok := argv.(string)
+	if !ok {
+		klog.V(util.LogErrorLevel).Infof("XPUTopologyNodeBandwidthLevels in args is not string")
+		return
+	}
+	levels, err := util.ParseNodeBandwidthLevels(value)
+	if err != nil {
+		klog.V(util.LogErrorLevel).Infof("get node bandwidth levels failed, err: %v", err.Error())
+		return
+	}
+	util.XPUTopologyNodeBandwidthLevels = levels
+	klog.V(util.LogInfoLevel).Infof("XPUTopologyNodeBandwidthLevels: %+v", levels)
+}
+
+func getQuotaConf(sh *plugin.ScheduleHandler, args framework.Arguments) {
+	sh.QueueQuotas = getQuotas(args, XPUQueueQuota)
+	sh.NamespaceQuotas = getQuotas(args, XPUNamespaceQuota)
+}
+
+func getQuotas(args framework.Arguments, key string) map[string]plugin.XPUResource {
+	argv, ok := args[key]
+	if !ok {
+		return nil
+	}
+	value, ok := argv.(string)
+	if !ok {
+		klog.V(util.LogErrorLevel).Infof("%s in args is not string", key)
+		return nil
+	}
+	quotas, err := plugin.ParseXPUQuotas(value)
+	if err != nil {
+		klog.V(util.LogErrorLevel).Infof("get %s failed, err: %v", key, err.Error())
+		return nil
+	}
+	klog.V(util.LogInfoLevel).Infof("%s: %+v", key, quotas)
+	return quotas
+}
+
+func getNodeBandwidth(args framework.Arguments, topologyNodeList []string) error {
+	argv, ok := args[XPUTopologyNodeBandwidth]
+	if !ok {
+		return errors.New("XPUTopologyNodeBandwidth not exist")
+	}
+	value, ok := argv.(string)
+	if !ok {
+		return errors.New("XPUTopologyNodeBandwidth is not string")
+	}
+	matrix := strings.Split(value, util.Semicolon)
+	if len(matrix) != len(topologyNodeList) {
+		return errors.New("length of node bandwidth matrix is different from length of node list")
+	}
+	nodeBandWidth, err := util.ConvertMatrix2Map(matrix, topologyNodeList)
+	if err != nil {
+		util.XPUTopologyNodeBandwidth = nil
+		return err
+	}
+	util.XPUTopologyNodeBandwidth = nodeBandWidth
+	klog.V(util.LogInfoLevel).Infof("XPUTopologyNodeBandwidth: +%v", util.XPUTopologyNodeBandwidth)
+	return nil
+}
@@ -1,6 +1,13 @@
 package xpu
 
-import "volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/plugin"
+import (
+	"sync"
+
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/plugin"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
 
 func GetGPUPlugin() plugin.XPUSchedulerPlugin {
 	return gpuPlugin
@@ -9,3 +16,20 @@ func GetGPUPlugin() plugin.XPUSchedulerPlugin {
 func GetNPUPlugin() plugin.XPUSchedulerPlugin {
 	return npuPlugin
 }
+
+// NewScheduleHandler create a ScheduleHandler with the gpu and npu plugins registered
+func NewScheduleHandler() *plugin.ScheduleHandler {
+	sh := &plugin.ScheduleHandler{
+		XPUPlugins:     map[string]plugin.XPUBuilder{},
+		XPUDevices:     map[string]map[string]map[int]*common.XPUDevice{},
+		Jobs:           map[api.JobID]*plugin.SchedulerJob{},
+		DeleteJobInfos: map[api.JobID]*api.JobInfo{},
+		SessionID:      "",
+		Mutex:          &sync.Mutex{},
+	}
+
+	// Register new xpu scheduler strategy.
+	sh.RegisterXPUScheduler(util.GPUPluginName, GetGPUPlugin)
+	sh.RegisterXPUScheduler(util.NPUPluginName, GetNPUPlugin)
+	return sh
+}
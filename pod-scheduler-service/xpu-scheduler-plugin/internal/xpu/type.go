@@ -12,6 +12,7 @@ var (
 		VxpuType:                   util.VGPUType,
 		VxpuCore:                   util.VGPUCore,
 		VxpuMemory:                 util.VGPUMemory,
+		VxpuMemoryMiB:              util.VGPUMemoryMiB,
 		Config:                     Config,
 		NodeXPURegisterAnno:        util.NodeGPURegisterAnnotation,
 		AssignedXPUsToAllocateAnno: util.AssignedGPUsToAllocateAnnotations,
@@ -19,6 +20,7 @@ var (
 		AssignedXPUsToPodAnno:      util.AssignedGPUsToPodAnnotations,
 		NodeXPUTopologyAnno:        util.NodeGPUTopologyAnnotation,
 		NodeXPUHandshakeAnno:       util.NodeGPUHandshakeAnnotation,
+		XPUNodeDeviceType:          util.NvidiaGPUDevice,
 	}
 
 	npuPlugin = &plugin.SchedulerPlugin{
@@ -27,6 +29,7 @@ var (
 		VxpuType:                   util.VNPUType,
 		VxpuCore:                   util.VNPUCore,
 		VxpuMemory:                 util.VNPUMemory,
+		VxpuMemoryMiB:              util.VNPUMemoryMiB,
 		Config:                     Config,
 		NodeXPURegisterAnno:        util.NodeNPURegisterAnnotation,
 		AssignedXPUsToAllocateAnno: util.AssignedNPUsToAllocateAnnotations,
@@ -34,6 +37,7 @@ var (
 		AssignedXPUsToPodAnno:      util.AssignedNPUsToPodAnnotations,
 		NodeXPUTopologyAnno:        util.NodeNPUTopologyAnnotation,
 		NodeXPUHandshakeAnno:       util.NodeNPUHandshakeAnnotation,
+		XPUNodeDeviceType:          util.AscendNPUDevice,
 	}
 
 	Config = &plugin.CommonConfig{}
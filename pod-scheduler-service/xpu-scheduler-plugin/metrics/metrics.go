@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package metrics the prometheus metrics of the xpu scheduler plugin, registered with the default registry of
+// volcano so they are served on the /metrics endpoint of the scheduler
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"volcano.sh/volcano/pkg/scheduler/metrics"
+)
+
+const (
+	// ResultSuccess result label of a successful allocation
+	ResultSuccess = "success"
+	// ResultFailure result label of a failed allocation
+	ResultFailure = "failure"
+)
+
+var (
+	predicateFailures = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: metrics.VolcanoNamespace,
+			Name:      "xpu_predicate_failures_total",
+			Help:      "Number of nodes failing the xpu predicate of a task, by plugin and reason",
+		}, []string{"plugin", "reason"},
+	)
+
+	topologyAllocateLatency = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: metrics.VolcanoNamespace,
+			Name:      "xpu_topology_allocate_latency_milliseconds",
+			Help:      "Time taken by the topology allocator to place the tasks of a job in milliseconds",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+		}, []string{"plugin", "result"},
+	)
+
+	topologyAllocateCandidates = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Subsystem: metrics.VolcanoNamespace,
+			Name:      "xpu_topology_allocate_candidate_nodes",
+			Help:      "Number of candidate nodes given to the topology allocator",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 12),
+		}, []string{"plugin"},
+	)
+
+	allocations = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Subsystem: metrics.VolcanoNamespace,
+			Name:      "xpu_allocations_total",
+			Help:      "Number of xpu allocations of tasks, by plugin and result",
+		}, []string{"plugin", "result"},
+	)
+
+	nodeFreeCores = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: metrics.VolcanoNamespace,
+			Name:      "xpu_node_free_cores",
+			Help:      "Free cores in percent of a card summed over the healthy xpu devices of the node",
+		}, []string{"plugin", "node"},
+	)
+
+	nodeFreeMemory = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: metrics.VolcanoNamespace,
+			Name:      "xpu_node_free_memory_mib",
+			Help:      "Free memory in MiB summed over the healthy xpu devices of the node",
+		}, []string{"plugin", "node"},
+	)
+
+	nodeFreeVids = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Subsystem: metrics.VolcanoNamespace,
+			Name:      "xpu_node_free_vids",
+			Help:      "Free vid slots summed over the healthy xpu devices of the node",
+		}, []string{"plugin", "node"},
+	)
+)
+
+func resultOf(success bool) string {
+	if success {
+		return ResultSuccess
+	}
+	return ResultFailure
+}
+
+// RecordPredicateFailure count a node failing the predicate of a task for the reason
+func RecordPredicateFailure(plugin string, reason string) {
+	predicateFailures.WithLabelValues(plugin, reason).Inc()
+}
+
+// UpdateTopologyAllocate record the duration of a topology allocation and the number of its candidate nodes
+func UpdateTopologyAllocate(plugin string, candidates int, duration time.Duration, success bool) {
+	topologyAllocateLatency.WithLabelValues(plugin, resultOf(success)).Observe(
+		float64(duration) / float64(time.Millisecond))
+	topologyAllocateCandidates.WithLabelValues(plugin).Observe(float64(candidates))
+}
+
+// RecordAllocation count the xpu allocation of a task
+func RecordAllocation(plugin string, success bool) {
+	allocations.WithLabelValues(plugin, resultOf(success)).Inc()
+}
+
+// UpdateNodeFreeResources set the free resources of the node as seen by the scheduler
+func UpdateNodeFreeResources(plugin string, node string, cores int, memory uint64, vids int) {
+	nodeFreeCores.WithLabelValues(plugin, node).Set(float64(cores))
+	nodeFreeMemory.WithLabelValues(plugin, node).Set(float64(memory))
+	nodeFreeVids.WithLabelValues(plugin, node).Set(float64(vids))
+}
+
+// ResetNodeFreeResources drop the free resources of all nodes, so the nodes gone are not reported any more
+func ResetNodeFreeResources() {
+	nodeFreeCores.Reset()
+	nodeFreeMemory.Reset()
+	nodeFreeVids.Reset()
+}
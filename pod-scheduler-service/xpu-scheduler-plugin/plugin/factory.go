@@ -3,6 +3,7 @@ package plugin
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"k8s.io/api/core/v1"
@@ -54,6 +55,16 @@ func (sh *ScheduleHandler) IsPluginRegistered(name string) bool {
 	return false
 }
 
+// sortedPluginNames the names of the registered xpu plugins in order
+func (sh *ScheduleHandler) sortedPluginNames() []string {
+	names := make([]string, 0, len(sh.XPUPlugins))
+	for name := range sh.XPUPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (sh *ScheduleHandler) InitXPUSession(ssn *framework.Session) error {
 	if sh == nil || ssn == nil {
 		return errors.New(util.ArgumentError)
@@ -62,10 +73,16 @@ func (sh *ScheduleHandler) InitXPUSession(ssn *framework.Session) error {
 		return err
 	}
 
+	sh.RefreshXPUNodes(ssn.KubeClient())
 	sh.InitJobsFromSession(ssn)
+	sh.RefreshReservations()
+	sh.InitQuotaUsage(ssn.Jobs)
 	sh.InitDeleteJobInfos()
 	sh.SessionID = ssn.UID
 	sh.Nodes = ssn.NodeList
+	initNodeBandwidth(sh.Nodes)
+	sh.ResizeContainers(ssn.KubeClient())
+	sh.UpdateNodeMetrics()
 	return nil
 }
 
@@ -76,9 +93,10 @@ func (sh *ScheduleHandler) InitDeleteJobInfos() {
 	sh.DeleteJobInfos = map[api.JobID]*api.JobInfo{}
 }
 
-func (sh *ScheduleHandler) getXPUDevicesOfNode(nodeName string) map[int]*common.XPUDevice {
+// getXPUDevicesOfNode the cached devices of the node for the plugin
+func (sh *ScheduleHandler) getXPUDevicesOfNode(pluginName string, nodeName string) map[int]*common.XPUDevice {
 	sh.Lock()
-	xpuDevices, ok := sh.XPUDevices[nodeName]
+	xpuDevices, ok := sh.XPUDevices[pluginName][nodeName]
 	sh.Unlock()
 	if !ok {
 		xpuDevices = map[int]*common.XPUDevice{}
@@ -121,12 +139,12 @@ func (sh *ScheduleHandler) BatchNodeOrderFn(task *api.TaskInfo, nodes []*api.Nod
 	return xpuTask.ScoreMap, nil
 }
 
-func (sh *ScheduleHandler) GetAllocatableXPUDeviceOnNodes(sJob *SchedulerJob) map[string][]*common.XPUDevice {
-	inUseDevicesOfTopology := GetXPUDevicesFromTopologyScheduleResult(sh.Jobs)
+func (sh *ScheduleHandler) GetAllocatableXPUDeviceOnNodes(handler XPUSchedulerPlugin) map[string][]*common.XPUDevice {
+	inUseDevicesOfTopology := GetXPUDevicesFromTopologyScheduleResult(sh.Jobs, handler.GetPluginName())
 	unUseXPUDeviceOfNodes := make(map[string][]*common.XPUDevice)
 	for _, v := range sh.Nodes {
-		sh.initXPUDevicesOfNode(sJob, v)
-		xpuDevices := sh.getXPUDevicesOfNode(v.Name)
+		sh.initXPUDevicesOfNode(handler, v)
+		xpuDevices := sh.getXPUDevicesOfNode(handler.GetPluginName(), v.Name)
 		sh.Lock()
 		UpdateXPUDevicesFromTopologyResults(xpuDevices, inUseDevicesOfTopology[v.Name])
 		sh.Unlock()
@@ -181,6 +199,21 @@ func (sh *ScheduleHandler) JobValid(obj interface{}) *api.ValidateResult {
 		_ = sh.SetJobPendingReason(job, result.Message)
 		return result
 	}
+	for _, task := range job.Tasks {
+		if err := CheckXPUMemoryUnits(task.Pod); err != nil {
+			_ = sh.SetJobPendingReason(job, err.Error())
+			return &api.ValidateResult{Pass: false, Reason: "invalid xpu memory request", Message: err.Error()}
+		}
+		if err := CheckXPUQos(task.Pod); err != nil {
+			_ = sh.SetJobPendingReason(job, err.Error())
+			return &api.ValidateResult{Pass: false, Reason: "invalid xpu qos class", Message: err.Error()}
+		}
+	}
+	// a job asking for more than the quota alone can never run
+	if err := sh.checkJobQuota(vcJob, false); err != nil {
+		_ = sh.SetJobPendingReason(job, err.Error())
+		return &api.ValidateResult{Pass: false, Reason: "xpu quota exceeded", Message: err.Error()}
+	}
 	return nil
 }
 
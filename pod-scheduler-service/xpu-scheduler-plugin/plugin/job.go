@@ -2,9 +2,11 @@ package plugin
 
 import (
 	"errors"
+	"sort"
 	"strings"
 	"sync"
 
+	"k8s.io/klog/v2"
 	"volcano.sh/volcano/pkg/scheduler/api"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
 )
@@ -26,21 +28,34 @@ func (sJob *SchedulerJob) Init(vcJob *api.JobInfo, sh *ScheduleHandler) error {
 }
 
 func (sJob *SchedulerJob) initJobInfo(vcJob *api.JobInfo) error {
-	name, num, tasks := getJobXPUTasks(vcJob)
+	names, num, tasks := getJobXPUTasks(vcJob)
 	if tasks == nil {
 		return errors.New("job has no xpu task")
 	}
 	sJob.JobReadyTag = true
-	sJob.UnschedulableReason = UnschedulableReason{Reason: map[string]string{}, Mutex: &sync.Mutex{}}
+	sJob.UnschedulableReason = UnschedulableReason{NodeFailures: map[NodeFailure]map[string]struct{}{},
+		Mutex: &sync.Mutex{}}
 	sJob.Id = vcJob.UID
 	sJob.NameSpace = vcJob.Namespace
+	sJob.Queue = string(vcJob.Queue)
+	sJob.Priority = vcJob.Priority
+	sJob.CreationTime = vcJob.CreationTimestamp.Time
 	sJob.ReferenceName = util.ReferenceNameOfJob(vcJob)
 	sJob.Selector = getSelectorFromVcJob(vcJob)
 	sJob.Label = getLabelFromVcJob(vcJob)
 	sJob.Annotation = vcJob.PodGroup.Annotations
-	sJob.handler = nil
+	sJob.handlers = make(map[string]XPUSchedulerPlugin, len(names))
+	sJob.TopologyAllocateOnce = make(map[string]*sync.Once, len(names))
+	for _, name := range names {
+		sJob.TopologyAllocateOnce[name] = &sync.Once{}
+	}
+	name := ""
+	if len(names) != 0 {
+		name = names[0]
+	}
 	sJob.XPUJob = &util.XPUJob{
 		ReqXPUName:             name,
+		ReqXPUNames:            names,
 		ReqXPUNum:              num,
 		Tasks:                  tasks,
 		ReqXPUInterBandwidth:   GetXPUTopologyInterBandwidth(sJob),
@@ -59,11 +74,16 @@ func (sJob *SchedulerJob) initJobInfo(vcJob *api.JobInfo) error {
 }
 
 func (sJob *SchedulerJob) isJobSupportByPlugin(sh *ScheduleHandler) bool {
-	name := sJob.getPluginNameByReq()
-	if name == "" {
+	if len(sJob.ReqXPUNames) == 0 {
 		return false
 	}
-	return sh.IsPluginRegistered(name)
+	for _, xpuName := range sJob.ReqXPUNames {
+		name := getPluginNameByXPUName(xpuName)
+		if name == "" || !sh.IsPluginRegistered(name) {
+			return false
+		}
+	}
+	return true
 }
 
 func isSelectorContains(value string, jobValue string) bool {
@@ -143,15 +163,15 @@ func getTaskResource(task *api.TaskInfo) *util.TaskResource {
 	return taskResources
 }
 
-func getJobXPUTasks(vcJob *api.JobInfo) (string, int, map[api.TaskID]*util.XPUTask) {
+func getJobXPUTasks(vcJob *api.JobInfo) ([]string, int, map[api.TaskID]*util.XPUTask) {
 	if vcJob == nil {
-		return "", 0, nil
+		return nil, 0, nil
 	}
 	if len(vcJob.Tasks) == 0 {
-		return "", 0, nil
+		return nil, 0, nil
 	}
 
-	name := ""
+	var names []string
 	num := 0
 	resultMap := make(map[api.TaskID]*util.XPUTask, util.MapInitNum)
 	for taskID, taskInf := range vcJob.Tasks {
@@ -170,37 +190,47 @@ func getJobXPUTasks(vcJob *api.JobInfo) (string, int, map[api.TaskID]*util.XPUTa
 			Annotation:           taskInf.Pod.Annotations,
 			PodStatus:            taskInf.Pod.Status.Phase,
 			ReqXPUIntraBandwidth: GetXPUTopologyIntraBandwidth(taskInf.Pod),
+			ContainerRequests:    GetXPUContainerRequests(taskInf, taskResource.ReqXPUName),
 			ScoreMap:             make(map[string]float64),
 			Selector:             getTaskSelectors(taskInf),
 			Label:                getTaskLabels(taskInf),
 		}
-		if name == "" {
-			name = taskResource.ReqXPUName
-		} else if taskResource.ReqXPUName != "" && name != taskResource.ReqXPUName {
-			return "", 0, nil
+		if taskResource.ReqXPUName != "" && !isNameContained(names, taskResource.ReqXPUName) {
+			names = append(names, taskResource.ReqXPUName)
 		}
 		num += taskResource.ReqXPUNum
 	}
-	return name, num, resultMap
+	sort.Strings(names)
+	return names, num, resultMap
+}
+
+func isNameContained(names []string, name string) bool {
+	for _, v := range names {
+		if v == name {
+			return true
+		}
+	}
+	return false
 }
 
 func (sJob *SchedulerJob) initPluginByJobInfo(sh *ScheduleHandler) {
 	if sJob == nil {
 		return
 	}
-	pluginName := sJob.getPluginNameByReq()
-	if pluginName == "" {
-		return
-	}
-	plugin, ok := sh.XPUPlugins[pluginName]
-	if !ok {
-		return
+	for _, xpuName := range sJob.ReqXPUNames {
+		pluginName := getPluginNameByXPUName(xpuName)
+		if pluginName == "" {
+			continue
+		}
+		plugin, ok := sh.XPUPlugins[pluginName]
+		if !ok {
+			continue
+		}
+		sJob.handlers[xpuName] = plugin()
 	}
-	sJob.handler = plugin()
 }
 
-func (sJob *SchedulerJob) getPluginNameByReq() string {
-	name := sJob.ReqXPUName
+func getPluginNameByXPUName(name string) string {
 	if strings.Contains(name, util.VGPUName) {
 		return util.GPUPluginName
 	}
@@ -210,17 +240,82 @@ func (sJob *SchedulerJob) getPluginNameByReq() string {
 	return ""
 }
 
+// getHandler get the plugin placing the task, by the xpu name the task requests
+func (sJob *SchedulerJob) getHandler(task *api.TaskInfo) XPUSchedulerPlugin {
+	if sJob == nil || task == nil {
+		return nil
+	}
+	xpuTask, ok := sJob.Tasks[task.UID]
+	if !ok {
+		return nil
+	}
+	return sJob.handlers[xpuTask.ReqXPUName]
+}
+
 func (sJob *SchedulerJob) ValidJobFn() *api.ValidateResult {
-	if result := sJob.handler.ValidXPUJob(); result != nil {
-		return result
+	for _, xpuName := range sJob.ReqXPUNames {
+		handler, ok := sJob.handlers[xpuName]
+		if !ok {
+			continue
+		}
+		if result := handler.ValidXPUJob(); result != nil {
+			return result
+		}
 	}
 	return nil
 }
 
+// IsJobReady check whether at least MinAvailable tasks of the job can run. Only the tasks allocated,
+// pipelined or running are counted, the tasks not taken by the plugin by their status alone. An xpu task
+// counts only when it was placed before this session, or got xpu devices or a topology scheduling result
+// on its node in this session.
+func (sJob *SchedulerJob) IsJobReady(vcJob *api.JobInfo) bool {
+	if sJob == nil || vcJob == nil {
+		return false
+	}
+	ready := 0
+	for taskID, task := range vcJob.Tasks {
+		if !isTaskReadyStatus(task.Status) {
+			continue
+		}
+		xpuTask, ok := sJob.Tasks[taskID]
+		if !ok || !util.IsXPUName(xpuTask.ReqXPUName) {
+			ready++
+			continue
+		}
+		xpuTask.Lock()
+		allocated := xpuTask.Allocated
+		xpuTask.Unlock()
+		if allocated || xpuTask.NodeName != "" {
+			ready++
+			continue
+		}
+		if result, exist := sJob.TopologyScheduleResult[taskID]; exist && task.NodeName != "" &&
+			result.NodeName == task.NodeName {
+			ready++
+		}
+	}
+	if int32(ready) < vcJob.MinAvailable {
+		klog.V(util.LogDebugLevel).Infof("job %s/%s not ready, %d tasks placed, min available %d.",
+			vcJob.Namespace, vcJob.Name, ready, vcJob.MinAvailable)
+		return false
+	}
+	return true
+}
+
+// isTaskReadyStatus check whether the task is allocated or pipelined in this session, or bound to its node
+func isTaskReadyStatus(status api.TaskStatus) bool {
+	switch status {
+	case api.Allocated, api.Pipelined, api.Binding, api.Bound, api.Running:
+		return true
+	default:
+		return false
+	}
+}
+
 func (sJob *SchedulerJob) preCheckNodePredicate(taskinfo *api.TaskInfo, nodeInfo *api.NodeInfo) error {
 	if !util.IsSelectorMeetJob(sJob.Selector, nodeInfo.Node.Labels) {
-		meetErr := errors.New("node labels not meet job selector")
-		return meetErr
+		return newNodeFailure(ReasonSelectorMismatch, "node labels not meet job selector")
 	}
 	return nil
 }
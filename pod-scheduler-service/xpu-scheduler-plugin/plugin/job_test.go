@@ -0,0 +1,63 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package plugin
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"volcano.sh/volcano/pkg/scheduler/api"
+)
+
+// testCPUTask a task of job asking for no xpu
+func testCPUTask(name string, job api.JobID, status api.TaskStatus) *api.TaskInfo {
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, UID: types.UID(name),
+			Annotations: map[string]string{}},
+		Spec: v1.PodSpec{Containers: []v1.Container{{Name: "c1"}}},
+	}
+	task := api.NewTaskInfo(pod)
+	task.Job = job
+	task.Status = status
+	return task
+}
+
+func TestIsJobReady(t *testing.T) {
+	tests := []struct {
+		name         string
+		cpuStatus    api.TaskStatus
+		gpuRunning   bool
+		gpuAllocated bool
+		want         bool
+	}{
+		{name: "pending cpu task", cpuStatus: api.Pending, gpuAllocated: true, want: false},
+		{name: "allocated cpu task", cpuStatus: api.Allocated, gpuAllocated: true, want: true},
+		{name: "pipelined cpu task", cpuStatus: api.Pipelined, gpuAllocated: true, want: true},
+		{name: "running tasks", cpuStatus: api.Running, gpuRunning: true, want: true},
+		{name: "xpu task without devices", cpuStatus: api.Running, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := testGPUNode("node-1", 1)
+			gpuTask := testGPUTask("gpu-task", "job-1", 1, 50, 10)
+			if tt.gpuRunning {
+				testRunningOn(gpuTask, node, "0,GPU-node-1-0,A100,10240,50,0:")
+			}
+			cpuTask := testCPUTask("cpu-task", "job-1", tt.cpuStatus)
+			sh, ssn := testScheduleHandler(t, testGPUPlugin(), []*api.NodeInfo{node}, gpuTask, cpuTask)
+			if !tt.gpuRunning {
+				gpuTask.NodeName, gpuTask.Status = node.Name, api.Allocated
+			}
+			if tt.gpuAllocated {
+				sh.XPUAllocateFunc(gpuTask, ssn)
+			}
+			if got := sh.Jobs["job-1"].IsJobReady(ssn.Jobs["job-1"]); got != tt.want {
+				t.Errorf("IsJobReady() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
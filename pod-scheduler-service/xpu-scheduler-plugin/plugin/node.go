@@ -4,13 +4,18 @@ import (
 	"fmt"
 
 	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/metrics"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
 )
 
-func (sh *ScheduleHandler) initXPUDevicesOfNode(sJob *SchedulerJob, node *api.NodeInfo) {
-	xpuDevices := sJob.handler.GetXPUDevicesFromNode(node)
+func (sh *ScheduleHandler) initXPUDevicesOfNode(handler XPUSchedulerPlugin, node *api.NodeInfo) {
+	xpuDevices := handler.GetXPUDevicesFromNode(node)
 	sh.Lock()
-	sh.XPUDevices[node.Name] = xpuDevices
+	if _, ok := sh.XPUDevices[handler.GetPluginName()]; !ok {
+		sh.XPUDevices[handler.GetPluginName()] = make(map[string]map[int]*common.XPUDevice)
+	}
+	sh.XPUDevices[handler.GetPluginName()][node.Name] = xpuDevices
 	sh.Unlock()
 }
 
@@ -25,13 +30,57 @@ func (sh *ScheduleHandler) NodePredicate(task *api.TaskInfo, node *api.NodeInfo)
 	if !util.IsXPUName(sJob.ReqXPUName) || !IsXPUTask(sJob, task) {
 		return nil
 	}
-	if err := sJob.preCheckNodePredicate(task, node); err != nil {
-		return err
+	handler := sJob.getHandler(task)
+	if handler == nil {
+		return fmt.Errorf("task %s has no xpu plugin", task.Name)
+	}
+	err := sJob.preCheckNodePredicate(task, node)
+	if err == nil {
+		err = sh.CheckQuota(sJob, getTaskXPURequest(sJob.Tasks[task.UID]))
+	}
+	if err == nil {
+		err = handler.NodePredicateForTask(sJob, task, node, sh)
 	}
-
-	err := sJob.handler.NodePredicateForTask(sJob, task, node, sh)
 	if err != nil {
+		metrics.RecordPredicateFailure(handler.GetPluginName(), string(toNodeFailure(err).Type))
 		return err
 	}
 	return nil
 }
+
+// UpdateNodeMetrics report the free resources of the healthy devices of each node as seen by the scheduler
+func (sh *ScheduleHandler) UpdateNodeMetrics() {
+	metrics.ResetNodeFreeResources()
+	for _, name := range sh.sortedPluginNames() {
+		handler := sh.XPUPlugins[name]()
+		if handler == nil {
+			continue
+		}
+		for _, node := range sh.Nodes {
+			if node == nil || node.Node == nil {
+				continue
+			}
+			xpuDevices := handler.GetXPUDevicesFromNode(node)
+			if len(xpuDevices) == 0 {
+				continue
+			}
+			cores, vids := 0, 0
+			var memory uint64
+			for _, device := range xpuDevices {
+				if !device.Health {
+					continue
+				}
+				if device.UsedCores < util.Base100 {
+					cores += util.Base100 - device.UsedCores
+				}
+				if capacity := device.MemoryCapacity(); device.UsedMemory < capacity {
+					memory += capacity - device.UsedMemory
+				}
+				if used := int(device.GetVidBound()); used < device.Count {
+					vids += device.Count - used
+				}
+			}
+			metrics.UpdateNodeFreeResources(handler.GetPluginName(), node.Name, cores, memory, vids)
+		}
+	}
+}
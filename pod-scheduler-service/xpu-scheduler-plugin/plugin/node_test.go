@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package plugin
+
+import (
+	"testing"
+
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+func testNPUPlugin() *SchedulerPlugin {
+	return &SchedulerPlugin{
+		PluginName:                 util.NPUPluginName,
+		VxpuName:                   util.VNPUName,
+		VxpuType:                   util.VNPUType,
+		VxpuCore:                   util.VNPUCore,
+		VxpuMemory:                 util.VNPUMemory,
+		VxpuMemoryMiB:              util.VNPUMemoryMiB,
+		Config:                     &CommonConfig{TestEnable: true},
+		NodeXPURegisterAnno:        util.NodeNPURegisterAnnotation,
+		AssignedXPUsToAllocateAnno: util.AssignedNPUsToAllocateAnnotations,
+		AssignedXPUsToNodeAnno:     util.AssignedNPUsToNodeAnnotations,
+		AssignedXPUsToPodAnno:      util.AssignedNPUsToPodAnnotations,
+		NodeXPUTopologyAnno:        util.NodeNPUTopologyAnnotation,
+		NodeXPUHandshakeAnno:       util.NodeNPUHandshakeAnnotation,
+		XPUNodeDeviceType:          util.AscendNPUDevice,
+	}
+}
+
+// TestXPUDevicesOfNodePerPlugin the devices of a node with gpus and npus are cached apart for each plugin
+func TestXPUDevicesOfNodePerPlugin(t *testing.T) {
+	gpu, npu := testGPUPlugin(), testNPUPlugin()
+	node := testGPUNode("node-1", 2)
+	node.Node.Annotations[util.NodeNPURegisterAnnotation] = "0,NPU-a,10,65536,910B,true,0:"
+	sh, _ := testScheduleHandler(t, gpu, []*api.NodeInfo{node})
+	sh.XPUPlugins[util.NPUPluginName] = func() XPUSchedulerPlugin { return npu }
+
+	sh.GetAllocatableXPUDeviceOnNodes(gpu)
+	sh.GetAllocatableXPUDeviceOnNodes(npu)
+	for _, tt := range []struct {
+		pluginName string
+		wantIds    []string
+	}{
+		{pluginName: util.GPUPluginName, wantIds: []string{"GPU-node-1-0", "GPU-node-1-1"}},
+		{pluginName: util.NPUPluginName, wantIds: []string{"NPU-a"}},
+	} {
+		devices := sh.getXPUDevicesOfNode(tt.pluginName, node.Name)
+		if len(devices) != len(tt.wantIds) {
+			t.Errorf("%s devices %v, want %v", tt.pluginName, devices, tt.wantIds)
+			continue
+		}
+		for i, id := range tt.wantIds {
+			if devices[i] == nil || devices[i].Id != id {
+				t.Errorf("%s device %d is %v, want %s", tt.pluginName, i, devices[i], id)
+			}
+		}
+	}
+}
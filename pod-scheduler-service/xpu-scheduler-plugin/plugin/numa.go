@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package plugin implements xpu scheduler plugin
+package plugin
+
+import (
+	"strconv"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const (
+	// NumaPolicyNone the NUMA nodes of the devices of a vxpu container are not considered
+	NumaPolicyNone = "none"
+	// NumaPolicyPreferred the devices of a vxpu container in one NUMA node are preferred and score higher
+	NumaPolicyPreferred = "preferred"
+	// NumaPolicyStrict the devices of a vxpu container must be in one NUMA node
+	NumaPolicyStrict = "strict"
+)
+
+// IsNumaPolicy check whether the policy is a known vxpu NUMA policy
+func IsNumaPolicy(policy string) bool {
+	return policy == NumaPolicyNone || policy == NumaPolicyPreferred || policy == NumaPolicyStrict
+}
+
+// GetPodNumaNodes get the NUMA nodes the CPUs of the pod are bound to, nil if the pod does not tell
+func GetPodNumaNodes(pod *v1.Pod) []int {
+	if pod == nil {
+		return nil
+	}
+	value, ok := pod.Annotations[util.XPUNumaNodesAnnotation]
+	if !ok {
+		return nil
+	}
+	var numaNodes []int
+	for _, item := range strings.Split(value, util.Comma) {
+		numa, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || numa < 0 {
+			klog.V(util.LogWarningLevel).Infof("pod %s/%s invalid %s %q, ignored.",
+				pod.Namespace, pod.Name, util.XPUNumaNodesAnnotation, value)
+			return nil
+		}
+		numaNodes = append(numaNodes, numa)
+	}
+	return numaNodes
+}
+
+// inPodNuma check whether the NUMA node is one of the NUMA nodes of the pod, true if it tells none
+func (tc *topologyConstraint) inPodNuma(numa int) bool {
+	if len(tc.numaNodes) == 0 {
+		return true
+	}
+	for _, node := range tc.numaNodes {
+		if node == numa {
+			return true
+		}
+	}
+	return false
+}
@@ -12,10 +12,12 @@ import (
 	"time"
 
 	"k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/klog/v2"
 	"volcano.sh/volcano/pkg/scheduler/api"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/allocator"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/metrics"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
 )
 
@@ -29,7 +31,12 @@ type XPUSchedulerPlugin interface {
 	GetXPUDevicesFromNode(*api.NodeInfo) map[int]*common.XPUDevice
 	NodePredicateForTask(*SchedulerJob, *api.TaskInfo, *api.NodeInfo, *ScheduleHandler) error
 	Allocate(*SchedulerJob, *api.TaskInfo, *api.NodeInfo, map[int]*common.XPUDevice) error
-	Deallocate(*api.TaskInfo, *api.NodeInfo) error
+	Deallocate(*SchedulerJob, *api.TaskInfo, *api.NodeInfo, map[int]*common.XPUDevice) error
+	SelectVictims(*SchedulerJob, *api.TaskInfo, *api.NodeInfo, []*api.TaskInfo, *ScheduleHandler) (
+		[]*api.TaskInfo, error)
+	GetXPUDevicesOfPod(*v1.Pod) PodDevices
+	ResizePodsOnNode(*api.NodeInfo, *ScheduleHandler, kubernetes.Interface)
+	GetPluginName() string
 }
 
 // SchedulerPlugin for all volcano-npu plugin
@@ -39,6 +46,7 @@ type SchedulerPlugin struct {
 	VxpuType                   string
 	VxpuCore                   string
 	VxpuMemory                 string
+	VxpuMemoryMiB              string
 	Config                     *CommonConfig
 	NodeXPURegisterAnno        string
 	AssignedXPUsToAllocateAnno string
@@ -46,6 +54,8 @@ type SchedulerPlugin struct {
 	AssignedXPUsToNodeAnno     string
 	NodeXPUTopologyAnno        string
 	NodeXPUHandshakeAnno       string
+	// XPUNodeDeviceType device type of the XPUNode objects reported for the plugin
+	XPUNodeDeviceType string
 }
 
 // CommonConfig for plugin
@@ -53,6 +63,27 @@ type CommonConfig struct {
 	NumaEnable     bool
 	TestEnable     bool
 	TopologyEnable bool
+	// VxpuNumaPolicy NUMA alignment of the devices of a vxpu container, none, preferred or strict
+	VxpuNumaPolicy string
+	// VxpuQosIsolation the qos classes whose vxpu containers may not share a card
+	VxpuQosIsolation QosIsolation
+	// ScoreStrategy name of the XPUScorer used for device choice and node ordering
+	ScoreStrategy string
+	// TopologySearchMaxIterations maximum placements tried by one topology allocation
+	TopologySearchMaxIterations int
+	// TopologySearchTimeout time budget of one topology allocation in milliseconds
+	TopologySearchTimeout int
+	// TopologyWeights weights of the objective function ranking topology allocations
+	TopologyWeights allocator.Weights
+	// ReservationEnable reserve devices for the starving topology jobs, kept from the vxpu containers
+	ReservationEnable bool
+	// ReservationTimeout seconds the devices stay reserved for a starving topology job
+	ReservationTimeout int
+}
+
+// GetPluginName name of the xpu plugin, gpu or npu
+func (sp *SchedulerPlugin) GetPluginName() string {
+	return sp.PluginName
 }
 
 // ValidXPUJob check job req xpu num
@@ -86,16 +117,10 @@ func getInUseDevice(inUseDeviceMap map[string][]common.ContainerDevice, annoName
 
 // GetXPUDevicesFromNode get xpu infos from node
 func (sp *SchedulerPlugin) GetXPUDevicesFromNode(node *api.NodeInfo) map[int]*common.XPUDevice {
-	infos, ok := node.Node.Annotations[sp.NodeXPURegisterAnno]
-	if !ok {
-		klog.V(util.LogWarningLevel).Infof("Get XPU Devices failed, annotation %s not exist on node %s",
-			sp.NodeXPURegisterAnno, node.Name)
-		return nil
-	}
-	if !sp.Config.TestEnable && !checkHandShake(node, sp.NodeXPUHandshakeAnno) {
+	xpuDevices, err := sp.getNodeXPUDevices(node)
+	if err != nil {
 		return nil
 	}
-	xpuDevices := DecodeNodeDevices(infos, node.Name)
 	inUseDeviceMap := make(map[string][]common.ContainerDevice)
 	for _, pod := range node.Pods() {
 		getInUseDevice(inUseDeviceMap, sp.AssignedXPUsToPodAnno, pod)
@@ -105,7 +130,10 @@ func (sp *SchedulerPlugin) GetXPUDevicesFromNode(node *api.NodeInfo) map[int]*co
 			for _, x := range inUseDeviceMap[v.Id] {
 				v.UsedMemory += x.UsedMemory
 				v.UsedCores += x.UsedCores
-				v.OccupyVid(x.Vid)
+				v.AddQos(qosOf(x))
+				if err := v.OccupyVid(x.Vid); err != nil {
+					klog.V(util.LogWarningLevel).Infof("node %s: %v", node.Name, err)
+				}
 			}
 			v.InUse = true
 		}
@@ -147,7 +175,7 @@ func checkHandShake(node *api.NodeInfo, anno string) bool {
 // topology and non-topology scenarios
 func (sp *SchedulerPlugin) NodePredicateForTask(sJob *SchedulerJob, task *api.TaskInfo,
 	node *api.NodeInfo, sh *ScheduleHandler) error {
-	if sp == nil || sJob == nil || task == nil || len(node.Node.Annotations) == 0 {
+	if sp == nil || sJob == nil || task == nil || node == nil || node.Node == nil {
 		err := errors.New(util.ArgumentError)
 		klog.V(util.LogErrorLevel).Infof("NodePredicateForTask err: %v", err.Error())
 		return err
@@ -159,28 +187,45 @@ func (sp *SchedulerPlugin) NodePredicateForTask(sJob *SchedulerJob, task *api.Ta
 			task.Name, sJob.Id)
 	}
 
-	unUseXPUDevivesOfNodes := sh.GetAllocatableXPUDeviceOnNodes(sJob)
+	unUseXPUDevivesOfNodes := sh.GetAllocatableXPUDeviceOnNodes(sp)
 
 	// node predicate for topology task
 	if sp.Config.TopologyEnable && !xpuTask.IsVXPUTask {
+		// the devices reserved for another starving job are left out
+		unUseXPUDevivesOfNodes = withoutReservedDevices(sp.VxpuName, sJob.Id, unUseXPUDevivesOfNodes)
 		// run core
-		sJob.TopologyAllocateOnce.Do(func() {
-			sp.PerformTopologyAllocation(sh.Nodes, task, sJob, unUseXPUDevivesOfNodes)
+		sJob.TopologyAllocateOnce[sp.VxpuName].Do(func() {
+			if sp.PerformTopologyAllocation(sh.Nodes, task, sJob, unUseXPUDevivesOfNodes) == nil {
+				sp.reserveForStarvingJob(sh, sJob)
+			}
 		})
 		result := sJob.TopologyScheduleResult[task.UID]
 
-		if result == nil || result.NodeName != node.Name {
-			return errors.New("different from the topology scheduling pre-allocation result")
+		if result == nil {
+			if !pinnedDevicesFree(xpuTask, unUseXPUDevivesOfNodes[node.Name]) {
+				return newNodeFailure(ReasonDeviceUUIDFiltered, "pinned devices not free for topology allocation")
+			}
+			return newNodeFailure(ReasonTopologyAllocation, "topology allocation failed")
+		}
+		if result.NodeName != node.Name {
+			return newNodeFailure(ReasonTopologyAllocation, "different from the topology allocation result")
 		}
 		return nil
 	}
 
 	// node predicate for topology task
 	var score float64
-	fit, _, err := sp.calculateDecision(task.Pod, sh.getXPUDevicesOfNode(node.Name), &score)
+	xpuDevices := sh.getXPUDevicesOfNode(sp.PluginName, node.Name)
+	if len(xpuDevices) == 0 {
+		if _, err := sp.getNodeXPUDevices(node); err != nil {
+			return err
+		}
+	}
+	fit, _, err := sp.calculateDecision(task.Pod, node, xpuDevices, &score)
 	if err != nil || !fit {
-		return fmt.Errorf("%s predicate failed: no suitable devices, err: %v",
-			sp.PluginName, err)
+		klog.V(util.LogDebugLevel).Infof("%s predicate failed on node %s: no suitable devices, err: %v",
+			sp.PluginName, node.Name, err)
+		return err
 	}
 	xpuTask.Lock()
 	xpuTask.ScoreMap[node.Name] = score
@@ -189,6 +234,22 @@ func (sp *SchedulerPlugin) NodePredicateForTask(sJob *SchedulerJob, task *api.Ta
 	return nil
 }
 
+// pinnedDevicesFree check whether enough of the devices the task is pinned on are free among the devices of
+// one node, true if it is not pinned
+func pinnedDevicesFree(xpuTask *util.XPUTask, unUseXPUDevices []*common.XPUDevice) bool {
+	uuids := xpuTask.ReqXPUUUID
+	if len(uuids.Use) == 0 {
+		return true
+	}
+	free := 0
+	for _, device := range unUseXPUDevices {
+		if uuids.Accept(device.Id) {
+			free++
+		}
+	}
+	return free >= xpuTask.ReqXPUNum
+}
+
 // PerformTopologyAllocation Perform topology allocation for all tasks under sJob
 // and return the allocation result for the current task
 func (sp *SchedulerPlugin) PerformTopologyAllocation(nodes []*api.NodeInfo, task *api.TaskInfo,
@@ -197,8 +258,12 @@ func (sp *SchedulerPlugin) PerformTopologyAllocation(nodes []*api.NodeInfo, task
 	if !success {
 		return nil
 	}
-	// reset TopologyScheduleResult of sJob
-	sJob.TopologyScheduleResult = make(map[api.TaskID]*util.TopologyScheduleXPUs)
+	// reset TopologyScheduleResult of the tasks placed by this plugin
+	for taskID, xpuTask := range sJob.Tasks {
+		if xpuTask.ReqXPUName == sp.VxpuName {
+			delete(sJob.TopologyScheduleResult, taskID)
+		}
+	}
 
 	// Save topology batch scheduling result to sJob.
 	// Next time another task(pod) is scheduled, will check the TopologyScheduleResult
@@ -207,8 +272,9 @@ func (sp *SchedulerPlugin) PerformTopologyAllocation(nodes []*api.NodeInfo, task
 
 	for i, v := range allTaskResult {
 		topologyScheduleXPUs := &util.TopologyScheduleXPUs{
-			AllocateXPUs: v.DeviceIds,
-			NodeName:     v.NodeName,
+			AllocateXPUs:  v.DeviceIds,
+			ContainerXPUs: v.ContainerDeviceIds,
+			NodeName:      v.NodeName,
 		}
 		sJob.TopologyScheduleResult[v.TaskId] = topologyScheduleXPUs
 		if v.TaskId == task.UID {
@@ -235,6 +301,27 @@ func (sp *SchedulerPlugin) setXPUDevicesToPod(task *api.TaskInfo, nodeName strin
 	return
 }
 
+// clearXPUDevicesOfPod remove the allocation annotations set by setXPUDevicesToPod
+func (sp *SchedulerPlugin) clearXPUDevicesOfPod(task *api.TaskInfo) {
+	if task == nil || task.Pod == nil || task.Pod.Annotations == nil {
+		klog.V(util.LogErrorLevel).Infof("clearXPUDevicesOfPod err: %s", util.ObjectNilError)
+		return
+	}
+	delete(task.Pod.Annotations, sp.AssignedXPUsToNodeAnno)
+	delete(task.Pod.Annotations, sp.AssignedXPUsToAllocateAnno)
+	delete(task.Pod.Annotations, sp.AssignedXPUsToPodAnno)
+	delete(task.Pod.Annotations, util.BindTimeAnnotations)
+	delete(task.Pod.Annotations, util.DeviceBindPhase)
+}
+
+// GetXPUDevicesOfPod get the xpu devices assigned to the pod by the scheduler
+func (sp *SchedulerPlugin) GetXPUDevicesOfPod(pod *v1.Pod) PodDevices {
+	if sp == nil || pod == nil {
+		return PodDevices{}
+	}
+	return DecodePodDevices(pod.Annotations[sp.AssignedXPUsToPodAnno])
+}
+
 // getXPUReqFromContainer get xpu request number from container
 func (sp *SchedulerPlugin) getXPUReqFromContainer(container *v1.Container) int {
 	var number int = 0
@@ -245,36 +332,51 @@ func (sp *SchedulerPlugin) getXPUReqFromContainer(container *v1.Container) int {
 	return number
 }
 
-// getPodDeviceFromAllocateXPUs select xpu devices from node
-func (sp *SchedulerPlugin) getPodDeviceFromAllocateXPUs(
-	pod *v1.Pod, allocateXPUs []int, xpuDevices map[int]*common.XPUDevice) string {
+// getPodDeviceFromAllocateXPUs select xpu devices from node, containerXPUs gives the devices of each xpu
+// container, without it allocateXPUs is sliced across the containers in order
+func (sp *SchedulerPlugin) getPodDeviceFromAllocateXPUs(pod *v1.Pod, allocateXPUs []int, containerXPUs [][]int,
+	xpuDevices map[int]*common.XPUDevice) string {
 	selectDevices := PodDevices{}
 	start := 0
 	length := len(allocateXPUs)
+	containerIdx := 0
 	for _, v := range pod.Spec.Containers {
 		xpuNum := sp.getXPUReqFromContainer(&v)
 		if xpuNum == 0 {
 			continue
 		}
-		if start+xpuNum > length {
-			klog.V(util.LogErrorLevel).Infof("getPodDeviceFromAllocateXPUs failed, insufficient number of xpu devices,"+
-				"request xpu number: %d, allocate %v", start+xpuNum, allocateXPUs)
-			return ""
+		var containerAllocateXPUs []int
+		if len(containerXPUs) != 0 {
+			if containerIdx >= len(containerXPUs) || len(containerXPUs[containerIdx]) != xpuNum {
+				klog.V(util.LogErrorLevel).Infof("getPodDeviceFromAllocateXPUs failed, container %s request xpu "+
+					"number: %d, allocate %v", v.Name, xpuNum, containerXPUs)
+				return ""
+			}
+			containerAllocateXPUs = containerXPUs[containerIdx]
+			containerIdx++
+		} else {
+			if start+xpuNum > length {
+				klog.V(util.LogErrorLevel).Infof("getPodDeviceFromAllocateXPUs failed, insufficient number of xpu "+
+					"devices, request xpu number: %d, allocate %v", start+xpuNum, allocateXPUs)
+				return ""
+			}
+			containerAllocateXPUs = allocateXPUs[start : start+xpuNum]
+			start += xpuNum
 		}
-		cds, err := getContainerDevices(allocateXPUs[start:start+xpuNum], xpuDevices)
+		cds, err := getContainerDevices(containerAllocateXPUs, xpuDevices)
 		if err != nil {
 			klog.V(util.LogErrorLevel).Infof(
 				"getPodDeviceFromAllocateXPUs failed, err: %v", err)
 			return ""
 		}
 		selectDevices = append(selectDevices, cds)
-		start += xpuNum
 	}
 	return EncodePodDevices(selectDevices)
 }
 
-func (sp *SchedulerPlugin) getSelectXPUs(task *api.TaskInfo, xpuDevices map[int]*common.XPUDevice) string {
-	fit, device, err := sp.calculateDecision(task.Pod, xpuDevices, nil)
+func (sp *SchedulerPlugin) getSelectXPUs(task *api.TaskInfo, node *api.NodeInfo,
+	xpuDevices map[int]*common.XPUDevice) string {
+	fit, device, err := sp.calculateDecision(task.Pod, node, xpuDevices, nil)
 	if err != nil || !fit {
 		klog.V(util.LogErrorLevel).Infof("%s Allocate failed: no suitable devices was selected.",
 			sp.PluginName)
@@ -299,7 +401,7 @@ func (sp *SchedulerPlugin) getTopologySelectXPUs(sJob *SchedulerJob, task *api.T
 		return ""
 	}
 	allocateXPUs = topologyScheduleXPUs.AllocateXPUs
-	return sp.getPodDeviceFromAllocateXPUs(task.Pod, allocateXPUs, xpuDevices)
+	return sp.getPodDeviceFromAllocateXPUs(task.Pod, allocateXPUs, topologyScheduleXPUs.ContainerXPUs, xpuDevices)
 }
 
 // Allocate select xpu for task from node
@@ -321,12 +423,12 @@ func (sp *SchedulerPlugin) Allocate(sJob *SchedulerJob, task *api.TaskInfo,
 	if sp.Config.TopologyEnable && !sJob.Tasks[task.UID].IsVXPUTask {
 		selectedXPUs = sp.getTopologySelectXPUs(sJob, task, node, xpuDevices)
 	} else {
-		selectedXPUs = sp.getSelectXPUs(task, xpuDevices)
+		selectedXPUs = sp.getSelectXPUs(task, node, xpuDevices)
 	}
 	if selectedXPUs == "" {
 		klog.V(util.LogErrorLevel).Infof("%s Allocate failed: no suitable xpus selected.",
 			sp.PluginName)
-		return nil
+		return fmt.Errorf("%s allocate task %s failed: no suitable xpus selected", sp.PluginName, task.Name)
 	}
 	klog.V(util.LogDebugLevel).Infof("%s Allocate task<%s> select xpu <%v>",
 		sp.PluginName, task.Name, selectedXPUs)
@@ -334,8 +436,27 @@ func (sp *SchedulerPlugin) Allocate(sJob *SchedulerJob, task *api.TaskInfo,
 	return nil
 }
 
-// Deallocate remove xpu allocation from node
-func (sp *SchedulerPlugin) Deallocate(_ *api.TaskInfo, _ *api.NodeInfo) error {
+// Deallocate remove xpu allocation from node, give back the devices charged by Allocate
+// and clear the allocation annotations of the pod. Only for a task allocated in the current session,
+// a task placed before keeps running on its devices
+func (sp *SchedulerPlugin) Deallocate(sJob *SchedulerJob, task *api.TaskInfo,
+	node *api.NodeInfo, xpuDevices map[int]*common.XPUDevice) error {
+	if sJob == nil || sp == nil || task == nil || task.Pod == nil || node == nil {
+		err := errors.New(util.ArgumentError)
+		klog.V(util.LogErrorLevel).Infof("Deallocate err: %s", err.Error())
+		return err
+	}
+	podDevices, ok := task.Pod.Annotations[sp.AssignedXPUsToPodAnno]
+	if !ok {
+		klog.V(util.LogDebugLevel).Infof("%s Deallocate task<%s> has no xpu allocated.", sp.PluginName, task.Name)
+		return nil
+	}
+	if task.Pod.Annotations[sp.AssignedXPUsToNodeAnno] == node.Name {
+		sp.releaseXPUDevicesOfPod(task.Pod, xpuDevices)
+	}
+	klog.V(util.LogDebugLevel).Infof("%s Deallocate task<%s> release xpu <%v> on node %s",
+		sp.PluginName, task.Name, podDevices, node.Name)
+	sp.clearXPUDevicesOfPod(task)
 	return nil
 }
 
@@ -355,8 +476,10 @@ func (sp *SchedulerPlugin) topologyAllocate(nodes []*api.NodeInfo,
 		"topologyAllocate start, node topology: %v, podrequest: %v, taskList: %v",
 		topologyOfNodes, podRequests, taskList)
 	// Batch scheduling all tasks within the job at once
-	allocator.SetNumaConfig(sp.Config.NumaEnable)
+	sp.setAllocatorConfig()
+	start := time.Now()
 	result, err := allocator.Allocate(topologyOfNodes, podRequests, reqXPUInterBandwidth)
+	metrics.UpdateTopologyAllocate(sp.PluginName, len(topologyOfNodes), time.Since(start), err == nil)
 	klog.V(util.LogDebugLevel).Infof("topologyAllocate end, result: %v", result)
 	if err != nil {
 		klog.V(util.LogErrorLevel).Infof("topologyAllocate failed, err: %v", err)
@@ -370,24 +493,26 @@ func (sp *SchedulerPlugin) topologyAllocate(nodes []*api.NodeInfo,
 	return result, true
 }
 
+// setAllocatorConfig pass the plugin arguments to the topology allocator
+func (sp *SchedulerPlugin) setAllocatorConfig() {
+	allocator.SetNumaConfig(sp.Config.NumaEnable)
+	allocator.SetSearchBudget(sp.Config.TopologySearchMaxIterations,
+		time.Duration(sp.Config.TopologySearchTimeout)*time.Millisecond)
+	allocator.SetObjectiveWeights(sp.Config.TopologyWeights)
+}
+
 // getXPUTopology for get xpu topology info
 func (sp *SchedulerPlugin) getXPUTopology(
 	nodes []*api.NodeInfo, unUseXPUDevicesOfNodes map[string][]*common.XPUDevice) []allocator.NodeResource {
 	// Get xpu devices topology info from nodes
 	var xpuTopology []allocator.NodeResource
 	for _, v := range nodes {
-		xpuTopoInfo, ok := v.Node.Annotations[sp.NodeXPUTopologyAnno]
+		topoGraph, ok := sp.getNodeXPUTopology(v)
 		if !ok {
 			klog.V(util.LogDebugLevel).Infof("ScoreBestXPUNodes node %s get xpu topology failed, skip.",
 				v.Name)
 			continue
 		}
-		topoGraph, ok := DecodeNodeXPUTopology(xpuTopoInfo)
-		if !ok {
-			klog.V(util.LogDebugLevel).Infof("ScoreBestXPUNodes node %s decode xpu topology failed, topo info: %s",
-				v.Name, xpuTopoInfo)
-			continue
-		}
 		unUseXPUDevices, ok := unUseXPUDevicesOfNodes[v.Name]
 		if !ok {
 			klog.V(util.LogDebugLevel).Infof("ScoreBestXPUNodes node %s get unuse xpu topology failed, skip.",
@@ -417,8 +542,8 @@ func (sp *SchedulerPlugin) buildSchedulingRequest(tasks map[api.TaskID]*util.XPU
 	var taskList []api.TaskID
 	i := 0
 	for k, v := range tasks {
-		// If task is vxpu task skip
-		if v.IsVXPUTask {
+		// If task is vxpu task or placed by another plugin skip
+		if v.IsVXPUTask || v.ReqXPUName != sp.VxpuName {
 			continue
 		}
 		podCardRequest := allocator.PodCardRequest{
@@ -426,6 +551,15 @@ func (sp *SchedulerPlugin) buildSchedulingRequest(tasks map[api.TaskID]*util.XPU
 			NumberOfCard:   v.ReqXPUNum,
 			IntraBandWidth: v.ReqXPUIntraBandwidth,
 			CardType:       v.ReqXPUType,
+			DeviceUUID:     v.ReqXPUUUID,
+		}
+		for _, c := range v.ContainerRequests {
+			podCardRequest.Containers = append(podCardRequest.Containers, allocator.ContainerCardRequest{
+				NumberOfCard:   c.ReqXPUNum,
+				IntraBandWidth: c.ReqXPUIntraBandwidth,
+				CardType:       c.ReqXPUType,
+				DeviceUUID:     podCardRequest.DeviceUUID,
+			})
 		}
 		if _, ok := v.Annotation[util.TaskSpec]; ok {
 			podCardRequest.TaskName = v.Annotation[util.TaskSpec]
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package plugin
+
+import (
+	"testing"
+
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+func TestPinnedDevicesFree(t *testing.T) {
+	devices := func(ids ...string) []*common.XPUDevice {
+		var result []*common.XPUDevice
+		for i, id := range ids {
+			result = append(result, &common.XPUDevice{Index: i, Id: id})
+		}
+		return result
+	}
+	tests := []struct {
+		name    string
+		use     []string
+		num     int
+		devices []*common.XPUDevice
+		want    bool
+	}{
+		{name: "not pinned", num: 2, devices: devices("GPU-a"), want: true},
+		{name: "pinned devices free", use: []string{"GPU-a", "GPU-b"}, num: 2,
+			devices: devices("GPU-a", "GPU-b", "GPU-c"), want: true},
+		{name: "one pinned device of two on the node", use: []string{"GPU-a", "GPU-b"}, num: 2,
+			devices: devices("GPU-a", "GPU-c"), want: false},
+		{name: "no pinned device on the node", use: []string{"GPU-a"}, num: 1, devices: devices("GPU-c")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			xpuTask := &util.XPUTask{TaskResource: &util.TaskResource{ReqXPUNum: tt.num,
+				ReqXPUUUID: util.XPUUUIDRequest{Use: tt.use}}}
+			if got := pinnedDevicesFree(xpuTask, tt.devices); got != tt.want {
+				t.Errorf("pinnedDevicesFree() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package plugin implements xpu scheduler plugin
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/allocator"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+	vcutil "volcano.sh/volcano/pkg/scheduler/util"
+)
+
+const (
+	// maxExactVictimCandidates above this number of candidates victims are chosen greedily
+	// instead of trying every combination
+	maxExactVictimCandidates = 12
+)
+
+// XPUVictimsFn select victims for the preemptor, called by volcano frame in preempt and reclaim actions
+func (sh *ScheduleHandler) XPUVictimsFn(preemptor *api.TaskInfo, preemptees []*api.TaskInfo) ([]*api.TaskInfo, int) {
+	if sh == nil || preemptor == nil {
+		klog.V(util.LogErrorLevel).Infof("XPUVictimsFn failed %s.", util.ArgumentError)
+		return nil, vcutil.Abstain
+	}
+	sJob, ok := sh.Jobs[preemptor.Job]
+	if !ok || !IsXPUTask(sJob, preemptor) {
+		return nil, vcutil.Abstain
+	}
+	handler := sJob.getHandler(preemptor)
+	if handler == nil {
+		return nil, vcutil.Abstain
+	}
+
+	preempteesOfNodes := make(map[string][]*api.TaskInfo)
+	for _, preemptee := range preemptees {
+		preempteesOfNodes[preemptee.NodeName] = append(preempteesOfNodes[preemptee.NodeName], preemptee)
+	}
+	var victims []*api.TaskInfo
+	for nodeName, tasks := range preempteesOfNodes {
+		node := sh.getNodeInfo(nodeName)
+		if node == nil {
+			klog.V(util.LogWarningLevel).Infof("XPUVictimsFn node %s not exist in session.", nodeName)
+			continue
+		}
+		nodeVictims, err := handler.SelectVictims(sJob, preemptor, node, tasks, sh)
+		if err != nil {
+			klog.V(util.LogDebugLevel).Infof("XPUVictimsFn task %s select victims on node %s failed: %v",
+				preemptor.Name, nodeName, err)
+			continue
+		}
+		victims = append(victims, nodeVictims...)
+	}
+	klog.V(util.LogDebugLevel).Infof("XPUVictimsFn task %s select %d victims from %d preemptees.",
+		preemptor.Name, len(victims), len(preemptees))
+	return victims, vcutil.Permit
+}
+
+func (sh *ScheduleHandler) getNodeInfo(nodeName string) *api.NodeInfo {
+	for _, node := range sh.Nodes {
+		if node != nil && node.Name == nodeName {
+			return node
+		}
+	}
+	return nil
+}
+
+// getXPUDevicesForPreemption get the xpu devices of node, including pre-allocation of topology
+// scheduling made by other jobs
+func (sh *ScheduleHandler) getXPUDevicesForPreemption(sJob *SchedulerJob, handler XPUSchedulerPlugin,
+	node *api.NodeInfo) map[int]*common.XPUDevice {
+	xpuDevices := handler.GetXPUDevicesFromNode(node)
+	otherJobs := make(map[api.JobID]*SchedulerJob, len(sh.Jobs))
+	for jobID, job := range sh.Jobs {
+		if jobID != sJob.Id {
+			otherJobs[jobID] = job
+		}
+	}
+	inUseDevicesOfTopology := GetXPUDevicesFromTopologyScheduleResult(otherJobs, handler.GetPluginName())
+	UpdateXPUDevicesFromTopologyResults(xpuDevices, inUseDevicesOfTopology[node.Name])
+	return xpuDevices
+}
+
+// SelectVictims select the smallest set of victims on node whose released xpu slices make the task fit.
+// If the task already fits without releasing anything, there is no victim.
+func (sp *SchedulerPlugin) SelectVictims(sJob *SchedulerJob, task *api.TaskInfo, node *api.NodeInfo,
+	preemptees []*api.TaskInfo, sh *ScheduleHandler) ([]*api.TaskInfo, error) {
+	if sp == nil || sJob == nil || task == nil || node == nil || sh == nil {
+		return nil, errors.New(util.ArgumentError)
+	}
+	xpuTask, ok := sJob.Tasks[task.UID]
+	if !ok {
+		return nil, fmt.Errorf("task %s is not exist in job %s", task.Name, sJob.Id)
+	}
+	xpuDevices := sh.getXPUDevicesForPreemption(sJob, sp, node)
+	if len(xpuDevices) == 0 {
+		return nil, fmt.Errorf("node %s has no available %s devices", node.Name, sp.PluginName)
+	}
+
+	fit := func(victims []*api.TaskInfo) bool {
+		devices := cloneXPUDevices(xpuDevices)
+		for _, victim := range victims {
+			sp.releaseXPUDevicesOfPod(victim.Pod, devices)
+		}
+		if sp.Config.TopologyEnable && !xpuTask.IsVXPUTask {
+			return sp.topologyFit(task, xpuTask, node, devices)
+		}
+		fit, _, err := sp.calculateDecision(task.Pod, node, devices, nil)
+		return err == nil && fit
+	}
+	if fit(nil) {
+		return []*api.TaskInfo{}, nil
+	}
+
+	candidates := sp.getVictimCandidates(preemptees, xpuDevices)
+	victims := searchVictims(candidates, fit)
+	if len(victims) == 0 {
+		return nil, fmt.Errorf("no victims on node %s can release enough %s devices", node.Name, sp.PluginName)
+	}
+	klog.V(util.LogDebugLevel).Infof("%s SelectVictims task %s node %s victims: %d of %d candidates",
+		sp.PluginName, task.Name, node.Name, len(victims), len(candidates))
+	return victims, nil
+}
+
+// topologyFit check whether the whole card task can be placed on node by the topology allocator
+func (sp *SchedulerPlugin) topologyFit(task *api.TaskInfo, xpuTask *util.XPUTask, node *api.NodeInfo,
+	xpuDevices map[int]*common.XPUDevice) bool {
+	unUseXPUDevicesOfNodes := map[string][]*common.XPUDevice{
+		node.Name: GetXPUDevicesNotInUse(xpuDevices, nil, node.Name),
+	}
+	topologyOfNodes := sp.getXPUTopology([]*api.NodeInfo{node}, unUseXPUDevicesOfNodes)
+	if len(topologyOfNodes) == 0 {
+		return false
+	}
+	podRequests, _ := sp.buildSchedulingRequest(map[api.TaskID]*util.XPUTask{task.UID: xpuTask})
+	sp.setAllocatorConfig()
+	_, err := allocator.Allocate(topologyOfNodes, podRequests, nil)
+	return err == nil
+}
+
+// getVictimCandidates get the preemptees holding devices on the node, the lower priority and
+// the more resources released, the earlier the candidate is tried
+func (sp *SchedulerPlugin) getVictimCandidates(preemptees []*api.TaskInfo,
+	xpuDevices map[int]*common.XPUDevice) []*api.TaskInfo {
+	var candidates []*api.TaskInfo
+	released := make(map[api.TaskID]float64)
+	for _, preemptee := range preemptees {
+		if preemptee == nil || preemptee.Pod == nil {
+			continue
+		}
+		score := 0.0
+		for _, cds := range DecodePodDevices(preemptee.Pod.Annotations[sp.AssignedXPUsToPodAnno]) {
+			for _, cd := range cds {
+				dev, ok := xpuDevices[cd.Index]
+				if !ok || dev.Id != cd.Id || dev.MemoryCapacity() == 0 {
+					continue
+				}
+				score += util.CoreWeight*float64(cd.UsedCores)/util.Base100 +
+					util.MemoryWeight*float64(cd.UsedMemory)/float64(dev.MemoryCapacity())
+			}
+		}
+		if score == 0 {
+			continue
+		}
+		released[preemptee.UID] = score
+		candidates = append(candidates, preemptee)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Priority != candidates[j].Priority {
+			return candidates[i].Priority < candidates[j].Priority
+		}
+		return released[candidates[i].UID] > released[candidates[j].UID]
+	})
+	return candidates
+}
+
+// searchVictims find the smallest subset of candidates making fit true. Every combination is tried in
+// increasing size when candidates are few, otherwise candidates are added in order and redundant ones removed.
+func searchVictims(candidates []*api.TaskInfo, fit func([]*api.TaskInfo) bool) []*api.TaskInfo {
+	if len(candidates) <= maxExactVictimCandidates {
+		for size := 1; size <= len(candidates); size++ {
+			if victims := searchVictimsOfSize(candidates, size, fit); victims != nil {
+				return victims
+			}
+		}
+		return nil
+	}
+
+	var victims []*api.TaskInfo
+	for _, candidate := range candidates {
+		victims = append(victims, candidate)
+		if fit(victims) {
+			break
+		}
+	}
+	if !fit(victims) {
+		return nil
+	}
+	for i := len(victims) - 1; i >= 0; i-- {
+		rest := append(append([]*api.TaskInfo{}, victims[:i]...), victims[i+1:]...)
+		if fit(rest) {
+			victims = rest
+		}
+	}
+	return victims
+}
+
+func searchVictimsOfSize(candidates []*api.TaskInfo, size int, fit func([]*api.TaskInfo) bool) []*api.TaskInfo {
+	indexes := make([]int, size)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	victims := make([]*api.TaskInfo, size)
+	for {
+		for i, idx := range indexes {
+			victims[i] = candidates[idx]
+		}
+		if fit(victims) {
+			return victims
+		}
+		// move to the next combination in lexicographic order
+		i := size - 1
+		for i >= 0 && indexes[i] == len(candidates)-size+i {
+			i--
+		}
+		if i < 0 {
+			return nil
+		}
+		indexes[i]++
+		for j := i + 1; j < size; j++ {
+			indexes[j] = indexes[j-1] + 1
+		}
+	}
+}
+
+// releaseXPUDevicesOfPod give back the xpu slices recorded in the pod annotation to the devices
+func (sp *SchedulerPlugin) releaseXPUDevicesOfPod(pod *v1.Pod, xpuDevices map[int]*common.XPUDevice) {
+	if pod == nil || xpuDevices == nil {
+		return
+	}
+	for _, cds := range DecodePodDevices(pod.Annotations[sp.AssignedXPUsToPodAnno]) {
+		for _, cd := range cds {
+			dev, ok := xpuDevices[cd.Index]
+			if !ok || dev.Id != cd.Id {
+				continue
+			}
+			if dev.UsedMemory > cd.UsedMemory {
+				dev.UsedMemory -= cd.UsedMemory
+			} else {
+				dev.UsedMemory = 0
+			}
+			if dev.UsedCores > cd.UsedCores {
+				dev.UsedCores -= cd.UsedCores
+			} else {
+				dev.UsedCores = 0
+			}
+			dev.ReleaseVid(cd.Vid)
+			dev.RemoveQos(qosOf(cd))
+			dev.InUse = !dev.UsedVids.Empty()
+		}
+	}
+}
+
+func cloneXPUDevices(xpuDevices map[int]*common.XPUDevice) map[int]*common.XPUDevice {
+	clone := make(map[int]*common.XPUDevice, len(xpuDevices))
+	for index, dev := range xpuDevices {
+		clone[index] = dev.Clone()
+	}
+	return clone
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+// testVictim a running task holding cores and 1GiB of memory of the device index of node
+type testVictim struct {
+	name     string
+	index    int
+	cores    int
+	priority int32
+}
+
+func TestSelectVictims(t *testing.T) {
+	manyVictims := []testVictim{{name: "low", index: 1, cores: 16}}
+	for i := 0; i < 7; i++ {
+		manyVictims = append(manyVictims, testVictim{name: fmt.Sprintf("a%d", i), cores: 14, priority: 1})
+	}
+	for i := 0; i < 5; i++ {
+		manyVictims = append(manyVictims, testVictim{name: fmt.Sprintf("b%d", i), index: 1, cores: 16, priority: 2})
+	}
+	tests := []struct {
+		name     string
+		devices  int
+		topology string
+		victims  []testVictim
+		// cores 0 asks for whole cards
+		num, cores, bandwidth int
+		want                  []string
+		wantErr               bool
+	}{
+		{name: "minimal victim set", devices: 1, victims: []testVictim{{name: "v1", cores: 20, priority: 1},
+			{name: "v2", cores: 20, priority: 1}, {name: "v3", cores: 60, priority: 2}},
+			num: 1, cores: 50, want: []string{"v3"}},
+		{name: "several victims needed", devices: 1, victims: []testVictim{{name: "v1", cores: 30},
+			{name: "v2", cores: 30}, {name: "v3", cores: 30}}, num: 1, cores: 50, want: []string{"v1", "v2"}},
+		{name: "task already fits", devices: 2, victims: []testVictim{{name: "v1", cores: 50}},
+			num: 1, cores: 50, want: []string{}},
+		{name: "no victims release enough", devices: 1, victims: []testVictim{{name: "v1", cores: 30}},
+			num: 2, cores: 50, wantErr: true},
+		// the lowest priority candidates are taken first and the redundant ones left out
+		{name: "more than 12 candidates", devices: 2, victims: manyVictims, num: 1, cores: 50,
+			want: []string{"a0", "a1", "a2", "a3"}},
+		// two whole cards linked by 100 are only freed on cards 0 and 2
+		{name: "topology preemptor", devices: 3, topology: "0,10,100;10,0,10;100,10,0",
+			victims: []testVictim{{name: "v1", cores: 50}, {name: "v2", index: 1, cores: 30},
+				{name: "v3", index: 2, cores: 20}, {name: "v4", index: 2, cores: 20}},
+			num: 2, bandwidth: 100, want: []string{"v1", "v3", "v4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sp := testGPUPlugin()
+			node := testGPUNode("node-1", tt.devices)
+			if tt.topology != "" {
+				sp.Config.TopologyEnable = true
+				node.Node.Annotations[util.NodeGPUTopologyAnnotation] = tt.topology
+			}
+			tasks := []*api.TaskInfo{}
+			vids := map[int]int{}
+			for _, v := range tt.victims {
+				victim := testGPUTask(v.name, api.JobID("job-"+v.name), 1, v.cores, 1)
+				victim.Priority = v.priority
+				testRunningOn(victim, node, fmt.Sprintf("%d,GPU-node-1-%d,A100,1024,%d,%d:", v.index, v.index,
+					v.cores, vids[v.index]))
+				vids[v.index]++
+				tasks = append(tasks, victim)
+			}
+			memory := 0
+			if tt.cores != 0 {
+				memory = 1
+			}
+			preemptor := testGPUTask("preemptor", "job-preemptor", tt.num, tt.cores, memory)
+			if tt.bandwidth != 0 {
+				preemptor.Pod.Spec.Containers[0].Resources.Limits[v1.ResourceName(
+					util.XPUTopologyIntraBandwidthAnnotation)] = resource.MustParse(fmt.Sprint(tt.bandwidth))
+			}
+			sh, _ := testScheduleHandler(t, sp, []*api.NodeInfo{node}, append(tasks, preemptor)...)
+
+			victims, err := sp.SelectVictims(sh.Jobs[preemptor.Job], preemptor, node, tasks, sh)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SelectVictims() error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			names := make([]string, 0, len(victims))
+			for _, victim := range victims {
+				names = append(names, victim.Name)
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("SelectVictims() = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
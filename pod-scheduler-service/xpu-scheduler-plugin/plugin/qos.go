@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package plugin implements xpu scheduler plugin
+package plugin
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const (
+	// QosGuaranteed the container gets its cores and never goes beyond them
+	QosGuaranteed = "guaranteed"
+	// QosBurstable the container gets its cores and may use the cores the others leave idle on the card
+	QosBurstable = "burstable"
+	// QosBestEffort the container asks for no cores and runs on the cores the others leave idle
+	QosBestEffort = "best-effort"
+
+	qosPairFields = 2
+)
+
+// IsQosClass check whether the class is a known vxpu qos class
+func IsQosClass(class string) bool {
+	return class == QosGuaranteed || class == QosBurstable || class == QosBestEffort
+}
+
+// defaultQosClass the class of a container not telling its own, best-effort without cores, guaranteed with
+func defaultQosClass(cores int) string {
+	if cores == 0 {
+		return QosBestEffort
+	}
+	return QosGuaranteed
+}
+
+// getContainerQos get the qos class of the xpu container asking for the cores, the default class if it tells
+// none. Several or unknown classes are refused, as are best-effort with cores and the other classes without
+func getContainerQos(container *v1.Container, xpuQos string, cores int) (string, error) {
+	var classes []string
+	for k := range container.Resources.Limits {
+		if strings.HasPrefix(string(k), xpuQos) {
+			classes = append(classes, strings.TrimPrefix(string(k), xpuQos))
+		}
+	}
+	if len(classes) == 0 {
+		return defaultQosClass(cores), nil
+	}
+	if len(classes) > 1 {
+		sort.Strings(classes)
+		return "", fmt.Errorf("container %s sets several qos classes %s", container.Name,
+			strings.Join(classes, util.Comma))
+	}
+	class := classes[0]
+	if !IsQosClass(class) {
+		return "", fmt.Errorf("container %s sets unknown qos class %s", container.Name, class)
+	}
+	if (class == QosBestEffort) != (cores == 0) {
+		return "", fmt.Errorf("container %s qos class %s does not match its %d cores, only %s asks for no cores",
+			container.Name, class, cores, QosBestEffort)
+	}
+	return class, nil
+}
+
+// CheckXPUQos check the qos class of every xpu container of the pod
+func CheckXPUQos(pod *v1.Pod) error {
+	if pod == nil {
+		return nil
+	}
+	for _, xpuName := range []string{util.VGPUName, util.VNPUName} {
+		names := getXPUResourceNames(xpuName)
+		for i := range pod.Spec.Containers {
+			container := &pod.Spec.Containers[i]
+			containerResource := GetXPUResourceFromContainer(container, xpuName)
+			if containerResource.ReqXPUNum == 0 {
+				continue
+			}
+			if _, err := getContainerQos(container, names.qos, containerResource.ReqXPUCores); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// QosIsolation the qos classes whose containers may not share a card, each class maps to the classes
+// it may not share a card with
+type QosIsolation map[string][]string
+
+// ParseQosIsolation parse the pairs of classes not sharing a card like "guaranteed:best-effort,burstable:best-effort"
+func ParseQosIsolation(value string) (QosIsolation, error) {
+	isolation := QosIsolation{}
+	for _, item := range strings.Split(value, util.Comma) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != qosPairFields {
+			return nil, fmt.Errorf("invalid qos isolation %q", item)
+		}
+		a, b := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if !IsQosClass(a) || !IsQosClass(b) {
+			return nil, fmt.Errorf("unknown qos class in qos isolation %q", item)
+		}
+		isolation.add(a, b)
+		if a != b {
+			isolation.add(b, a)
+		}
+	}
+	return isolation, nil
+}
+
+func (qi QosIsolation) add(class string, other string) {
+	for _, c := range qi[class] {
+		if c == other {
+			return
+		}
+	}
+	qi[class] = append(qi[class], other)
+}
+
+// Conflicts the classes a container of the class may not share a card with
+func (qi QosIsolation) Conflicts(class string) []string {
+	return qi[class]
+}
+
+// qosOf the qos class of a placed container, derived from its cores when the scheduler did not record it
+func qosOf(cd common.ContainerDevice) string {
+	if cd.Qos != "" {
+		return cd.Qos
+	}
+	return defaultQosClass(cd.UsedCores)
+}
@@ -0,0 +1,257 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package plugin implements xpu scheduler plugin
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const (
+	quotaCores  = "cores"
+	quotaMemory = "memory"
+	quotaCards  = "cards"
+)
+
+// XPUResource xpu resources counted by quotas
+type XPUResource struct {
+	// Cores sum of the cores, 100 is a whole card
+	Cores int
+	// Memory sum of the memory in MiB
+	Memory int
+	// Cards number of whole cards, the slices with all the cores of a card
+	Cards int
+}
+
+func (r *XPUResource) add(other XPUResource) {
+	r.Cores += other.Cores
+	r.Memory += other.Memory
+	r.Cards += other.Cards
+}
+
+func (r *XPUResource) sub(other XPUResource) {
+	r.add(XPUResource{Cores: -other.Cores, Memory: -other.Memory, Cards: -other.Cards})
+	if r.Cores < 0 {
+		r.Cores = 0
+	}
+	if r.Memory < 0 {
+		r.Memory = 0
+	}
+	if r.Cards < 0 {
+		r.Cards = 0
+	}
+}
+
+// exceed check whether the resource goes beyond the quota, zero quota items are not limited
+func (r XPUResource) exceed(quota XPUResource) string {
+	if quota.Cores > 0 && r.Cores > quota.Cores {
+		return fmt.Sprintf("xpu cores %d exceed quota %d", r.Cores, quota.Cores)
+	}
+	if quota.Memory > 0 && r.Memory > quota.Memory {
+		return fmt.Sprintf("xpu memory %dMi exceed quota %dMi", r.Memory, quota.Memory)
+	}
+	if quota.Cards > 0 && r.Cards > quota.Cards {
+		return fmt.Sprintf("xpu cards %d exceed quota %d", r.Cards, quota.Cards)
+	}
+	return ""
+}
+
+// xpuQuotaUsage the xpu resources used by queues and namespaces in the session
+type xpuQuotaUsage struct {
+	queues     map[string]*XPUResource
+	namespaces map[string]*XPUResource
+	sync.Mutex
+}
+
+// ParseXPUQuotas parse quotas like "name1:cores=400,memory=80,cards=2;name2:cores=100",
+// memory is given in Gi as the vxpu memory resource
+func ParseXPUQuotas(s string) (map[string]XPUResource, error) {
+	quotas := make(map[string]XPUResource)
+	for _, item := range strings.Split(s, util.Semicolon) {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		nameAndLimits := strings.SplitN(item, ":", util.Base2)
+		name := strings.TrimSpace(nameAndLimits[0])
+		if len(nameAndLimits) != util.Base2 || name == "" {
+			return nil, fmt.Errorf("invalid quota %s", item)
+		}
+		var quota XPUResource
+		for _, limit := range strings.Split(nameAndLimits[1], util.Comma) {
+			kv := strings.SplitN(limit, "=", util.Base2)
+			if len(kv) != util.Base2 {
+				return nil, fmt.Errorf("invalid quota %s of %s", limit, name)
+			}
+			value, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+			if err != nil || value < 0 {
+				return nil, fmt.Errorf("invalid quota %s of %s", limit, name)
+			}
+			switch strings.TrimSpace(kv[0]) {
+			case quotaCores:
+				quota.Cores = value
+			case quotaMemory:
+				quota.Memory = value * util.Base1024
+			case quotaCards:
+				quota.Cards = value
+			default:
+				return nil, fmt.Errorf("unknown quota %s of %s", kv[0], name)
+			}
+		}
+		quotas[name] = quota
+	}
+	return quotas, nil
+}
+
+// getPodXPUResource the xpu resources recorded in the pod annotation
+func getPodXPUResource(pd PodDevices) XPUResource {
+	var resource XPUResource
+	for _, cds := range pd {
+		for _, cd := range cds {
+			resource.Cores += cd.UsedCores
+			resource.Memory += int(cd.UsedMemory)
+			if cd.UsedCores == util.Base100 {
+				resource.Cards++
+			}
+		}
+	}
+	return resource
+}
+
+// getTaskXPURequest the xpu resources the task asks for, the memory given in percentage is
+// only known once the devices are chosen and is not counted
+func getTaskXPURequest(xpuTask *util.XPUTask) XPUResource {
+	if xpuTask == nil || xpuTask.TaskResource == nil {
+		return XPUResource{}
+	}
+	request := XPUResource{Cores: xpuTask.ReqXPUCores, Memory: xpuTask.ReqXPUMem}
+	for _, c := range xpuTask.ContainerRequests {
+		if c.ReqXPUCores == util.Base100 {
+			request.Cards += c.ReqXPUNum
+		}
+	}
+	return request
+}
+
+// InitQuotaUsage sum the xpu resources of the placed pods by queue and namespace
+func (sh *ScheduleHandler) InitQuotaUsage(jobs map[api.JobID]*api.JobInfo) {
+	sh.quotaUsage = &xpuQuotaUsage{
+		queues:     make(map[string]*XPUResource),
+		namespaces: make(map[string]*XPUResource),
+	}
+	if len(sh.QueueQuotas) == 0 && len(sh.NamespaceQuotas) == 0 {
+		return
+	}
+	for jobID, job := range jobs {
+		sJob, ok := sh.Jobs[jobID]
+		if !ok {
+			continue
+		}
+		for _, task := range job.Tasks {
+			if task.NodeName == "" || task.Status == api.Succeeded || task.Status == api.Failed {
+				continue
+			}
+			sh.addQuotaUsage(sJob, task)
+		}
+	}
+}
+
+func (sh *ScheduleHandler) addQuotaUsage(sJob *SchedulerJob, task *api.TaskInfo) {
+	sh.updateQuotaUsage(sJob, task, (*XPUResource).add)
+}
+
+func (sh *ScheduleHandler) subQuotaUsage(sJob *SchedulerJob, task *api.TaskInfo) {
+	sh.updateQuotaUsage(sJob, task, (*XPUResource).sub)
+}
+
+func (sh *ScheduleHandler) updateQuotaUsage(sJob *SchedulerJob, task *api.TaskInfo,
+	update func(*XPUResource, XPUResource)) {
+	if sh.quotaUsage == nil || task.Pod == nil {
+		return
+	}
+	handler := sJob.getHandler(task)
+	if handler == nil {
+		return
+	}
+	resource := getPodXPUResource(handler.GetXPUDevicesOfPod(task.Pod))
+	sh.quotaUsage.Lock()
+	defer sh.quotaUsage.Unlock()
+	if _, ok := sh.quotaUsage.queues[sJob.Queue]; !ok {
+		sh.quotaUsage.queues[sJob.Queue] = &XPUResource{}
+	}
+	update(sh.quotaUsage.queues[sJob.Queue], resource)
+	if _, ok := sh.quotaUsage.namespaces[sJob.NameSpace]; !ok {
+		sh.quotaUsage.namespaces[sJob.NameSpace] = &XPUResource{}
+	}
+	update(sh.quotaUsage.namespaces[sJob.NameSpace], resource)
+}
+
+// CheckQuota check whether the request can be added to the usage of the queue and namespace of the job
+func (sh *ScheduleHandler) CheckQuota(sJob *SchedulerJob, request XPUResource) error {
+	return sh.checkQuota(sJob, request, true)
+}
+
+func (sh *ScheduleHandler) checkQuota(sJob *SchedulerJob, request XPUResource, withUsage bool) error {
+	if sh == nil || sJob == nil || sh.quotaUsage == nil {
+		return nil
+	}
+	sh.quotaUsage.Lock()
+	defer sh.quotaUsage.Unlock()
+	checks := []struct {
+		kind   string
+		name   string
+		quotas map[string]XPUResource
+		usages map[string]*XPUResource
+	}{
+		{kind: "queue", name: sJob.Queue, quotas: sh.QueueQuotas, usages: sh.quotaUsage.queues},
+		{kind: "namespace", name: sJob.NameSpace, quotas: sh.NamespaceQuotas, usages: sh.quotaUsage.namespaces},
+	}
+	for _, check := range checks {
+		quota, ok := check.quotas[check.name]
+		if !ok {
+			continue
+		}
+		total := request
+		if usage, ok := check.usages[check.name]; ok && withUsage {
+			total.add(*usage)
+		}
+		if reason := total.exceed(quota); reason != "" {
+			klog.V(util.LogDebugLevel).Infof("job %s %s %s %s.", sJob.ReferenceName, check.kind, check.name, reason)
+			return newNodeFailure(ReasonQuotaExceeded, fmt.Sprintf("%s %s %s", check.kind, check.name, reason))
+		}
+	}
+	return nil
+}
+
+// checkJobQuota check the job does not ask for more than the quotas, pending is true to count only
+// the tasks not placed yet on top of the usage, otherwise the whole job is checked alone
+func (sh *ScheduleHandler) checkJobQuota(sJob *SchedulerJob, pending bool) error {
+	var request XPUResource
+	for _, xpuTask := range sJob.Tasks {
+		if pending && xpuTask.NodeName != "" {
+			continue
+		}
+		request.add(getTaskXPURequest(xpuTask))
+	}
+	return sh.checkQuota(sJob, request, pending)
+}
+
+// JobEnqueueable reject the job whose pending tasks go beyond the quota of its queue or namespace
+func (sh *ScheduleHandler) JobEnqueueable(job *api.JobInfo) error {
+	if sh == nil || job == nil {
+		return nil
+	}
+	sJob, ok := sh.Jobs[job.UID]
+	if !ok {
+		return nil
+	}
+	return sh.checkJobQuota(sJob, true)
+}
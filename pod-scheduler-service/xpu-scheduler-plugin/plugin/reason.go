@@ -0,0 +1,242 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package plugin implements xpu scheduler plugin
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/framework"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+// ReasonType typed reason why a node can not host a task
+type ReasonType string
+
+const (
+	// ReasonInsufficientMemory the cards have not enough free memory
+	ReasonInsufficientMemory ReasonType = "InsufficientMemory"
+	// ReasonInsufficientCores the cards have not enough free cores, or are not free for an exclusive request
+	ReasonInsufficientCores ReasonType = "InsufficientCores"
+	// ReasonVidExhausted the cards have no free vid slot left
+	ReasonVidExhausted ReasonType = "VidSlotsExhausted"
+	// ReasonCardTypeMismatch the cards are not of the requested type
+	ReasonCardTypeMismatch ReasonType = "CardTypeMismatch"
+	// ReasonNotEnoughCards the node has fewer qualified cards than requested
+	ReasonNotEnoughCards ReasonType = "NotEnoughCards"
+	// ReasonHandshakeExpired the device plugin of the node has not reported its devices recently
+	ReasonHandshakeExpired ReasonType = "HandshakeExpired"
+	// ReasonNoDevices the node has no xpu devices registered
+	ReasonNoDevices ReasonType = "NoDevices"
+	// ReasonTopologyBandwidth no group of qualified cards meets the bandwidth requirement
+	ReasonTopologyBandwidth ReasonType = "TopologyBandwidthUnmet"
+	// ReasonNumaUnaligned no group of qualified cards is in one NUMA node, or in the NUMA nodes of the pod
+	ReasonNumaUnaligned ReasonType = "NumaUnaligned"
+	// ReasonTopologyAllocation the node is not part of the topology allocation of the job
+	ReasonTopologyAllocation ReasonType = "TopologyAllocation"
+	// ReasonSelectorMismatch the node labels do not meet the job selector
+	ReasonSelectorMismatch ReasonType = "SelectorMismatch"
+	// ReasonQuotaExceeded the task goes beyond the xpu quota of its queue or namespace
+	ReasonQuotaExceeded ReasonType = "QuotaExceeded"
+	// ReasonDeviceUUIDFiltered the cards are not among the pinned uuids or are among the excluded ones
+	ReasonDeviceUUIDFiltered ReasonType = "DeviceUUIDFiltered"
+	// ReasonQosConflict the cards host containers of a qos class the container may not share a card with
+	ReasonQosConflict ReasonType = "QosConflict"
+	// ReasonDeviceReserved the cards are reserved for a starving topology job
+	ReasonDeviceReserved ReasonType = "DeviceReserved"
+	// ReasonOther any other failure
+	ReasonOther ReasonType = "Other"
+)
+
+var reasonMessages = map[ReasonType]string{
+	ReasonInsufficientMemory: "insufficient memory",
+	ReasonInsufficientCores:  "insufficient cores",
+	ReasonVidExhausted:       "vid slots exhausted",
+	ReasonCardTypeMismatch:   "card type mismatch",
+	ReasonDeviceUUIDFiltered: "filtered by device uuid",
+	ReasonQosConflict:        "qos class conflict",
+	ReasonDeviceReserved:     "reserved for a topology job",
+}
+
+// NodeFailure typed error of a node failing the predicate of a task. The message is kept the same
+// for the nodes failing the same way so the failures can be aggregated
+type NodeFailure struct {
+	Type    ReasonType
+	Message string
+}
+
+// Error message of the failure
+func (f *NodeFailure) Error() string {
+	return f.Message
+}
+
+func newNodeFailure(reasonType ReasonType, message string) *NodeFailure {
+	return &NodeFailure{Type: reasonType, Message: message}
+}
+
+// cardsFailure the failure of a container request from the reasons of the unqualified cards,
+// the reason shared by most cards is reported
+func cardsFailure(reasons map[ReasonType]int, cards int) *NodeFailure {
+	return cardsFailureOf(reasons, cards, "cards")
+}
+
+// uuidCardsFailure the failure of a container request restricted by device uuids. The cards filtered by
+// the uuids are reported only when no card is left, else the failure of the cards left is reported
+func uuidCardsFailure(reasons map[ReasonType]int, cards int, uuids util.XPUUUIDRequest) *NodeFailure {
+	filtered := reasons[ReasonDeviceUUIDFiltered]
+	if uuids.IsEmpty() {
+		return cardsFailure(reasons, cards)
+	}
+	if filtered == cards {
+		if len(uuids.Use) != 0 {
+			return newNodeFailure(ReasonDeviceUUIDFiltered, "pinned devices not on node")
+		}
+		return newNodeFailure(ReasonDeviceUUIDFiltered, "all cards excluded by uuid")
+	}
+	left := make(map[ReasonType]int, len(reasons))
+	for reasonType, count := range reasons {
+		if reasonType != ReasonDeviceUUIDFiltered {
+			left[reasonType] = count
+		}
+	}
+	if len(uuids.Use) != 0 {
+		return cardsFailureOf(left, cards-filtered, "pinned cards")
+	}
+	return cardsFailureOf(left, cards-filtered, "cards not excluded")
+}
+
+func cardsFailureOf(reasons map[ReasonType]int, cards int, subject string) *NodeFailure {
+	var dominant ReasonType
+	for reasonType, count := range reasons {
+		if dominant == "" || count > reasons[dominant] || (count == reasons[dominant] && reasonType < dominant) {
+			dominant = reasonType
+		}
+	}
+	if dominant == "" {
+		return newNodeFailure(ReasonNotEnoughCards, "not enough cards")
+	}
+	scope := "some"
+	if reasons[dominant] == cards {
+		scope = "all"
+	}
+	return newNodeFailure(dominant, fmt.Sprintf("%s on %s %s", reasonMessages[dominant], scope, subject))
+}
+
+// toNodeFailure the typed failure of an error, the untyped ones are reported as ReasonOther
+func toNodeFailure(err error) NodeFailure {
+	var failure *NodeFailure
+	if errors.As(err, &failure) {
+		return *failure
+	}
+	return NodeFailure{Type: ReasonOther, Message: err.Error()}
+}
+
+// AddNodeFailure record the failure of node for the job
+func (r *UnschedulableReason) AddNodeFailure(nodeName string, err error) {
+	if r == nil || r.Mutex == nil || err == nil {
+		return
+	}
+	failure := toNodeFailure(err)
+	r.Lock()
+	defer r.Unlock()
+	if _, ok := r.NodeFailures[failure]; !ok {
+		r.NodeFailures[failure] = map[string]struct{}{}
+	}
+	r.NodeFailures[failure][nodeName] = struct{}{}
+}
+
+// Summary aggregate the failures like "5 nodes: insufficient memory on all cards; 2 nodes: handshake expired",
+// the most frequent failure first, whose type is returned with the summary
+func (r *UnschedulableReason) Summary() (ReasonType, string) {
+	if r == nil || r.Mutex == nil {
+		return "", ""
+	}
+	r.Lock()
+	defer r.Unlock()
+	failures := make([]NodeFailure, 0, len(r.NodeFailures))
+	for failure := range r.NodeFailures {
+		failures = append(failures, failure)
+	}
+	if len(failures) == 0 {
+		return "", ""
+	}
+	sort.Slice(failures, func(i, j int) bool {
+		ni, nj := len(r.NodeFailures[failures[i]]), len(r.NodeFailures[failures[j]])
+		if ni != nj {
+			return ni > nj
+		}
+		return failures[i].Message < failures[j].Message
+	})
+	parts := make([]string, 0, len(failures))
+	for _, failure := range failures {
+		nodes := len(r.NodeFailures[failure])
+		unit := "nodes"
+		if nodes == 1 {
+			unit = "node"
+		}
+		parts = append(parts, fmt.Sprintf("%d %s: %s", nodes, unit, failure.Message))
+	}
+	return failures[0].Type, strings.Join(parts, "; ")
+}
+
+// RecordUnschedulableReason publish the aggregated node failures of the job as a PodGroup condition and an event
+func (sh *ScheduleHandler) RecordUnschedulableReason(ssn *framework.Session, job *api.JobInfo) {
+	if sh == nil || ssn == nil || job == nil || job.PodGroup == nil {
+		return
+	}
+	sJob, ok := sh.Jobs[job.UID]
+	if !ok {
+		return
+	}
+	reasonType, summary := sJob.UnschedulableReason.Summary()
+	if summary == "" {
+		return
+	}
+	klog.V(util.LogInfoLevel).Infof("job %s unschedulable: %s.", sJob.ReferenceName, summary)
+	sh.updatePodGroupCondition(job, string(reasonType), summary, isReasonType)
+	ssn.RecordPodGroupEvent(job.PodGroup, v1.EventTypeWarning, string(reasonType), summary)
+}
+
+func isReasonType(reason string) bool {
+	switch ReasonType(reason) {
+	case ReasonInsufficientMemory, ReasonInsufficientCores, ReasonVidExhausted, ReasonCardTypeMismatch,
+		ReasonNotEnoughCards, ReasonHandshakeExpired, ReasonNoDevices, ReasonTopologyBandwidth, ReasonNumaUnaligned,
+		ReasonTopologyAllocation, ReasonSelectorMismatch, ReasonQuotaExceeded, ReasonDeviceUUIDFiltered,
+		ReasonQosConflict, ReasonDeviceReserved, ReasonOther:
+		return true
+	default:
+		return false
+	}
+}
+
+// updatePodGroupCondition set the unschedulable condition of the PodGroup, the condition whose reason
+// matches replace is updated in place instead of adding another one
+func (sh *ScheduleHandler) updatePodGroupCondition(job *api.JobInfo, reason string, message string,
+	replace func(string) bool) {
+	if len(job.PodGroup.Status.Conditions) == 0 {
+		return
+	}
+	condition := job.PodGroup.Status.Conditions[0].DeepCopy()
+	condition.Type = util.PodGroupUnschedulableType
+	condition.Status = v1.ConditionTrue
+	condition.LastTransitionTime = metav1.Now()
+	condition.TransitionID = string(sh.SessionID)
+	condition.Reason = reason
+	condition.Message = message
+	for k, value := range job.PodGroup.Status.Conditions {
+		if value.Type == util.PodGroupUnschedulableType && replace(value.Reason) {
+			job.PodGroup.Status.Conditions[k] = *condition
+			return
+		}
+	}
+	job.PodGroup.Status.Conditions = append(job.PodGroup.Status.Conditions, *condition)
+}
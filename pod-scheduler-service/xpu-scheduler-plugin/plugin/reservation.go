@@ -0,0 +1,225 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package plugin implements xpu scheduler plugin
+package plugin
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const (
+	// DefaultReservationTimeout seconds the devices stay reserved for a starving topology job
+	DefaultReservationTimeout = 600
+)
+
+// deviceReservation the devices kept for a starving topology job, keyed by node name then device index
+type deviceReservation struct {
+	job     api.JobID
+	xpuName string
+	devices map[string]map[int]struct{}
+	created time.Time
+	expire  time.Time
+}
+
+// reservationCache the device reservations shared by the sessions, at most one per xpu name
+type reservationCache struct {
+	reservations map[string]*deviceReservation
+	// cooldown the jobs whose reservation expired may not reserve again before the time
+	cooldown map[api.JobID]time.Time
+	sync.Mutex
+}
+
+var reservations = &reservationCache{
+	reservations: map[string]*deviceReservation{},
+	cooldown:     map[api.JobID]time.Time{},
+}
+
+// isPendingTopologyJob check whether the job has a topology task of the xpu name not placed on a node yet
+func isPendingTopologyJob(sJob *SchedulerJob, xpuName string) bool {
+	if sJob == nil || sJob.XPUJob == nil {
+		return false
+	}
+	for _, task := range sJob.Tasks {
+		if !task.IsVXPUTask && task.ReqXPUName == xpuName && task.NodeName == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// RefreshReservations drop the reservations which expired or whose job is no longer pending, the jobs
+// whose reservation expired cool down as long as they held it before they may reserve again
+func (sh *ScheduleHandler) RefreshReservations() {
+	now := time.Now()
+	reservations.Lock()
+	defer reservations.Unlock()
+	for job, until := range reservations.cooldown {
+		if now.After(until) {
+			delete(reservations.cooldown, job)
+		}
+	}
+	for xpuName, r := range reservations.reservations {
+		if now.After(r.expire) {
+			klog.V(util.LogInfoLevel).Infof("device reservation of job %s expired, release %v.", r.job, r.devices)
+			reservations.cooldown[r.job] = now.Add(r.expire.Sub(r.created))
+			delete(reservations.reservations, xpuName)
+			continue
+		}
+		if !isPendingTopologyJob(sh.Jobs[r.job], xpuName) {
+			klog.V(util.LogDebugLevel).Infof("job %s is no longer pending, release device reservation %v.",
+				r.job, r.devices)
+			delete(reservations.reservations, xpuName)
+		}
+	}
+}
+
+// isReservationCandidate check whether the job is the one the devices of the xpu name are reserved for,
+// the job holding the reservation, else the pending topology job of the highest priority then the oldest
+func (sh *ScheduleHandler) isReservationCandidate(sJob *SchedulerJob, xpuName string) bool {
+	reservations.Lock()
+	defer reservations.Unlock()
+	if r, ok := reservations.reservations[xpuName]; ok {
+		return r.job == sJob.Id
+	}
+	var candidate *SchedulerJob
+	for _, job := range sh.Jobs {
+		if _, cooling := reservations.cooldown[job.Id]; cooling || !isPendingTopologyJob(job, xpuName) {
+			continue
+		}
+		if candidate == nil || job.Priority > candidate.Priority ||
+			(job.Priority == candidate.Priority && job.CreationTime.Before(candidate.CreationTime)) ||
+			(job.Priority == candidate.Priority && job.CreationTime.Equal(candidate.CreationTime) &&
+				job.Id < candidate.Id) {
+			candidate = job
+		}
+	}
+	return candidate != nil && candidate.Id == sJob.Id
+}
+
+// reserve keep the devices of the allocation for the job until the timeout, an existing reservation
+// of the job is kept as it is
+func (c *reservationCache) reserve(sJob *SchedulerJob, xpuName string, allocation []*util.TopologyScheduleXPUs,
+	timeout time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	if _, ok := c.reservations[xpuName]; ok {
+		return
+	}
+	now := time.Now()
+	r := &deviceReservation{job: sJob.Id, xpuName: xpuName, devices: map[string]map[int]struct{}{},
+		created: now, expire: now.Add(timeout)}
+	for _, result := range allocation {
+		if _, ok := r.devices[result.NodeName]; !ok {
+			r.devices[result.NodeName] = map[int]struct{}{}
+		}
+		for _, index := range result.AllocateXPUs {
+			r.devices[result.NodeName][index] = struct{}{}
+		}
+	}
+	c.reservations[xpuName] = r
+	klog.V(util.LogInfoLevel).Infof("reserve devices %v for job %s until %s.", r.devices, sJob.ReferenceName,
+		r.expire.Format(time.RFC3339))
+}
+
+// reservedDevices the devices of the xpu name on the node reserved for a job other than the given one.
+// The reservations expiring before the deadline are left out, no deadline is given by a zero time
+func (c *reservationCache) reservedDevices(xpuName string, nodeName string, job api.JobID,
+	deadline time.Time) map[int]struct{} {
+	c.Lock()
+	defer c.Unlock()
+	r, ok := c.reservations[xpuName]
+	if !ok || r.job == job || (!deadline.IsZero() && !deadline.After(r.expire)) {
+		return nil
+	}
+	return r.devices[nodeName]
+}
+
+// withoutReservedDevices the unused devices of the nodes less the ones reserved for another job
+func withoutReservedDevices(xpuName string, job api.JobID,
+	unUseXPUDevicesOfNodes map[string][]*common.XPUDevice) map[string][]*common.XPUDevice {
+	result := make(map[string][]*common.XPUDevice, len(unUseXPUDevicesOfNodes))
+	for nodeName, devices := range unUseXPUDevicesOfNodes {
+		reserved := reservations.reservedDevices(xpuName, nodeName, job, time.Time{})
+		if len(reserved) == 0 {
+			result[nodeName] = devices
+			continue
+		}
+		var left []*common.XPUDevice
+		for _, device := range devices {
+			if _, ok := reserved[device.Index]; !ok {
+				left = append(left, device)
+			}
+		}
+		result[nodeName] = left
+	}
+	return result
+}
+
+// getReclaimableXPUDevices the healthy devices of the nodes which are not held whole, so they become free once
+// the vxpu containers on them end. The devices of the topology results of this session are held whole
+func (sh *ScheduleHandler) getReclaimableXPUDevices(pluginName string) map[string][]*common.XPUDevice {
+	inUseDevicesOfTopology := GetXPUDevicesFromTopologyScheduleResult(sh.Jobs, pluginName)
+	reclaimable := make(map[string][]*common.XPUDevice)
+	for _, v := range sh.Nodes {
+		xpuDevices := sh.getXPUDevicesOfNode(pluginName, v.Name)
+		sh.Lock()
+		for _, device := range xpuDevices {
+			if _, ok := inUseDevicesOfTopology[v.Name][device.Index]; ok {
+				continue
+			}
+			if device.Health && device.UsedCores < util.Base100 {
+				reclaimable[v.Name] = append(reclaimable[v.Name], device)
+			}
+		}
+		sh.Unlock()
+	}
+	return reclaimable
+}
+
+// reserveForStarvingJob reserve the devices the job would get once the vxpu containers on them end, when its
+// topology allocation failed and it is the reservation candidate of the plugin
+func (sp *SchedulerPlugin) reserveForStarvingJob(sh *ScheduleHandler, sJob *SchedulerJob) {
+	if !sp.Config.ReservationEnable || !sh.isReservationCandidate(sJob, sp.VxpuName) {
+		return
+	}
+	result, success := sp.topologyAllocate(sh.Nodes, sh.getReclaimableXPUDevices(sp.PluginName), sJob.Tasks,
+		sJob.ReqXPUInterBandwidth)
+	if !success {
+		klog.V(util.LogDebugLevel).Infof("job %s can not be placed even on reclaimable devices, no reservation.",
+			sJob.ReferenceName)
+		return
+	}
+	allocation := make([]*util.TopologyScheduleXPUs, 0, len(result))
+	for _, v := range result {
+		allocation = append(allocation, &util.TopologyScheduleXPUs{AllocateXPUs: v.DeviceIds, NodeName: v.NodeName})
+	}
+	reservations.reserve(sJob, sp.VxpuName, allocation, time.Duration(sp.Config.ReservationTimeout)*time.Second)
+}
+
+// GetPodMaxRuntime get the maximum runtime of the pod from its active deadline, which kubelet enforces,
+// 0 if it has none
+func GetPodMaxRuntime(pod *v1.Pod) time.Duration {
+	if pod == nil || pod.Spec.ActiveDeadlineSeconds == nil || *pod.Spec.ActiveDeadlineSeconds <= 0 {
+		return 0
+	}
+	return time.Duration(*pod.Spec.ActiveDeadlineSeconds) * time.Second
+}
+
+// getPodReservedDevices the devices on the node the vxpu pod may not use, the pods with an active deadline
+// backfill the devices of the reservations lasting longer than it
+func (sp *SchedulerPlugin) getPodReservedDevices(pod *v1.Pod, nodeName string) map[int]struct{} {
+	var deadline time.Time
+	if maxRuntime := GetPodMaxRuntime(pod); maxRuntime > 0 {
+		deadline = time.Now().Add(maxRuntime)
+	}
+	return reservations.reservedDevices(sp.VxpuName, nodeName, "", deadline)
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetPodMaxRuntime(t *testing.T) {
+	seconds := func(v int64) *int64 { return &v }
+	tests := []struct {
+		name string
+		pod  *v1.Pod
+		want time.Duration
+	}{
+		{name: "nil pod", pod: nil, want: 0},
+		{name: "no active deadline", pod: &v1.Pod{}, want: 0},
+		{name: "active deadline", pod: &v1.Pod{Spec: v1.PodSpec{ActiveDeadlineSeconds: seconds(300)}},
+			want: 300 * time.Second},
+		{name: "zero active deadline", pod: &v1.Pod{Spec: v1.PodSpec{ActiveDeadlineSeconds: seconds(0)}}, want: 0},
+		{name: "declared runtime not enforced", pod: &v1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{"huawei.com/xpu-max-runtime": "60"}}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := GetPodMaxRuntime(tt.pod); got != tt.want {
+				t.Errorf("GetPodMaxRuntime() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
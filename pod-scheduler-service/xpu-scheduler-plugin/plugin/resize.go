@@ -0,0 +1,327 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package plugin implements xpu scheduler plugin
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const (
+	resizeCores     = "cores"
+	resizeMemory    = "memory"
+	resizeMemoryMiB = "memoryMi"
+)
+
+// errNoXPUContainerResized the resize request names no container of the xpu of the plugin
+var errNoXPUContainerResized = errors.New("no container of the xpu resized")
+
+// ContainerResize the new vxpu limits of a container, a negative value keeps the current limit
+type ContainerResize struct {
+	// Cores the cores in percent of a card
+	Cores int
+	// Memory the memory in MiB
+	Memory int
+}
+
+// apply the limits of the container device after the resize
+func (r ContainerResize) apply(cd common.ContainerDevice) (int, uint64) {
+	cores, memory := cd.UsedCores, cd.UsedMemory
+	if r.Cores >= 0 {
+		cores = r.Cores
+	}
+	if r.Memory >= 0 {
+		memory = uint64(r.Memory)
+	}
+	return cores, memory
+}
+
+// ParseXPUResize parse the resize request like "c1:cores=50,memory=4;c2:memoryMi=2048" keyed by container name,
+// memory is given in Gi or with memoryMi in Mi as the vxpu memory resources and must be positive
+func ParseXPUResize(s string) (map[string]ContainerResize, error) {
+	request := make(map[string]ContainerResize)
+	for _, item := range strings.Split(s, util.Semicolon) {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		nameAndLimits := strings.SplitN(item, ":", util.Base2)
+		name := strings.TrimSpace(nameAndLimits[0])
+		if len(nameAndLimits) != util.Base2 || name == "" {
+			return nil, fmt.Errorf("invalid resize %s", item)
+		}
+		resize := ContainerResize{Cores: -1, Memory: -1}
+		for _, limit := range strings.Split(nameAndLimits[1], util.Comma) {
+			kv := strings.SplitN(limit, "=", util.Base2)
+			if len(kv) != util.Base2 {
+				return nil, fmt.Errorf("invalid resize %s of %s", limit, name)
+			}
+			value, err := strconv.Atoi(strings.TrimSpace(kv[1]))
+			if err != nil || value < 0 {
+				return nil, fmt.Errorf("invalid resize %s of %s", limit, name)
+			}
+			key := strings.TrimSpace(kv[0])
+			// a container is never given a 0 MiB limit, every allocation in it would fail
+			if value == 0 && (key == resizeMemory || key == resizeMemoryMiB) {
+				return nil, fmt.Errorf("invalid resize %s of %s, memory must be positive", limit, name)
+			}
+			switch key {
+			case resizeCores:
+				resize.Cores = value
+			case resizeMemory:
+				resize.Memory = value * util.Base1024
+			case resizeMemoryMiB:
+				resize.Memory = value
+			default:
+				return nil, fmt.Errorf("unknown resize %s of %s", kv[0], name)
+			}
+		}
+		request[name] = resize
+	}
+	if len(request) == 0 {
+		return nil, fmt.Errorf("empty resize %q", s)
+	}
+	return request, nil
+}
+
+// ResizeContainers handle the resize requests of the pods placed on the nodes of the session
+func (sh *ScheduleHandler) ResizeContainers(client kubernetes.Interface) {
+	names := sh.sortedPluginNames()
+	for _, node := range sh.Nodes {
+		if node == nil || node.Node == nil {
+			continue
+		}
+		for _, name := range names {
+			if handler := sh.XPUPlugins[name](); handler != nil {
+				handler.ResizePodsOnNode(node, sh, client)
+			}
+		}
+	}
+}
+
+// ResizePodsOnNode resize the containers of the pods on the node whose resize request was not handled yet.
+// A resize fitting the headroom of the same devices and the quotas of the job is accepted and charged on
+// the devices and the quotas, the device plugin then applies it to the containers. The request, its status
+// and the resized devices are patched on the pod
+func (sp *SchedulerPlugin) ResizePodsOnNode(node *api.NodeInfo, sh *ScheduleHandler, client kubernetes.Interface) {
+	tasks := make([]*api.TaskInfo, 0, len(node.Tasks))
+	for _, task := range node.Tasks {
+		tasks = append(tasks, task)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].UID < tasks[j].UID })
+	var xpuDevices map[int]*common.XPUDevice
+	for _, task := range tasks {
+		pod := task.Pod
+		if pod == nil {
+			continue
+		}
+		request, ok := pod.Annotations[util.XPUResizeAnnotation]
+		if !ok || request == pod.Annotations[util.XPUResizeHandledAnnotation] ||
+			pod.Annotations[sp.AssignedXPUsToPodAnno] == "" {
+			continue
+		}
+		sJob, ok := sh.Jobs[task.Job]
+		if !ok {
+			// the quotas of the pod are not known without its job, try again in the next session
+			klog.V(util.LogDebugLevel).Infof("job of pod %s/%s is not in the session, resize later.",
+				pod.Namespace, pod.Name)
+			continue
+		}
+		if xpuDevices == nil {
+			if xpuDevices = sp.GetXPUDevicesFromNode(node); xpuDevices == nil {
+				return
+			}
+		}
+		annotations := map[string]string{util.XPUResizeHandledAnnotation: request}
+		podDevices, err := sp.resizePod(pod, request, xpuDevices, sh, sJob)
+		if errors.Is(err, errNoXPUContainerResized) {
+			continue
+		}
+		if err != nil {
+			klog.V(util.LogWarningLevel).Infof("reject resize %q of pod %s/%s: %v", request, pod.Namespace,
+				pod.Name, err)
+			annotations[util.XPUResizeStatusAnnotation] = util.XPUResizeRejected
+			annotations[util.XPUResizeMessageAnnotation] = resizeRejectMessage(err)
+		} else {
+			klog.V(util.LogInfoLevel).Infof("accept resize %q of pod %s/%s on node %s", request, pod.Namespace,
+				pod.Name, node.Name)
+			annotations[sp.AssignedXPUsToPodAnno] = EncodePodDevices(podDevices)
+			annotations[util.XPUResizeStatusAnnotation] = util.XPUResizeAccepted
+			annotations[util.XPUResizeMessageAnnotation] = ""
+		}
+		if err := patchPodAnnotations(client, pod, annotations); err != nil {
+			klog.V(util.LogErrorLevel).Infof("patch resize of pod %s/%s failed: %v", pod.Namespace, pod.Name, err)
+			// the devices were charged with the resize, count them again for the next pod
+			xpuDevices = nil
+			continue
+		}
+		// the session charges the devices and the quotas with the resized limits from now on
+		sh.subQuotaUsage(sJob, task)
+		for k, v := range annotations {
+			pod.Annotations[k] = v
+		}
+		sh.addQuotaUsage(sJob, task)
+	}
+}
+
+// resizePod check the resize request fits the devices of the pod and charge it on them, the devices
+// of the pod with the resized limits are returned
+func (sp *SchedulerPlugin) resizePod(pod *v1.Pod, request string, xpuDevices map[int]*common.XPUDevice,
+	sh *ScheduleHandler, sJob *SchedulerJob) (PodDevices, error) {
+	resizes, err := ParseXPUResize(request)
+	if err != nil {
+		return nil, err
+	}
+	for name := range resizes {
+		if !hasContainer(pod, name) {
+			return nil, fmt.Errorf("container %s is not in the pod", name)
+		}
+	}
+	podDevices := sp.GetXPUDevicesOfPod(pod)
+	var resized []ContainerDevices
+	var containerResizes []ContainerResize
+	xpuIdx := 0
+	for i := range pod.Spec.Containers {
+		container := &pod.Spec.Containers[i]
+		if sp.getXPUReqFromContainer(container) == 0 {
+			continue
+		}
+		if xpuIdx >= len(podDevices) {
+			return nil, fmt.Errorf("devices of the pod do not match its %s containers", sp.VxpuName)
+		}
+		cds := podDevices[xpuIdx]
+		xpuIdx++
+		resize, ok := resizes[container.Name]
+		if !ok {
+			continue
+		}
+		if err := checkContainerResize(container.Name, cds, resize, xpuDevices); err != nil {
+			return nil, err
+		}
+		resized = append(resized, cds)
+		containerResizes = append(containerResizes, resize)
+	}
+	if len(resized) == 0 {
+		return nil, errNoXPUContainerResized
+	}
+	current := getPodXPUResource(podDevices)
+	growth := getResizedPodXPUResource(podDevices, resized, containerResizes)
+	growth.sub(current)
+	if err := sh.CheckQuota(sJob, growth); err != nil {
+		return nil, err
+	}
+	for i, cds := range resized {
+		for j := range cds {
+			cores, memory := containerResizes[i].apply(cds[j])
+			dev := xpuDevices[cds[j].Index]
+			dev.UsedCores += cores - cds[j].UsedCores
+			dev.UsedMemory = dev.UsedMemory - cds[j].UsedMemory + memory
+			cds[j].UsedCores = cores
+			cds[j].UsedMemory = memory
+		}
+	}
+	return podDevices, nil
+}
+
+// getResizedPodXPUResource the xpu resources of the pod once the containers are resized
+func getResizedPodXPUResource(podDevices PodDevices, resized []ContainerDevices,
+	containerResizes []ContainerResize) XPUResource {
+	resource := getPodXPUResource(podDevices)
+	for i, cds := range resized {
+		for _, cd := range cds {
+			cores, memory := containerResizes[i].apply(cd)
+			resource.sub(getPodXPUResource(PodDevices{{cd}}))
+			resource.add(getPodXPUResource(PodDevices{{{UsedCores: cores, UsedMemory: memory}}}))
+		}
+	}
+	return resource
+}
+
+// checkContainerResize check the devices of the container have the headroom for the resize. The qos class
+// of the container can not change, so cores can not be set from or to 0
+func checkContainerResize(name string, cds ContainerDevices, resize ContainerResize,
+	xpuDevices map[int]*common.XPUDevice) error {
+	for _, cd := range cds {
+		dev, ok := xpuDevices[cd.Index]
+		if !ok || dev.Id != cd.Id {
+			return fmt.Errorf("device %s of container %s is not on the node", cd.Id, name)
+		}
+		cores, memory := resize.apply(cd)
+		if cores > util.Base100 || cores%scoreSplitMinSize != 0 {
+			return fmt.Errorf("container %s invalid cores %d", name, cores)
+		}
+		if (cores == 0) != (cd.UsedCores == 0) {
+			return fmt.Errorf("container %s can not change its qos class, cores %d to %d", name, cd.UsedCores, cores)
+		}
+		if dev.UsedCores-cd.UsedCores+cores > util.Base100 {
+			return fmt.Errorf("not enough cores on device %s for container %s, request cores: %d, free cores: %d",
+				dev.Id, name, cores, util.Base100-dev.UsedCores+cd.UsedCores)
+		}
+		if cores == util.Base100 && dev.GetVidBound() > 1 {
+			return fmt.Errorf("device %s is shared, container %s can not take it exclusively", dev.Id, name)
+		}
+		used := dev.UsedMemory - cd.UsedMemory
+		if dev.UsedMemory < cd.UsedMemory {
+			used = 0
+		}
+		var free uint64
+		if capacity := dev.MemoryCapacity(); used < capacity {
+			free = capacity - used
+		}
+		if free < memory {
+			return fmt.Errorf("not enough memory on device %s for container %s, request memory: %d, "+
+				"free memory: %d", dev.Id, name, memory, free)
+		}
+	}
+	return nil
+}
+
+// resizeRejectMessage the message of a rejected resize, led by the reason type of the typed failures
+func resizeRejectMessage(err error) string {
+	var failure *NodeFailure
+	if errors.As(err, &failure) {
+		return string(failure.Type) + ": " + failure.Message
+	}
+	return err.Error()
+}
+
+func hasContainer(pod *v1.Pod, name string) bool {
+	for i := range pod.Spec.Containers {
+		if pod.Spec.Containers[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// patchPodAnnotations patch the annotations of the pod, nothing is patched without a client
+// as when the plugin runs offline
+func patchPodAnnotations(client kubernetes.Interface, pod *v1.Pod, annotations map[string]string) error {
+	if client == nil {
+		return nil
+	}
+	patch := map[string]interface{}{"metadata": map[string]interface{}{"annotations": annotations}}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	_, err = client.CoreV1().Pods(pod.Namespace).Patch(context.TODO(), pod.Name, types.StrategicMergePatchType,
+		data, metav1.PatchOptions{})
+	return err
+}
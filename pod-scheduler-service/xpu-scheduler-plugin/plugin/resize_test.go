@@ -0,0 +1,41 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package plugin
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseXPUResize(t *testing.T) {
+	tests := []struct {
+		name    string
+		request string
+		want    map[string]ContainerResize
+		wantErr bool
+	}{
+		{name: "cores and memory in Gi", request: "c1:cores=50,memory=4",
+			want: map[string]ContainerResize{"c1": {Cores: 50, Memory: 4096}}},
+		{name: "memory in Mi only", request: "c1:memoryMi=2048;c2:cores=0",
+			want: map[string]ContainerResize{"c1": {Cores: -1, Memory: 2048}, "c2": {Cores: 0, Memory: -1}}},
+		{name: "zero memory", request: "c1:memory=0", wantErr: true},
+		{name: "zero memory in Mi", request: "c1:cores=20,memoryMi=0", wantErr: true},
+		{name: "negative cores", request: "c1:cores=-10", wantErr: true},
+		{name: "unknown limit", request: "c1:gpus=1", wantErr: true},
+		{name: "no container", request: ":cores=10", wantErr: true},
+		{name: "empty", request: " ; ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseXPUResize(tt.request)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseXPUResize(%q) error %v, want error %v", tt.request, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseXPUResize(%q) = %v, want %v", tt.request, got, tt.want)
+			}
+		})
+	}
+}
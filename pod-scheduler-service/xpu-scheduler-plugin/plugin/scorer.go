@@ -0,0 +1,123 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package plugin implements xpu scheduler plugin
+package plugin
+
+import (
+	"math"
+
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const (
+	// BinpackStrategy fill the devices which are already used most
+	BinpackStrategy = "binpack"
+	// SpreadStrategy place the request on the devices which are used least
+	SpreadStrategy = "spread"
+	// LeastFragmentationStrategy keep the cores and memory left on a device balanced
+	// and avoid breaking idle devices
+	LeastFragmentationStrategy = "least-fragmentation"
+	// DefaultScoreStrategy strategy used when nothing or an unknown one is configured
+	DefaultScoreStrategy = BinpackStrategy
+)
+
+// XPUScorer score xpu devices for a container request, it drives both the device choice
+// inside a node and the node ordering
+type XPUScorer interface {
+	// Name of the score strategy
+	Name() string
+	// ScoreDevice score a device which is able to hold the request before it is charged,
+	// the higher the better, in range [0, util.XpuMultiplier]
+	ScoreDevice(dev *common.XPUDevice, req *util.ContainerResource) float64
+}
+
+var xpuScorers = map[string]XPUScorer{
+	BinpackStrategy:            binpackScorer{},
+	SpreadStrategy:             spreadScorer{},
+	LeastFragmentationStrategy: leastFragmentationScorer{},
+}
+
+// RegisterXPUScorer register a score strategy which can be selected by plugin arguments
+func RegisterXPUScorer(scorer XPUScorer) {
+	if scorer == nil {
+		return
+	}
+	xpuScorers[scorer.Name()] = scorer
+}
+
+// GetXPUScorer get the score strategy by name, fall back to the default strategy if not registered
+func GetXPUScorer(name string) XPUScorer {
+	if scorer, ok := xpuScorers[name]; ok {
+		return scorer
+	}
+	if name != "" {
+		klog.V(util.LogWarningLevel).Infof("score strategy %s is not registered, use %s", name, DefaultScoreStrategy)
+	}
+	return xpuScorers[DefaultScoreStrategy]
+}
+
+// usageAfterAllocation the weighted usage rate of cores and memory if the request is placed on the device
+func usageAfterAllocation(dev *common.XPUDevice, req *util.ContainerResource) (float64, float64) {
+	coreRate, memoryRate := 1.0, 1.0
+	if dev.Cores > 0 {
+		coreRate = math.Min(float64(dev.UsedCores+req.ReqXPUCores)/float64(dev.Cores), 1)
+	}
+	if capacity := dev.MemoryCapacity(); capacity > 0 {
+		memoryRate = math.Min(float64(dev.UsedMemory+uint64(req.ReqXPUMem))/float64(capacity), 1)
+	}
+	return coreRate, memoryRate
+}
+
+type binpackScorer struct{}
+
+func (binpackScorer) Name() string {
+	return BinpackStrategy
+}
+
+func (binpackScorer) ScoreDevice(dev *common.XPUDevice, req *util.ContainerResource) float64 {
+	coreRate, memoryRate := usageAfterAllocation(dev, req)
+	return util.XpuMultiplier * (util.CoreWeight*coreRate + util.MemoryWeight*memoryRate) /
+		(util.CoreWeight + util.MemoryWeight)
+}
+
+type spreadScorer struct{}
+
+func (spreadScorer) Name() string {
+	return SpreadStrategy
+}
+
+func (spreadScorer) ScoreDevice(dev *common.XPUDevice, req *util.ContainerResource) float64 {
+	return util.XpuMultiplier - binpackScorer{}.ScoreDevice(dev, req)
+}
+
+type leastFragmentationScorer struct{}
+
+func (leastFragmentationScorer) Name() string {
+	return LeastFragmentationStrategy
+}
+
+// ScoreDevice prefer devices on which the cores and memory left are balanced, so that they can still
+// be used by another request, and prefer devices already in use to keep idle devices whole
+func (leastFragmentationScorer) ScoreDevice(dev *common.XPUDevice, req *util.ContainerResource) float64 {
+	coreRate, memoryRate := usageAfterAllocation(dev, req)
+	balance := 1 - math.Abs(coreRate-memoryRate)
+	inUse := 0.0
+	if !dev.UsedVids.Empty() {
+		inUse = 1
+	}
+	return util.XpuMultiplier * (balance + inUse) / util.Base2
+}
+
+// typePreferenceScore favor the more preferred card types of the request, one step of preference
+// outweighs any device score so the devices of a preferred type are always chosen first
+func typePreferenceScore(dev *common.XPUDevice, req *util.ContainerResource) float64 {
+	rank := req.ReqXPUType.Rank(dev.Type)
+	if rank < 0 {
+		return 0
+	}
+	return util.XpuMultiplier * float64(len(req.ReqXPUType.Preferred)-1-rank)
+}
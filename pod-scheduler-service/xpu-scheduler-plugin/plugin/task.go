@@ -9,6 +9,8 @@ import (
 	"k8s.io/klog/v2"
 	"volcano.sh/volcano/pkg/scheduler/api"
 	"volcano.sh/volcano/pkg/scheduler/framework"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/metrics"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
 )
 
@@ -50,6 +52,12 @@ func (sh ScheduleHandler) XPUAllocateFunc(task *api.TaskInfo, ssn *framework.Ses
 	if !sJob.JobReadyTag {
 		klog.V(util.LogDebugLevel).Infof("XPUAllocateFunc %s not allow allocate npu.", task.Name)
 	}
+	// a running task given back by a discarded eviction still holds its devices
+	if !isTaskPlacedInSession(task) {
+		klog.V(util.LogDebugLevel).Infof("XPUAllocateFunc %s is not placed in this session, keep its xpu.",
+			task.Name)
+		return
+	}
 	nodeName := task.NodeName
 	node, found := ssn.Nodes[nodeName]
 	if !found {
@@ -57,17 +65,87 @@ func (sh ScheduleHandler) XPUAllocateFunc(task *api.TaskInfo, ssn *framework.Ses
 		return
 	}
 
-	sh.GetAllocatableXPUDeviceOnNodes(sJob)
-	err := sJob.handler.Allocate(sJob, task, node, sh.getXPUDevicesOfNode(nodeName))
+	handler := sJob.getHandler(task)
+	if handler == nil {
+		klog.V(util.LogErrorLevel).Infof("XPUAllocateFunc %s has no xpu plugin.", task.Name)
+		return
+	}
+	sh.GetAllocatableXPUDeviceOnNodes(handler)
+	err := handler.Allocate(sJob, task, node, sh.getXPUDevicesOfNode(handler.GetPluginName(), nodeName))
+	metrics.RecordAllocation(handler.GetPluginName(), err == nil)
 	if err != nil {
-		klog.V(util.LogErrorLevel).Infof("XPUAllocateFunc allocate failed: %s.", util.ArgumentError)
+		klog.V(util.LogErrorLevel).Infof("XPUAllocateFunc allocate failed: %s.", err)
+		return
 	}
+	xpuTask := sJob.Tasks[task.UID]
+	xpuTask.Lock()
+	xpuTask.Allocated = true
+	xpuTask.Unlock()
+	sh.addQuotaUsage(sJob, task)
+}
+
+// isTaskPlacedInSession check whether the task was just allocated or pipelined by the session, the other
+// statuses come back to AllocateFunc when an eviction is discarded
+func isTaskPlacedInSession(task *api.TaskInfo) bool {
+	return task.Status == api.Allocated || task.Status == api.Pipelined
 }
 
-// XPUDeallocateFunc Free assigned xpu, if allocate failed by volcano frame.
-func (sh *ScheduleHandler) XPUDeallocateFunc(task *api.TaskInfo) {
-	if sh == nil || task == nil {
+// evictXPUTask the task placed before this session is evicted. It keeps running on its devices until
+// it ends, so its devices, pod annotations and quota usage are left as they are.
+func (sh *ScheduleHandler) evictXPUTask(task *api.TaskInfo) {
+	klog.V(util.LogDebugLevel).Infof("XPUDeallocateFunc %s is not allocated in this session, keep its xpu "+
+		"until it ends.", task.Name)
+}
+
+// XPUDeallocateFunc Free assigned xpu, if allocate failed by volcano frame. Only the allocation of the
+// current session is rolled back, a running task evicted by preempt or reclaim keeps its devices.
+func (sh *ScheduleHandler) XPUDeallocateFunc(task *api.TaskInfo, ssn *framework.Session) {
+	if sh == nil || task == nil || ssn == nil {
 		klog.V(util.LogErrorLevel).Infof("XPUDeallocateFunc failed %s.", util.ArgumentError)
 		return
 	}
+	sJob, ok := sh.Jobs[task.Job]
+	if !ok {
+		klog.V(util.LogDebugLevel).Infof("XPUDeallocateFunc %s not req npu.", task.Name)
+		return
+	}
+	if !sh.IsTaskNeedXPUAllocated(sJob, task) {
+		klog.V(util.LogDebugLevel).Infof("XPUDeallocateFunc %s no need to clear pod annotation.", task.Name)
+		return
+	}
+	nodeName := task.NodeName
+	node, found := ssn.Nodes[nodeName]
+	if !found {
+		klog.V(util.LogWarningLevel).Infof("%s XPUDeallocateFunc %s not exist.", PluginName, nodeName)
+		return
+	}
+
+	xpuTask := sJob.Tasks[task.UID]
+	xpuTask.Lock()
+	allocated := xpuTask.Allocated
+	xpuTask.Unlock()
+	if !allocated {
+		sh.evictXPUTask(task)
+		return
+	}
+	handler := sJob.getHandler(task)
+	if handler == nil {
+		klog.V(util.LogErrorLevel).Infof("XPUDeallocateFunc %s has no xpu plugin.", task.Name)
+		return
+	}
+	sh.subQuotaUsage(sJob, task)
+	// The cached devices of the node were charged when the task was allocated,
+	// release them in place so that later tasks in this session see the free capacity.
+	sh.Lock()
+	defer sh.Unlock()
+	xpuDevices, ok := sh.XPUDevices[handler.GetPluginName()][nodeName]
+	if !ok {
+		xpuDevices = map[int]*common.XPUDevice{}
+	}
+	if err := handler.Deallocate(sJob, task, node, xpuDevices); err != nil {
+		klog.V(util.LogErrorLevel).Infof("XPUDeallocateFunc deallocate failed: %s.", err)
+	}
+	xpuTask.Lock()
+	xpuTask.Allocated = false
+	xpuTask.Unlock()
 }
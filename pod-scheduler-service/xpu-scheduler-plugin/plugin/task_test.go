@@ -0,0 +1,189 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package plugin
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/framework"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const testNamespace = "default"
+
+func testGPUPlugin() *SchedulerPlugin {
+	return &SchedulerPlugin{
+		PluginName:                 util.GPUPluginName,
+		VxpuName:                   util.VGPUName,
+		VxpuType:                   util.VGPUType,
+		VxpuCore:                   util.VGPUCore,
+		VxpuMemory:                 util.VGPUMemory,
+		VxpuMemoryMiB:              util.VGPUMemoryMiB,
+		Config:                     &CommonConfig{TestEnable: true},
+		NodeXPURegisterAnno:        util.NodeGPURegisterAnnotation,
+		AssignedXPUsToAllocateAnno: util.AssignedGPUsToAllocateAnnotations,
+		AssignedXPUsToNodeAnno:     util.AssignedGPUsToNodeAnnotations,
+		AssignedXPUsToPodAnno:      util.AssignedGPUsToPodAnnotations,
+		NodeXPUTopologyAnno:        util.NodeGPUTopologyAnnotation,
+		NodeXPUHandshakeAnno:       util.NodeGPUHandshakeAnnotation,
+		XPUNodeDeviceType:          util.NvidiaGPUDevice,
+	}
+}
+
+// testGPUNode a node registering num A100 cards of 40960MiB, named GPU-<node>-<index>
+func testGPUNode(name string, num int) *api.NodeInfo {
+	var devices strings.Builder
+	for i := 0; i < num; i++ {
+		fmt.Fprintf(&devices, "%d,GPU-%s-%d,10,40960,A100,true,0:", i, name, i)
+	}
+	return api.NewNodeInfo(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: name,
+		Annotations: map[string]string{util.NodeGPURegisterAnnotation: devices.String()}}})
+}
+
+// testGPUTask a task of job asking for num vgpus with cores and memory in GiB on each of them,
+// whole cards when both are 0
+func testGPUTask(name string, job api.JobID, num int, cores int, memory int) *api.TaskInfo {
+	limits := v1.ResourceList{v1.ResourceName(util.VGPUName): resource.MustParse(strconv.Itoa(num))}
+	if cores != 0 {
+		limits[v1.ResourceName(util.VGPUCore)] = resource.MustParse(strconv.Itoa(cores))
+	}
+	if memory != 0 {
+		limits[v1.ResourceName(util.VGPUMemory)] = resource.MustParse(strconv.Itoa(memory))
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace, UID: types.UID(name),
+			Annotations: map[string]string{}},
+		Spec: v1.PodSpec{Containers: []v1.Container{{Name: "c1",
+			Resources: v1.ResourceRequirements{Limits: limits}}}},
+	}
+	task := api.NewTaskInfo(pod)
+	task.Job = job
+	task.Status = api.Pending
+	return task
+}
+
+// testRunningOn place the task on node holding the slices of the devices, as bound by an earlier session
+func testRunningOn(task *api.TaskInfo, node *api.NodeInfo, devices ...string) {
+	task.NodeName, task.Status = node.Name, api.Running
+	task.Pod.Spec.NodeName = node.Name
+	task.Pod.Annotations[util.AssignedGPUsToNodeAnnotations] = node.Name
+	task.Pod.Annotations[util.AssignedGPUsToPodAnnotations] = strings.Join(devices, "")
+	task.Pod.Annotations[util.DeviceBindPhase] = "success"
+	_ = node.AddTask(task)
+}
+
+// testScheduleHandler a handler with the gpu plugin, holding the jobs of the tasks in a session over nodes
+func testScheduleHandler(t *testing.T, sp *SchedulerPlugin, nodes []*api.NodeInfo,
+	tasks ...*api.TaskInfo) (*ScheduleHandler, *framework.Session) {
+	ssn := &framework.Session{Jobs: map[api.JobID]*api.JobInfo{}, Nodes: map[string]*api.NodeInfo{},
+		NodeList: nodes}
+	for _, node := range nodes {
+		ssn.Nodes[node.Name] = node
+	}
+	for _, task := range tasks {
+		job, ok := ssn.Jobs[task.Job]
+		if !ok {
+			job = api.NewJobInfo(task.Job)
+			job.Name, job.Namespace, job.Queue = string(task.Job), testNamespace, "default"
+			job.PodGroup = &api.PodGroup{}
+			ssn.Jobs[task.Job] = job
+		}
+		job.Tasks[task.UID] = task
+		job.MinAvailable = int32(len(job.Tasks))
+	}
+	sh := &ScheduleHandler{
+		XPUPlugins:      map[string]XPUBuilder{util.GPUPluginName: func() XPUSchedulerPlugin { return sp }},
+		XPUDevices:      map[string]map[string]map[int]*common.XPUDevice{},
+		NamespaceQuotas: map[string]XPUResource{testNamespace: {Cores: 10000}},
+		Nodes:           nodes,
+		Mutex:           &sync.Mutex{},
+	}
+	sh.InitJobsFromSession(ssn)
+	if len(sh.Jobs) != len(ssn.Jobs) {
+		t.Fatalf("%d of %d jobs taken by the plugin", len(sh.Jobs), len(ssn.Jobs))
+	}
+	sh.InitQuotaUsage(ssn.Jobs)
+	return sh, ssn
+}
+
+func testNamespaceUsage(sh *ScheduleHandler) XPUResource {
+	if usage, ok := sh.quotaUsage.namespaces[testNamespace]; ok {
+		return *usage
+	}
+	return XPUResource{}
+}
+
+// TestXPUDeallocateFuncEvictedTask a running task evicted by preempt or reclaim keeps its devices,
+// annotations and quota usage, and gets no new devices when the eviction is discarded
+func TestXPUDeallocateFuncEvictedTask(t *testing.T) {
+	node := testGPUNode("node-1", 1)
+	victim := testGPUTask("victim", "job-1", 1, 50, 10)
+	testRunningOn(victim, node, "0,GPU-node-1-0,A100,10240,50,0:")
+	sh, ssn := testScheduleHandler(t, testGPUPlugin(), []*api.NodeInfo{node}, victim)
+	annotations := make(map[string]string, len(victim.Pod.Annotations))
+	for k, v := range victim.Pod.Annotations {
+		annotations[k] = v
+	}
+	usage := testNamespaceUsage(sh)
+	if usage.Cores != 50 {
+		t.Fatalf("namespace usage %+v, want 50 cores of the running task", usage)
+	}
+
+	victim.Status = api.Releasing
+	sh.XPUDeallocateFunc(victim, ssn)
+	victim.Status = api.Running
+	sh.XPUAllocateFunc(victim, ssn)
+
+	for k, v := range annotations {
+		if victim.Pod.Annotations[k] != v {
+			t.Errorf("annotation %s = %q, want %q", k, victim.Pod.Annotations[k], v)
+		}
+	}
+	if len(victim.Pod.Annotations) != len(annotations) {
+		t.Errorf("annotations %v, want %v", victim.Pod.Annotations, annotations)
+	}
+	if got := testNamespaceUsage(sh); got != usage {
+		t.Errorf("namespace usage %+v after eviction, want %+v", got, usage)
+	}
+}
+
+// TestXPUDeallocateFuncSessionAllocation the allocation of the current session is rolled back
+func TestXPUDeallocateFuncSessionAllocation(t *testing.T) {
+	node := testGPUNode("node-1", 1)
+	task := testGPUTask("task", "job-1", 1, 50, 10)
+	sh, ssn := testScheduleHandler(t, testGPUPlugin(), []*api.NodeInfo{node}, task)
+
+	task.NodeName, task.Status = node.Name, api.Allocated
+	sh.XPUAllocateFunc(task, ssn)
+	if task.Pod.Annotations[util.AssignedGPUsToPodAnnotations] == "" ||
+		task.Pod.Annotations[util.DeviceBindPhase] != util.DeviceBindAllocating {
+		t.Fatalf("annotations %v after allocate, want the devices allocating", task.Pod.Annotations)
+	}
+	if usage := testNamespaceUsage(sh); usage.Cores != 50 {
+		t.Fatalf("namespace usage %+v after allocate, want 50 cores", usage)
+	}
+
+	task.Status = api.Pending
+	sh.XPUDeallocateFunc(task, ssn)
+	for _, anno := range []string{util.AssignedGPUsToPodAnnotations, util.AssignedGPUsToNodeAnnotations,
+		util.DeviceBindPhase, util.BindTimeAnnotations} {
+		if v, ok := task.Pod.Annotations[anno]; ok {
+			t.Errorf("annotation %s = %q after deallocate", anno, v)
+		}
+	}
+	if usage := testNamespaceUsage(sh); usage.Cores != 0 {
+		t.Errorf("namespace usage %+v after deallocate, want none", usage)
+	}
+}
@@ -11,6 +11,8 @@ import (
 
 	"k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/allocator"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
 )
@@ -76,14 +78,33 @@ func GetXPUTopologyInterBandwidth(sJob *SchedulerJob) map[string]map[string]int
 // GetXPUTopologyIntraBandwidth get xpu topology intraBandwidth if configured
 func GetXPUTopologyIntraBandwidth(pod *v1.Pod) int {
 	for _, c := range pod.Spec.Containers {
-		resourceNum, ok := c.Resources.Limits[v1.ResourceName(util.XPUTopologyIntraBandwidthAnnotation)]
-		if ok {
-			return int(resourceNum.Value())
+		if bandwidth := getContainerIntraBandwidth(&c); bandwidth != 0 {
+			return bandwidth
 		}
 	}
 	return 0
 }
 
+func getContainerIntraBandwidth(container *v1.Container) int {
+	resourceNum, ok := container.Resources.Limits[v1.ResourceName(util.XPUTopologyIntraBandwidthAnnotation)]
+	if ok {
+		return int(resourceNum.Value())
+	}
+	return 0
+}
+
+// initNodeBandwidth give the topology allocator the bandwidth between the nodes of the session,
+// derived from the node labels and overridden by the configured matrix
+func initNodeBandwidth(nodes []*api.NodeInfo) {
+	labels := make(map[string]map[string]string, len(nodes))
+	for _, node := range nodes {
+		if node != nil && node.Node != nil {
+			labels[node.Name] = node.Node.Labels
+		}
+	}
+	allocator.SetNodeBandwidth(util.NewNodeBandwidth(labels))
+}
+
 // ScheduleXPUTopologyForTask schedule xpu topology for task
 func ScheduleXPUTopologyForTask(reqXPUNum int, reqXPUType string, intraBandwidth int, xpuTopology [][]int,
 	unUseDevices map[int]*common.XPUDevice) [][]string {
@@ -7,6 +7,7 @@ package plugin
 
 import (
 	"sync"
+	"time"
 
 	"k8s.io/apimachinery/pkg/types"
 	"volcano.sh/volcano/pkg/scheduler/api"
@@ -27,12 +28,19 @@ const (
 
 // ScheduleHandler information for the current plugin
 type ScheduleHandler struct {
-	XPUPlugins     map[string]XPUBuilder
-	XPUDevices     map[string]map[int]*common.XPUDevice
+	XPUPlugins map[string]XPUBuilder
+	// XPUDevices the devices of the nodes keyed by plugin name and node name
+	XPUDevices     map[string]map[string]map[int]*common.XPUDevice
 	Jobs           map[api.JobID]*SchedulerJob
 	DeleteJobInfos map[api.JobID]*api.JobInfo
 	SessionID      types.UID
 	Nodes          []*api.NodeInfo
+	// QueueQuotas and NamespaceQuotas xpu quotas keyed by queue and namespace name
+	QueueQuotas     map[string]XPUResource
+	NamespaceQuotas map[string]XPUResource
+	// XPUNodeSource where the device inventory of the nodes is read, XPUNodeSourceCRD or XPUNodeSourceAnnotation
+	XPUNodeSource string
+	quotaUsage    *xpuQuotaUsage
 	*sync.Mutex
 }
 
@@ -47,18 +55,25 @@ type SchedulerJob struct {
 	Id            api.JobID
 	ReferenceName string
 	NameSpace     string
+	Queue         string
 	Annotation    map[string]string
 	Selector      map[string]string
 	Label         map[string]string
+	// Priority and CreationTime order the pending topology jobs the devices are reserved for
+	Priority     int32
+	CreationTime time.Time
 	UnschedulableReason
-	handler     XPUSchedulerPlugin
+	// handlers the plugin of each xpu name requested by the tasks of the job
+	handlers    map[string]XPUSchedulerPlugin
 	JobReadyTag bool
 	*util.XPUJob
-	TopologyAllocateOnce sync.Once
+	// TopologyAllocateOnce the topology allocation runs once for the tasks of each xpu name
+	TopologyAllocateOnce map[string]*sync.Once
 }
 
 // UnschedulableReason the message of pod pending
 type UnschedulableReason struct {
-	Reason map[string]string
+	// NodeFailures the names of the nodes failing the predicate of the job, grouped by failure
+	NodeFailures map[NodeFailure]map[string]struct{}
 	*sync.Mutex
 }
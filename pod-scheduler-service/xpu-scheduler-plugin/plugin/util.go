@@ -8,92 +8,71 @@ package plugin
 import (
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
 
 	"k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
 	"volcano.sh/volcano/pkg/scheduler/api"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/devicecodec"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
 )
 
-// EncodeNodeDevices encode a node's xpus info to string
+// EncodeNodeDevices encode a node's xpus info to the versioned annotation
 func EncodeNodeDevices(xpuDevices []*common.XPUDevice) string {
-	var encodeNodeDevices strings.Builder
+	devices := make([]devicecodec.NodeDevice, 0, len(xpuDevices))
 	for _, val := range xpuDevices {
-		encodeNodeDevices.Write([]byte(strconv.Itoa(val.Index)))
-		encodeNodeDevices.Write([]byte(","))
-		encodeNodeDevices.Write([]byte(val.Id))
-		encodeNodeDevices.Write([]byte(","))
-		encodeNodeDevices.Write([]byte(strconv.Itoa(val.Count)))
-		encodeNodeDevices.Write([]byte(","))
-		encodeNodeDevices.Write([]byte(strconv.Itoa(int(val.Memory))))
-		encodeNodeDevices.Write([]byte(","))
-		encodeNodeDevices.Write([]byte(val.Type))
-		encodeNodeDevices.Write([]byte(","))
-		encodeNodeDevices.Write([]byte(strconv.FormatBool(val.Health)))
-		encodeNodeDevices.Write([]byte(":"))
+		devices = append(devices, devicecodec.NodeDevice{Index: val.Index, Id: val.Id, Count: val.Count,
+			Memory: val.Memory, Type: val.Type, Health: val.Health, Numa: val.Numa, MemoryRatio: val.MemoryRatio})
 	}
-	klog.V(util.LogDebugLevel).Infof("Encode node Devices: %s", encodeNodeDevices.String())
-	return encodeNodeDevices.String()
+	encodeNodeDevices, err := devicecodec.EncodeNodeDevices(devices)
+	if err != nil {
+		klog.V(util.LogErrorLevel).Infof("Encode node devices failed: %v", err)
+		return ""
+	}
+	klog.V(util.LogDebugLevel).Infof("Encode node Devices: %s", encodeNodeDevices)
+	return encodeNodeDevices
 }
 
-// DecodeNodeDevices decode string to node's xpus info
+// DecodeNodeDevices decode string to node's xpus info, the legacy format is accepted as well
 func DecodeNodeDevices(str string, nodeId string) map[int]*common.XPUDevice {
 	xpuDevices := make(map[int]*common.XPUDevice)
-	if !strings.Contains(str, ":") {
-		klog.V(util.LogErrorLevel).Infof("Decode node device failed, wrong annotations: %s", str)
+	devices, err := devicecodec.DecodeNodeDevices(str)
+	if err != nil {
+		klog.V(util.LogErrorLevel).Infof("Decode node device failed: %v", err)
 		return xpuDevices
 	}
-	tmp := strings.Split(str, ":")
-	for _, val := range tmp {
-		if strings.Contains(val, ",") {
-			// NodeDevice
-			// Field description: GPU Index, GPU UUID, count, memory, type, health
-			items := strings.Split(val, ",")
-			if len(items) != util.XPUDeviceLen {
-				klog.V(util.LogErrorLevel).Infof("Decode node device failed, wrong device info: %s", val)
-				return map[int]*common.XPUDevice{}
-			}
-			index, err := strconv.Atoi(items[0])
-			count, err := strconv.Atoi(items[2])
-			memory, err := strconv.Atoi(items[3])
-			health, err := strconv.ParseBool(items[5])
-			numa, err := strconv.Atoi(items[6])
-			if err != nil {
-				klog.V(util.LogErrorLevel).Infof("Decode node device failed, wrong device info: %s", val)
-				return map[int]*common.XPUDevice{}
-			}
-			i := &common.XPUDevice{
-				Index:      index,
-				Id:         items[1],
-				NodeId:     nodeId,
-				Type:       items[4],
-				Count:      count,
-				Health:     health,
-				Cores:      util.Base100,
-				Memory:     uint64(memory),
-				UsedCores:  0,
-				UsedMemory: 0,
-				UsedVids:   0,
-				InUse:      false,
-				Numa:       numa,
-			}
-			xpuDevices[index] = i
+	return newXPUDevices(devices, nodeId)
+}
+
+// newXPUDevices build the xpu devices of node from the registered devices, none of them in use
+func newXPUDevices(devices []devicecodec.NodeDevice, nodeId string) map[int]*common.XPUDevice {
+	xpuDevices := make(map[int]*common.XPUDevice, len(devices))
+	for _, val := range devices {
+		xpuDevices[val.Index] = &common.XPUDevice{
+			Index:       val.Index,
+			Id:          val.Id,
+			NodeId:      nodeId,
+			Type:        val.Type,
+			Count:       val.Count,
+			Health:      val.Health,
+			Cores:       util.Base100,
+			Memory:      val.Memory,
+			UsedCores:   0,
+			UsedMemory:  0,
+			UsedVids:    nil,
+			InUse:       false,
+			Numa:        val.Numa,
+			MemoryRatio: val.MemoryRatio,
 		}
 	}
 	return xpuDevices
 }
 
-// EncodeContainerDevices encode vxpu resource request of a container to string
-func EncodeContainerDevices(cd ContainerDevices) string {
-	var encodeContainerDevices strings.Builder
+// toCodecContainerDevices convert the vxpu slices of a container to the annotation entries
+func toCodecContainerDevices(cd ContainerDevices) []devicecodec.ContainerDevice {
+	devices := make([]devicecodec.ContainerDevice, 0, len(cd))
 	for _, val := range cd {
-		encodeContainerDevices.Write([]byte(strconv.Itoa(int(val.Index))))
-		encodeContainerDevices.Write([]byte(","))
-		encodeContainerDevices.Write([]byte(val.Id))
-		encodeContainerDevices.Write([]byte(","))
 		valType := val.Type
 		if strings.Contains(valType, util.NvidiaGPUDevice) {
 			valType = util.NvidiaGPUDevice
@@ -101,84 +80,55 @@ func EncodeContainerDevices(cd ContainerDevices) string {
 		if strings.Contains(valType, util.AscendNPUDevice) {
 			valType = util.AscendNPUDevice
 		}
-		encodeContainerDevices.Write([]byte(valType))
-		encodeContainerDevices.Write([]byte(","))
-		encodeContainerDevices.Write([]byte(strconv.Itoa(int(val.UsedMemory))))
-		encodeContainerDevices.Write([]byte(","))
-		encodeContainerDevices.Write([]byte(strconv.Itoa(int(val.UsedCores))))
-		encodeContainerDevices.Write([]byte(","))
-		encodeContainerDevices.Write([]byte(strconv.FormatUint(uint64(val.Vid), util.Base10)))
-		encodeContainerDevices.Write([]byte(":"))
+		devices = append(devices, devicecodec.ContainerDevice{Index: val.Index, UUID: val.Id, Type: valType,
+			UsedMemory: val.UsedMemory, UsedCores: val.UsedCores, Vid: int(val.Vid), Qos: val.Qos})
 	}
-	klog.V(util.LogDebugLevel).Infof("Encode container Devices: %s", encodeContainerDevices.String())
-	return encodeContainerDevices.String()
+	return devices
+}
+
+// EncodeContainerDevices encode vxpu resource request of a container to string
+func EncodeContainerDevices(cd ContainerDevices) string {
+	return EncodePodDevices(PodDevices{cd})
 }
 
-// EncodePodDevices encode vxpu resource request of a pod to string
+// EncodePodDevices encode vxpu resource request of a pod to the versioned annotation
 func EncodePodDevices(pd PodDevices) string {
-	var ss []string
+	containers := make([][]devicecodec.ContainerDevice, 0, len(pd))
 	for _, cd := range pd {
-		ss = append(ss, EncodeContainerDevices(cd))
+		containers = append(containers, toCodecContainerDevices(cd))
+	}
+	encodePodDevices, err := devicecodec.EncodePodDevices(containers)
+	if err != nil {
+		klog.V(util.LogErrorLevel).Infof("Encode pod devices failed: %v", err)
+		return ""
 	}
-	return strings.Join(ss, ";")
+	klog.V(util.LogDebugLevel).Infof("Encode pod Devices: %s", encodePodDevices)
+	return encodePodDevices
 }
 
 // DecodeContainerDevices decode vxpu resource request of a container from string
 func DecodeContainerDevices(str string) ContainerDevices {
-	if len(str) == 0 {
+	pd := DecodePodDevices(str)
+	if len(pd) == 0 {
 		return ContainerDevices{}
 	}
-	cd := strings.Split(str, ":")
-	containerDevices := ContainerDevices{}
-	for _, val := range cd {
-		if strings.Contains(val, ",") == false {
-			continue
-		}
-		fields := strings.Split(val, ",")
-		tmpdev := common.ContainerDevice{}
-		if len(fields) != reflect.TypeOf(tmpdev).NumField() {
-			klog.V(util.LogErrorLevel).Infof("DecodeContainerDevices invalid parameter: %s", str)
-			return ContainerDevices{}
-		}
-		index, err := strconv.Atoi(fields[0])
-		if err != nil {
-			klog.V(util.LogErrorLevel).Infof("DecodeContainerDevices invalid parameter: %s", str)
-			return ContainerDevices{}
-		}
-		tmpdev.Index = index
-		tmpdev.Id = fields[1]
-		tmpdev.Type = fields[2]
-		mem, err := strconv.Atoi(fields[3])
-		if err != nil {
-			klog.V(util.LogErrorLevel).Infof("DecodeContainerDevices invalid parameter: %s", str)
-			return ContainerDevices{}
-		}
-		tmpdev.UsedMemory = uint64(mem)
-		devcores, err := strconv.Atoi(fields[4])
-		if err != nil {
-			klog.V(util.LogErrorLevel).Infof("DecodeContainerDevices invalid parameter: %s", str)
-			return ContainerDevices{}
-		}
-		tmpdev.UsedCores = devcores
-		vid, err := strconv.ParseUint(fields[5], util.Base10, 0)
-		if err != nil {
-			klog.V(util.LogErrorLevel).Infof("DecodeContainerDevices invalid parameter: %s", str)
-			return ContainerDevices{}
-		}
-		tmpdev.Vid = uint(vid)
-		containerDevices = append(containerDevices, tmpdev)
-	}
-	return containerDevices
+	return pd[0]
 }
 
-// DecodePodDevices decode vxpu resource request of a pod from string
+// DecodePodDevices decode vxpu resource request of a pod from string, the legacy format is accepted as well
 func DecodePodDevices(str string) PodDevices {
-	if len(str) == 0 {
+	containers, err := devicecodec.DecodePodDevices(str)
+	if err != nil {
+		klog.V(util.LogErrorLevel).Infof("DecodePodDevices invalid parameter: %v", err)
 		return PodDevices{}
 	}
-	var pd PodDevices
-	for _, s := range strings.Split(str, ";") {
-		cd := DecodeContainerDevices(s)
+	pd := make(PodDevices, 0, len(containers))
+	for _, devices := range containers {
+		cd := ContainerDevices{}
+		for _, val := range devices {
+			cd = append(cd, common.ContainerDevice{Index: val.Index, Id: val.UUID, Type: val.Type,
+				UsedMemory: val.UsedMemory, UsedCores: val.UsedCores, Vid: uint(val.Vid), Qos: val.Qos})
+		}
 		pd = append(pd, cd)
 	}
 	return pd
@@ -215,7 +165,7 @@ func UpdateXPUDevicesFromTopologyResults(xpuDevices map[int]*common.XPUDevice, i
 		}
 		xpuDevices[index].InUse = true
 		xpuDevices[index].UsedCores = util.Base100
-		xpuDevices[index].UsedMemory = xpuDevices[index].Memory
+		xpuDevices[index].UsedMemory = xpuDevices[index].MemoryCapacity()
 	}
 }
 
@@ -230,11 +180,16 @@ func initScoreMap(nodes []*api.NodeInfo) map[string]float64 {
 	return scoreMap
 }
 
-// GetXPUDevicesFromTopologyScheduleResult get xpu devices from topology schedule result
-func GetXPUDevicesFromTopologyScheduleResult(sJobs map[api.JobID]*SchedulerJob) map[string]map[int]struct{} {
+// GetXPUDevicesFromTopologyScheduleResult get xpu devices of the plugin from topology schedule result
+func GetXPUDevicesFromTopologyScheduleResult(sJobs map[api.JobID]*SchedulerJob,
+	pluginName string) map[string]map[int]struct{} {
 	inUseDevicesOfTopology := make(map[string]map[int]struct{})
 	for _, v := range sJobs {
-		for _, x := range v.TopologyScheduleResult {
+		for taskID, x := range v.TopologyScheduleResult {
+			// the devices of another plugin have their own indexes
+			if xpuTask, ok := v.Tasks[taskID]; ok && getPluginNameByXPUName(xpuTask.ReqXPUName) != pluginName {
+				continue
+			}
 			if _, ok := inUseDevicesOfTopology[x.NodeName]; !ok {
 				inUseDevicesOfTopology[x.NodeName] = make(map[int]struct{})
 			}
@@ -260,6 +215,7 @@ func getContainerDevices(allocateXPUs []int, xpuDevices map[int]*common.XPUDevic
 			Type:       xpuDevice.Type,
 			UsedMemory: xpuDevice.Memory,
 			UsedCores:  util.Base100,
+			Qos:        QosGuaranteed,
 		}
 		cds = append(cds, cd)
 	}
@@ -271,19 +227,14 @@ func GetXPUResourceFromTaskInfo(task *api.TaskInfo, xpuName string) *util.TaskRe
 	taskResource := &util.TaskResource{
 		ReqXPUName:          "",
 		ReqXPUNum:           0,
-		ReqXPUType:          "",
+		ReqXPUType:          util.XPUTypeRequest{},
 		ReqXPUCores:         0,
 		ReqXPUMem:           0,
 		ReqXPUMemPercentage: 0,
-	}
-	xpuCoreName, xpuMemName, xpuTypeName := "", "", ""
-	if xpuName == util.VGPUName {
-		xpuCoreName, xpuMemName, xpuTypeName = util.VGPUCore, util.VGPUMemory, util.VGPUType
-	} else {
-		xpuCoreName, xpuMemName, xpuTypeName = util.VNPUCore, util.VNPUMemory, util.VNPUType
+		ReqXPUUUID:          util.GetXPUUUIDRequest(task.Pod.Annotations),
 	}
 	for _, container := range task.Pod.Spec.Containers {
-		containerResource := GetXPUResourceFromContainer(&container, xpuName, xpuCoreName, xpuMemName, xpuTypeName)
+		containerResource := GetXPUResourceFromContainer(&container, xpuName)
 		if containerResource.ReqXPUNum == 0 {
 			continue
 		}
@@ -292,27 +243,101 @@ func GetXPUResourceFromTaskInfo(task *api.TaskInfo, xpuName string) *util.TaskRe
 		taskResource.ReqXPUMem += containerResource.ReqXPUMem * containerResource.ReqXPUNum
 		taskResource.ReqXPUMemPercentage += containerResource.ReqXPUMemPercentage * containerResource.ReqXPUNum
 		// all the containers' xpu device type of the pod must be the same
-		if taskResource.ReqXPUType == "" {
+		if taskResource.ReqXPUType.IsEmpty() {
 			taskResource.ReqXPUType = containerResource.ReqXPUType
 		}
 	}
 	return taskResource
 }
 
-// GetXPUResourceFromContainer for get xpu resource info from container
-func GetXPUResourceFromContainer(container *v1.Container, xpuName string, xpuCoreName string,
-	xpuMemName string, xpuTypeName string) util.ContainerResource {
+// GetXPUContainerRequests for get the topology request of each container applying xpu devices
+func GetXPUContainerRequests(task *api.TaskInfo, xpuName string) []util.ContainerRequest {
+	if task == nil || task.Pod == nil || xpuName == "" {
+		return nil
+	}
+	var requests []util.ContainerRequest
+	for _, container := range task.Pod.Spec.Containers {
+		containerResource := GetXPUResourceFromContainer(&container, xpuName)
+		if containerResource.ReqXPUNum == 0 {
+			continue
+		}
+		requests = append(requests, util.ContainerRequest{
+			ReqXPUNum:            containerResource.ReqXPUNum,
+			ReqXPUType:           containerResource.ReqXPUType,
+			ReqXPUCores:          containerResource.ReqXPUCores,
+			ReqXPUIntraBandwidth: getContainerIntraBandwidth(&container),
+		})
+	}
+	// the bandwidth of a single xpu container may be set on any container of the pod
+	if len(requests) == 1 {
+		requests[0].ReqXPUIntraBandwidth = GetXPUTopologyIntraBandwidth(task.Pod)
+	}
+	return requests
+}
+
+// xpuResourceNames the resource names a container applies xpu devices with
+type xpuResourceNames struct {
+	core        string
+	memory      string
+	memoryMiB   string
+	cardType    string
+	excludeType string
+	qos         string
+}
+
+func getXPUResourceNames(xpuName string) xpuResourceNames {
+	if xpuName == util.VGPUName {
+		return xpuResourceNames{core: util.VGPUCore, memory: util.VGPUMemory, memoryMiB: util.VGPUMemoryMiB,
+			cardType: util.VGPUType, excludeType: util.VGPUExcludeType, qos: util.VGPUQos}
+	}
+	return xpuResourceNames{core: util.VNPUCore, memory: util.VNPUMemory, memoryMiB: util.VNPUMemoryMiB,
+		cardType: util.VNPUType, excludeType: util.VNPUExcludeType, qos: util.VNPUQos}
+}
+
+// CheckXPUMemoryUnits check the xpu containers of the pod ask for xpu memory in one unit, all of them
+// in GiB or all of them in MiB
+func CheckXPUMemoryUnits(pod *v1.Pod) error {
+	if pod == nil {
+		return nil
+	}
+	for _, xpuName := range []string{util.VGPUName, util.VNPUName} {
+		names := getXPUResourceNames(xpuName)
+		var gibContainer, mibContainer string
+		for i := range pod.Spec.Containers {
+			container := &pod.Spec.Containers[i]
+			if _, ok := container.Resources.Limits[v1.ResourceName(names.memory)]; ok && gibContainer == "" {
+				gibContainer = container.Name
+			}
+			if _, ok := container.Resources.Limits[v1.ResourceName(names.memoryMiB)]; ok && mibContainer == "" {
+				mibContainer = container.Name
+			}
+		}
+		if gibContainer != "" && mibContainer != "" {
+			return fmt.Errorf("container %s sets %s and container %s sets %s, only one memory unit is allowed "+
+				"in a pod", gibContainer, names.memory, mibContainer, names.memoryMiB)
+		}
+	}
+	return nil
+}
+
+// GetXPUResourceFromContainer for get xpu resource info from container, the memory is requested either
+// in GiB or in MiB and is returned in MiB
+func GetXPUResourceFromContainer(container *v1.Container, xpuName string) util.ContainerResource {
+	names := getXPUResourceNames(xpuName)
 	containerResource := util.ContainerResource{
 		ReqXPUName:          xpuName,
 		ReqXPUNum:           0,
-		ReqXPUType:          "",
+		ReqXPUType:          util.XPUTypeRequest{},
 		ReqXPUCores:         0,
 		ReqXPUMem:           0,
 		ReqXPUMemPercentage: 0,
 	}
 	vxpuNum := util.GetVXPUResource(container, xpuName)
-	vxpuCore := util.GetVXPUResource(container, xpuCoreName)
-	vxpuMem := util.GetVXPUResource(container, xpuMemName) * util.Base1024
+	vxpuCore := util.GetVXPUResource(container, names.core)
+	vxpuMem := util.GetVXPUResource(container, names.memory) * util.Base1024
+	if vxpuMem == 0 {
+		vxpuMem = util.GetVXPUResource(container, names.memoryMiB)
+	}
 	if vxpuNum == 0 {
 		klog.V(util.LogDebugLevel).Infof("Container %s do not apply xpu device, resources limit: %v",
 			container.Name, container.Resources.Limits)
@@ -332,6 +357,12 @@ func GetXPUResourceFromContainer(container *v1.Container, xpuName string, xpuCor
 		containerResource.ReqXPUCores = vxpuCore
 		containerResource.ReqXPUMem = vxpuMem
 	}
-	containerResource.ReqXPUType = util.GetXPUType(container, xpuTypeName)
+	containerResource.ReqXPUType = util.GetXPUTypeRequest(container, names.cardType, names.excludeType)
+	qos, err := getContainerQos(container, names.qos, containerResource.ReqXPUCores)
+	if err != nil {
+		klog.V(util.LogWarningLevel).Infof("%v, use qos class %s", err, defaultQosClass(containerResource.ReqXPUCores))
+		qos = defaultQosClass(containerResource.ReqXPUCores)
+	}
+	containerResource.ReqXPUQos = qos
 	return containerResource
 }
@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package plugin
+
+import (
+	"testing"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+func testContainer(name string, limits ...string) v1.Container {
+	list := v1.ResourceList{}
+	for _, limit := range limits {
+		list[v1.ResourceName(limit)] = resource.MustParse("1")
+	}
+	return v1.Container{Name: name, Resources: v1.ResourceRequirements{Limits: list}}
+}
+
+func TestCheckXPUMemoryUnits(t *testing.T) {
+	tests := []struct {
+		name       string
+		containers []v1.Container
+		wantErr    bool
+	}{
+		{name: "GiB only", containers: []v1.Container{testContainer("c1", util.VGPUMemory),
+			testContainer("c2", util.VGPUMemory)}},
+		{name: "MiB only", containers: []v1.Container{testContainer("c1", util.VGPUMemoryMiB),
+			testContainer("c2", util.VGPUMemoryMiB)}},
+		{name: "both units in a container", containers: []v1.Container{
+			testContainer("c1", util.VGPUMemory, util.VGPUMemoryMiB)}, wantErr: true},
+		{name: "units mixed across containers", containers: []v1.Container{testContainer("c1", util.VGPUMemory),
+			testContainer("c2", util.VGPUMemoryMiB)}, wantErr: true},
+		{name: "units of different xpus", containers: []v1.Container{testContainer("c1", util.VGPUMemory),
+			testContainer("c2", util.VNPUMemoryMiB)}},
+		{name: "no xpu memory", containers: []v1.Container{testContainer("c1")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &v1.Pod{Spec: v1.PodSpec{Containers: tt.containers}}
+			if err := CheckXPUMemoryUnits(pod); (err != nil) != tt.wantErr {
+				t.Errorf("CheckXPUMemoryUnits() error %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -8,99 +8,271 @@ package plugin
 import (
 	"errors"
 	"fmt"
+	"sort"
 
 	"k8s.io/api/core/v1"
 	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/api"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
 	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
 )
 
-func unqualifiedCheck(xpuDevices []*common.XPUDevice, val *util.ContainerResource, i int) bool {
+// unqualifiedCheck check the device i meets the container request, the reason is returned when it does not
+func unqualifiedCheck(xpuDevices []*common.XPUDevice, val *util.ContainerResource, i int) (bool, ReasonType) {
 	if i >= len(xpuDevices) {
-		return false
+		return false, ReasonOther
 	}
-	if xpuDevices[i].Count <= int(xpuDevices[i].GetVidBound()) {
+	// device must be pinned by the pod if any is, and not excluded
+	if !val.ReqXPUUUID.Accept(xpuDevices[i].Id) {
+		klog.V(util.LogDebugLevel).Infof("Calculate device for container request %v, device %s filtered by uuid",
+			val, xpuDevices[i].Id)
+		return false, ReasonDeviceUUIDFiltered
+	}
+	// device type must be one of the request xpu types and not excluded
+	if !val.ReqXPUType.Accept(xpuDevices[i].Type) {
+		klog.V(util.LogDebugLevel).Infof("Calculate device for container request %v, xpu type not the same, "+
+			"deviceId: %s, request xpu: %s, device xpu: %s",
+			val, xpuDevices[i].Id, val.ReqXPUType, xpuDevices[i].Type)
+		return false, ReasonCardTypeMismatch
+	}
+	// device reserved for a starving topology job the container can not backfill
+	if _, ok := val.ReservedXPUs[xpuDevices[i].Index]; ok {
+		klog.V(util.LogDebugLevel).Infof("Calculate device for container request %v, device %s is reserved",
+			val, xpuDevices[i].Id)
+		return false, ReasonDeviceReserved
+	}
+	if !xpuDevices[i].HasFreeVid() {
 		klog.V(util.LogDebugLevel).Infof("Calculate device for container request %v, count is not enough, "+
-			"deviceId: %s, max count: %d, used vids: %x",
+			"deviceId: %s, max count: %d, used vids: %s",
 			val, xpuDevices[i].Id, xpuDevices[i].Count, xpuDevices[i].UsedVids)
-		return false
+		return false, ReasonVidExhausted
 	}
 	// If ReqXPUMemPercentage is set and ReqXPUMem is not set, calculate memory with percentage
 	if val.ReqXPUMemPercentage != 0 && val.ReqXPUMem == 0 {
 		val.ReqXPUMem = int(xpuDevices[i].Memory) * val.ReqXPUMemPercentage / util.Base100
 	}
-	if xpuDevices[i].Memory-xpuDevices[i].UsedMemory < uint64(val.ReqXPUMem) {
+	// the free memory is counted against the oversubscribed capacity, the percentage above stays physical
+	capacity := xpuDevices[i].MemoryCapacity()
+	if xpuDevices[i].UsedMemory > capacity || capacity-xpuDevices[i].UsedMemory < uint64(val.ReqXPUMem) {
 		klog.V(util.LogDebugLevel).Infof("Calculate device for container request %v, memory is not enough, "+
-			"deviceId: %s, request memory: %d, exist memory: %d",
-			val, xpuDevices[i].Id, val.ReqXPUMem, xpuDevices[i].Memory-xpuDevices[i].UsedMemory)
-		return false
+			"deviceId: %s, request memory: %d, used memory: %d, memory capacity: %d",
+			val, xpuDevices[i].Id, val.ReqXPUMem, xpuDevices[i].UsedMemory, capacity)
+		return false, ReasonInsufficientMemory
 	}
 	if util.Base100-xpuDevices[i].UsedCores < val.ReqXPUCores {
 		klog.V(util.LogDebugLevel).Infof("Calculate device for container request %v, cores is not enough, "+
 			"deviceId: %s, request cores: %d, exist cores: %d",
 			val, xpuDevices[i].Id, val.ReqXPUCores, util.Base100-xpuDevices[i].UsedCores)
-		return false
+		return false, ReasonInsufficientCores
 	}
 	// ReqXPUCores=100 indicates it want this card exclusively
-	if val.ReqXPUCores == util.Base100 && xpuDevices[i].UsedVids > 0 {
+	if val.ReqXPUCores == util.Base100 && !xpuDevices[i].UsedVids.Empty() {
 		klog.V(util.LogDebugLevel).Infof("Calculate device for container request %v, skip exclusive card request, "+
-			"deviceId: %s, request cores: %d, used vids: %x",
+			"deviceId: %s, request cores: %d, used vids: %s",
 			val, xpuDevices[i].Id, val.ReqXPUCores, xpuDevices[i].UsedVids)
-		return false
+		return false, ReasonInsufficientCores
 	}
-	// You can't allocate core=0 job to an already full xpu device
+	// You can't allocate a best-effort core=0 job to an already full xpu device
 	if xpuDevices[i].UsedCores == util.Base100 && val.ReqXPUCores == 0 {
 		klog.V(util.LogDebugLevel).Infof("Calculate device for container request %v, skip already full card, "+
 			"deviceId: %s, request cores: %d, used cores: %d",
 			val, xpuDevices[i].Id, val.ReqXPUCores, xpuDevices[i].UsedCores)
-		return false
+		return false, ReasonInsufficientCores
 	}
-	// device type must be the same as request xpu type
-	if len(val.ReqXPUType) != 0 && xpuDevices[i].Type != val.ReqXPUType {
-		klog.V(util.LogDebugLevel).Infof("Calculate device for container request %v, xpu type not the same, "+
-			"deviceId: %s, request xpu: %s, device xpu: %s",
-			val, xpuDevices[i].Id, val.ReqXPUType, xpuDevices[i].Type)
-		return false
+	for _, class := range val.ReqXPUQosConflicts {
+		if xpuDevices[i].HasQos(class) {
+			klog.V(util.LogDebugLevel).Infof("Calculate device for container request %v, qos class %s can not "+
+				"share device %s with qos class %s", val, val.ReqXPUQos, xpuDevices[i].Id, class)
+			return false, ReasonQosConflict
+		}
+	}
+	return true, ""
+}
+
+// deviceScore a qualified device with its score for a container request
+type deviceScore struct {
+	device *common.XPUDevice
+	score  float64
+}
+
+// topologyConstraint bandwidth and NUMA requirement on the devices chosen for one container
+type topologyConstraint struct {
+	topology       [][]int
+	intraBandwidth int
+	numaPolicy     string
+	// numaNodes the NUMA nodes the CPUs of the pod are bound to, the devices are aligned to them if set
+	numaNodes []int
+}
+
+// getTopologyConstraint get the topology requirement of a vxpu pod on node, nil if there is none
+func (sp *SchedulerPlugin) getTopologyConstraint(pod *v1.Pod, node *api.NodeInfo) *topologyConstraint {
+	if node == nil || node.Node == nil {
+		return nil
+	}
+	constraint := &topologyConstraint{numaPolicy: sp.Config.VxpuNumaPolicy}
+	if constraint.numaPolicy == "" {
+		constraint.numaPolicy = NumaPolicyNone
+	}
+	if sp.Config.TopologyEnable {
+		constraint.intraBandwidth = GetXPUTopologyIntraBandwidth(pod)
+	}
+	if constraint.numaPolicy != NumaPolicyNone {
+		constraint.numaNodes = GetPodNumaNodes(pod)
+	}
+	if constraint.intraBandwidth == 0 && constraint.numaPolicy == NumaPolicyNone {
+		return nil
+	}
+	// without topology a bandwidth requirement can not be met by several devices
+	if constraint.intraBandwidth != 0 {
+		constraint.topology, _ = sp.getNodeXPUTopology(node)
+	}
+	return constraint
+}
+
+// appliesTo check whether the constraint restricts the devices of a request for num devices,
+// a single device only has to be aligned with the NUMA nodes of the pod
+func (tc *topologyConstraint) appliesTo(num int) bool {
+	return num > 1 || (num == 1 && len(tc.numaNodes) != 0)
+}
+
+// satisfied check the bandwidth between the device and every device of the group
+func (tc *topologyConstraint) satisfied(group []deviceScore, dev *common.XPUDevice) bool {
+	if tc.intraBandwidth == 0 {
+		return true
+	}
+	for _, member := range group {
+		row, col := member.device.Index, dev.Index
+		if row >= len(tc.topology) || col >= len(tc.topology[row]) ||
+			tc.topology[row][col] < tc.intraBandwidth {
+			return false
+		}
 	}
 	return true
 }
 
-// calculate for container xpu device request
-func calculate(xpuDevices []*common.XPUDevice, val *util.ContainerResource,
-	score *float64) []common.ContainerDevice {
-	var cdevs []common.ContainerDevice
+// selectGroup select num candidates meeting the constraint. Each candidate in score order seeds a group
+// filled with the best scored candidates linked to all the members. Unless the NUMA policy is none the
+// groups aligned in one NUMA node are tried first, and the only ones tried when it is strict.
+// Whether the group is NUMA aligned is returned with it
+func (tc *topologyConstraint) selectGroup(candidates []deviceScore, num int) ([]deviceScore, bool) {
+	grow := func(seed int, sameNuma bool) []deviceScore {
+		group := []deviceScore{candidates[seed]}
+		for i, candidate := range candidates {
+			if len(group) == num {
+				break
+			}
+			if i == seed || (sameNuma && candidate.device.Numa != candidates[seed].device.Numa) {
+				continue
+			}
+			if tc.satisfied(group, candidate.device) {
+				group = append(group, candidate)
+			}
+		}
+		return group
+	}
+	if tc.numaPolicy != NumaPolicyNone {
+		for seed := range candidates {
+			if !tc.inPodNuma(candidates[seed].device.Numa) {
+				continue
+			}
+			if group := grow(seed, true); len(group) == num {
+				return group, true
+			}
+		}
+	}
+	if tc.numaPolicy == NumaPolicyStrict {
+		return nil, false
+	}
+	for seed := range candidates {
+		if group := grow(seed, false); len(group) == num {
+			return group, false
+		}
+	}
+	return nil, false
+}
+
+// groupFailure the failure when no group of the candidates meets the constraint
+func (tc *topologyConstraint) groupFailure() *NodeFailure {
+	if tc.numaPolicy == NumaPolicyStrict {
+		if len(tc.numaNodes) != 0 {
+			return newNodeFailure(ReasonNumaUnaligned, "no cards in the NUMA nodes of the pod fit")
+		}
+		return newNodeFailure(ReasonNumaUnaligned, "no cards in one NUMA node fit")
+	}
+	return newNodeFailure(ReasonTopologyBandwidth, "topology bandwidth unmet")
+}
+
+// calculate for container xpu device request, the qualified devices are chosen in order of the scorer,
+// several devices for one container also meet the topology constraint if there is one. The failure
+// is returned when the request can not be fulfilled
+func calculate(xpuDevices []*common.XPUDevice, val *util.ContainerResource, scorer XPUScorer,
+	constraint *topologyConstraint, score *float64) ([]common.ContainerDevice, error) {
+	var candidates []deviceScore
+	reasons := map[ReasonType]int{}
 	for i := len(xpuDevices) - 1; i >= 0; i-- {
-		quelified := unqualifiedCheck(xpuDevices, val, i)
+		quelified, reason := unqualifiedCheck(xpuDevices, val, i)
 		if !quelified {
+			reasons[reason]++
 			continue
 		}
-		if val.ReqXPUNum > 0 {
-			klog.V(util.LogDebugLevel).Infof("xpu device %s fitted", xpuDevices[i].Id)
-			val.ReqXPUNum--
-			vid := xpuDevices[i].AllocVid()
-			xpuDevices[i].UsedMemory += uint64(val.ReqXPUMem)
-			xpuDevices[i].UsedCores += val.ReqXPUCores
-			cdevs = append(cdevs, common.ContainerDevice{
-				Index:      xpuDevices[i].Index,
-				Id:         xpuDevices[i].Id,
-				Type:       xpuDevices[i].Type,
-				UsedMemory: uint64(val.ReqXPUMem),
-				UsedCores:  val.ReqXPUCores,
-				Vid:        vid,
-			})
-			if score != nil {
-				*score += util.XpuMultiplier * (float64(xpuDevices[i].UsedMemory) / float64(xpuDevices[i].Memory))
-			}
+		candidates = append(candidates, deviceScore{device: xpuDevices[i],
+			score: scorer.ScoreDevice(xpuDevices[i], val) + typePreferenceScore(xpuDevices[i], val)})
+	}
+	// stable sort keeps walking devices from the highest index down when the scores are equal
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+	if len(candidates) < val.ReqXPUNum {
+		return nil, uuidCardsFailure(reasons, len(xpuDevices), val.ReqXPUUUID)
+	}
+	numaAligned := false
+	if constraint != nil && constraint.appliesTo(val.ReqXPUNum) {
+		candidates, numaAligned = constraint.selectGroup(candidates, val.ReqXPUNum)
+		if candidates == nil {
+			return nil, constraint.groupFailure()
 		}
+	}
+
+	var cdevs []common.ContainerDevice
+	for _, candidate := range candidates {
 		if val.ReqXPUNum == 0 {
 			break
 		}
+		dev := candidate.device
+		vid, ok := dev.AllocVid()
+		if !ok {
+			continue
+		}
+		klog.V(util.LogDebugLevel).Infof("xpu device %s fitted, %s score: %v", dev.Id, scorer.Name(), candidate.score)
+		val.ReqXPUNum--
+		dev.UsedMemory += uint64(val.ReqXPUMem)
+		dev.UsedCores += val.ReqXPUCores
+		dev.AddQos(val.ReqXPUQos)
+		cdevs = append(cdevs, common.ContainerDevice{
+			Index:      dev.Index,
+			Id:         dev.Id,
+			Type:       dev.Type,
+			UsedMemory: uint64(val.ReqXPUMem),
+			UsedCores:  val.ReqXPUCores,
+			Vid:        vid,
+			Qos:        val.ReqXPUQos,
+		})
+		if score != nil {
+			*score += candidate.score
+		}
+	}
+	if val.ReqXPUNum > 0 {
+		return nil, newNodeFailure(ReasonVidExhausted, "vid slots exhausted on some cards")
 	}
-	return cdevs
+	// the nodes able to align the devices in one NUMA node score higher
+	if numaAligned && score != nil {
+		*score += util.XpuMultiplier
+	}
+	return cdevs, nil
 }
 
 // calculateDecision for calculate pod device decision
-func (sp *SchedulerPlugin) calculateDecision(pod *v1.Pod,
+func (sp *SchedulerPlugin) calculateDecision(pod *v1.Pod, node *api.NodeInfo,
 	devs map[int]*common.XPUDevice, score *float64) (bool, PodDevices, error) {
 	xpuDevices := make([]*common.XPUDevice, len(devs))
 	for index, dev := range devs {
@@ -112,9 +284,14 @@ func (sp *SchedulerPlugin) calculateDecision(pod *v1.Pod,
 	klog.V(util.LogDebugLevel).Infof("Calculate decision for pod %s/%s", pod.Namespace, pod.Name)
 
 	var resourceRequests []*util.ContainerResource
+	uuids := util.GetXPUUUIDRequest(pod.Annotations)
+	reserved := sp.getPodReservedDevices(pod, node.Name)
+	if err := CheckXPUMemoryUnits(pod); err != nil {
+		klog.V(util.LogErrorLevel).Infof(err.Error())
+		return false, PodDevices{}, err
+	}
 	for _, c := range pod.Spec.Containers {
-		containerResource := GetXPUResourceFromContainer(
-			&c, sp.VxpuName, sp.VxpuCore, sp.VxpuMemory, sp.VxpuType)
+		containerResource := GetXPUResourceFromContainer(&c, sp.VxpuName)
 		if containerResource.ReqXPUNum == 0 {
 			continue
 		}
@@ -124,19 +301,28 @@ func (sp *SchedulerPlugin) calculateDecision(pod *v1.Pod,
 			klog.V(util.LogErrorLevel).Infof(errMsg)
 			return false, PodDevices{}, fmt.Errorf(errMsg)
 		}
+		if _, err := getContainerQos(&c, getXPUResourceNames(sp.VxpuName).qos, containerResource.ReqXPUCores); err != nil {
+			klog.V(util.LogErrorLevel).Infof(err.Error())
+			return false, PodDevices{}, err
+		}
+		containerResource.ReqXPUUUID = uuids
+		containerResource.ReservedXPUs = reserved
+		containerResource.ReqXPUQosConflicts = sp.Config.VxpuQosIsolation.Conflicts(containerResource.ReqXPUQos)
 		resourceRequests = append(resourceRequests, &containerResource)
 	}
 
+	constraint := sp.getTopologyConstraint(pod, node)
 	podDevices := PodDevices{}
 	for _, val := range resourceRequests {
 		if val.ReqXPUNum > len(xpuDevices) {
-			return false, PodDevices{}, fmt.Errorf("no enough gpu cards on node, request: %d, have: %d",
+			klog.V(util.LogDebugLevel).Infof("no enough xpu cards on node, request: %d, have: %d",
 				val.ReqXPUNum, len(xpuDevices))
+			return false, PodDevices{}, newNodeFailure(ReasonNotEnoughCards, "not enough cards")
 		}
 		klog.V(util.LogDebugLevel).Infof("Allocating deivce for container request %v", val)
-		cdevs := calculate(xpuDevices, val, score)
-		if val.ReqXPUNum > 0 {
-			return false, PodDevices{}, fmt.Errorf("no enough gpu fitted on this node")
+		cdevs, err := calculate(xpuDevices, val, GetXPUScorer(sp.Config.ScoreStrategy), constraint, score)
+		if err != nil {
+			return false, PodDevices{}, err
 		}
 		podDevices = append(podDevices, cdevs)
 	}
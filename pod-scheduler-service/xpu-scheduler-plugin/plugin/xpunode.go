@@ -0,0 +1,145 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package plugin implements xpu scheduler plugin
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/common"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/devicecodec"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const (
+	// XPUNodeSourceCRD read the device inventory from the XPUNode objects, the nodes without
+	// XPUNode object are read from the node annotations
+	XPUNodeSourceCRD = "crd"
+	// XPUNodeSourceAnnotation read the device inventory from the node annotations only
+	XPUNodeSourceAnnotation = "annotation"
+	// xpuNodeResyncInterval the XPUNode objects are listed at most once in this interval
+	xpuNodeResyncInterval = 10 * time.Second
+)
+
+// xpuNodeCache XPUNode status keyed by node name and device type, shared by the sessions
+type xpuNodeCache struct {
+	nodes    map[string]*devicecodec.XPUNodeStatus
+	lastSync time.Time
+	sync.RWMutex
+}
+
+var xpuNodes = &xpuNodeCache{nodes: map[string]*devicecodec.XPUNodeStatus{}}
+
+func xpuNodeKey(nodeName string, deviceType string) string {
+	return devicecodec.XPUNodeName(nodeName, deviceType)
+}
+
+// RefreshXPUNodes list the XPUNode objects when the cache is older than the resync interval,
+// the cache is emptied when the annotations are the source or the objects can not be listed
+func (sh *ScheduleHandler) RefreshXPUNodes(client kubernetes.Interface) {
+	if sh.XPUNodeSource == XPUNodeSourceAnnotation || client == nil {
+		xpuNodes.set(nil)
+		return
+	}
+	xpuNodes.RLock()
+	fresh := time.Since(xpuNodes.lastSync) < xpuNodeResyncInterval
+	xpuNodes.RUnlock()
+	if fresh {
+		return
+	}
+	restClient := client.Discovery().RESTClient()
+	if restClient == nil {
+		xpuNodes.set(nil)
+		return
+	}
+	data, err := restClient.Get().AbsPath(devicecodec.XPUNodesPath).DoRaw(context.TODO())
+	if err != nil {
+		klog.V(util.LogDebugLevel).Infof("list XPUNode failed, use node annotations: %v", err)
+		xpuNodes.set(nil)
+		return
+	}
+	var list devicecodec.XPUNodeList
+	if err := json.Unmarshal(data, &list); err != nil {
+		klog.V(util.LogErrorLevel).Infof("decode XPUNode list failed, use node annotations: %v", err)
+		xpuNodes.set(nil)
+		return
+	}
+	klog.V(util.LogDebugLevel).Infof("list %d XPUNode objects.", len(list.Items))
+	xpuNodes.set(list.Items)
+}
+
+// LoadXPUNodes fill the cache with the given XPUNode objects instead of listing them, for running
+// the plugin without a cluster
+func LoadXPUNodes(items []devicecodec.XPUNode) {
+	xpuNodes.set(items)
+}
+
+func (c *xpuNodeCache) set(items []devicecodec.XPUNode) {
+	nodes := make(map[string]*devicecodec.XPUNodeStatus, len(items))
+	for i := range items {
+		nodes[xpuNodeKey(items[i].Spec.NodeName, items[i].Spec.DeviceType)] = &items[i].Status
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.nodes = nodes
+	c.lastSync = time.Now()
+}
+
+func (c *xpuNodeCache) get(nodeName string, deviceType string) (*devicecodec.XPUNodeStatus, bool) {
+	c.RLock()
+	defer c.RUnlock()
+	status, ok := c.nodes[xpuNodeKey(nodeName, deviceType)]
+	return status, ok
+}
+
+// checkHeartbeat check the device plugin has reported the XPUNode status recently
+func checkHeartbeat(nodeName string, heartbeat time.Time) bool {
+	if time.Since(heartbeat) > time.Second*util.HandshakeTolerateUpdateTime {
+		klog.V(util.LogWarningLevel).Infof("node %v XPUNode heartbeat has not been updated for %v seconds",
+			nodeName, util.HandshakeTolerateUpdateTime)
+		return false
+	}
+	return true
+}
+
+// getNodeXPUDevices get the registered xpu devices of node from its XPUNode object, or from
+// the node annotations when there is no XPUNode object. The failure is returned when there are none
+func (sp *SchedulerPlugin) getNodeXPUDevices(node *api.NodeInfo) (map[int]*common.XPUDevice, error) {
+	if status, ok := xpuNodes.get(node.Name, sp.XPUNodeDeviceType); ok {
+		if !sp.Config.TestEnable && !checkHeartbeat(node.Name, status.HeartbeatTime) {
+			return nil, newNodeFailure(ReasonHandshakeExpired, "handshake expired")
+		}
+		return newXPUDevices(status.Devices, node.Name), nil
+	}
+	infos, ok := node.Node.Annotations[sp.NodeXPURegisterAnno]
+	if !ok {
+		klog.V(util.LogWarningLevel).Infof("Get XPU Devices failed, annotation %s not exist on node %s",
+			sp.NodeXPURegisterAnno, node.Name)
+		return nil, newNodeFailure(ReasonNoDevices, "no xpu devices")
+	}
+	if !sp.Config.TestEnable && !checkHandShake(node, sp.NodeXPUHandshakeAnno) {
+		return nil, newNodeFailure(ReasonHandshakeExpired, "handshake expired")
+	}
+	return DecodeNodeDevices(infos, node.Name), nil
+}
+
+// getNodeXPUTopology get the bandwidth matrix between the xpu devices of node from its XPUNode
+// object, or from the node annotations when there is no XPUNode object
+func (sp *SchedulerPlugin) getNodeXPUTopology(node *api.NodeInfo) ([][]int, bool) {
+	if status, ok := xpuNodes.get(node.Name, sp.XPUNodeDeviceType); ok && len(status.Topology) != 0 {
+		return status.Topology, true
+	}
+	xpuTopoInfo, ok := node.Node.Annotations[sp.NodeXPUTopologyAnno]
+	if !ok {
+		return nil, false
+	}
+	return DecodeNodeXPUTopology(xpuTopoInfo)
+}
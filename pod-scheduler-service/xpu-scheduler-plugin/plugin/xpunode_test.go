@@ -0,0 +1,75 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+
+	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/devicecodec"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const testNodeDevices = `{"version":2,"devices":[{"index":0,"id":"GPU-a","count":10,"memory":40960,` +
+	`"type":"A100","health":true,"numa":0,"memoryRatio":1.5}]}`
+
+// TestGetNodeXPUDevicesMemoryRatio the memory oversubscription ratio is read both from the XPUNode
+// objects and from the node annotations
+func TestGetNodeXPUDevicesMemoryRatio(t *testing.T) {
+	sp := &SchedulerPlugin{
+		Config:              &CommonConfig{TestEnable: true},
+		NodeXPURegisterAnno: util.NodeGPURegisterAnnotation,
+		XPUNodeDeviceType:   util.NvidiaGPUDevice,
+	}
+	devices, err := devicecodec.DecodeNodeDevices(testNodeDevices)
+	if err != nil {
+		t.Fatalf("decode node devices: %v", err)
+	}
+	xpuNode := devicecodec.XPUNode{
+		Spec:   devicecodec.XPUNodeSpec{NodeName: "node-1", DeviceType: util.NvidiaGPUDevice},
+		Status: devicecodec.XPUNodeStatus{Devices: devices},
+	}
+	// the XPUNode list as returned by the api server
+	listData, err := json.Marshal(devicecodec.XPUNodeList{Items: []devicecodec.XPUNode{xpuNode}})
+	if err != nil {
+		t.Fatalf("marshal XPUNode list: %v", err)
+	}
+	tests := []struct {
+		name        string
+		xpuNodes    string
+		annotations map[string]string
+	}{
+		{name: "crd", xpuNodes: string(listData), annotations: map[string]string{}},
+		{name: "annotation", xpuNodes: `{"items":[]}`,
+			annotations: map[string]string{util.NodeGPURegisterAnnotation: testNodeDevices}},
+	}
+	defer LoadXPUNodes(nil)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var list devicecodec.XPUNodeList
+			if err := json.Unmarshal([]byte(tt.xpuNodes), &list); err != nil {
+				t.Fatalf("unmarshal XPUNode list: %v", err)
+			}
+			LoadXPUNodes(list.Items)
+			node := api.NewNodeInfo(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1",
+				Annotations: tt.annotations}})
+			xpuDevices, err := sp.getNodeXPUDevices(node)
+			if err != nil {
+				t.Fatalf("get node xpu devices: %v", err)
+			}
+			device, ok := xpuDevices[0]
+			if !ok {
+				t.Fatalf("device 0 not found in %v", xpuDevices)
+			}
+			if device.MemoryRatio != 1.5 || device.MemoryCapacity() != 61440 {
+				t.Errorf("memory ratio %v capacity %d, want 1.5 and 61440", device.MemoryRatio,
+					device.MemoryCapacity())
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package simulator replays the xpu scheduler plugin on a snapshot of the cluster without a cluster
+package simulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/plugin"
+)
+
+const (
+	// OutputText a table with one row per task
+	OutputText = "text"
+	// OutputJSON the Result as JSON
+	OutputJSON = "json"
+	tabPadding = 2
+)
+
+// Print write the result in the output format
+func (r *Result) Print(w io.Writer, output string) error {
+	switch output {
+	case OutputJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case OutputText:
+		return r.printText(w)
+	default:
+		return fmt.Errorf("unknown output format %q", output)
+	}
+}
+
+func (r *Result) printText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, tabPadding, ' ', 0)
+	fmt.Fprintln(tw, "JOB\tQUEUE\tSTATUS\tTASK\tNODE\tSCORE\tDEVICES/REASON")
+	scheduled, placed := 0, 0
+	for _, job := range r.Jobs {
+		status := "Unschedulable"
+		if job.Scheduled {
+			status = "Scheduled"
+			scheduled++
+		}
+		for i, task := range job.Tasks {
+			jobName, queue, jobStatus := job.Namespace+"/"+job.Name, job.Queue, status
+			if i > 0 {
+				jobName, queue, jobStatus = "", "", ""
+			}
+			node, score, detail := "-", "-", task.Reason
+			if task.Node != "" {
+				node, score, detail = task.Node, fmt.Sprintf("%.2f", task.Score), formatDevices(task.Devices)
+				placed++
+			}
+			if detail == "" {
+				detail = job.Reason
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", jobName, queue, jobStatus, task.Name, node, score, detail)
+		}
+	}
+	fmt.Fprintf(tw, "\n%d/%d jobs scheduled, %d tasks placed\n", scheduled, len(r.Jobs), placed)
+	return tw.Flush()
+}
+
+// formatDevices the devices of each container like "0:GPU-a(mem=4096,cores=30,vid=1)", containers split by ";"
+func formatDevices(devices plugin.PodDevices) string {
+	containers := make([]string, 0, len(devices))
+	for _, container := range devices {
+		devs := make([]string, 0, len(container))
+		for _, dev := range container {
+			devs = append(devs, fmt.Sprintf("%d:%s(mem=%d,cores=%d,vid=%d)",
+				dev.Index, dev.Id, dev.UsedMemory, dev.UsedCores, dev.Vid))
+		}
+		containers = append(containers, strings.Join(devs, ","))
+	}
+	return strings.Join(containers, ";")
+}
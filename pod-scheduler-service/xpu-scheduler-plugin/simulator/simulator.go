@@ -0,0 +1,350 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package simulator replays the xpu scheduler plugin on a snapshot of the cluster without a cluster
+package simulator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/api"
+	"volcano.sh/volcano/pkg/scheduler/framework"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/plugin"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const (
+	// groupNameAnnotation the annotation naming the PodGroup of a pod
+	groupNameAnnotation = "scheduling.k8s.io/group-name"
+	// defaultQueue the queue of the jobs whose PodGroup is not in the snapshot
+	defaultQueue = "default"
+	sessionID    = "xpu-simulator"
+)
+
+// TaskResult the placement of a pending task, or why it got none
+type TaskResult struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Node      string            `json:"node,omitempty"`
+	Score     float64           `json:"score,omitempty"`
+	Devices   plugin.PodDevices `json:"devices,omitempty"`
+	Reason    string            `json:"reason,omitempty"`
+}
+
+// JobResult the outcome of a job with pending xpu tasks, the job is scheduled when at least
+// MinAvailable tasks are placed, otherwise none of its tasks is
+type JobResult struct {
+	Name         string       `json:"name"`
+	Namespace    string       `json:"namespace"`
+	Queue        string       `json:"queue"`
+	MinAvailable int32        `json:"minAvailable"`
+	Scheduled    bool         `json:"scheduled"`
+	ReasonType   string       `json:"reasonType,omitempty"`
+	Reason       string       `json:"reason,omitempty"`
+	Tasks        []TaskResult `json:"tasks"`
+}
+
+// Result the outcome of the jobs in the order they were scheduled
+type Result struct {
+	Jobs []JobResult `json:"jobs"`
+}
+
+// simulation one scheduling session over the snapshot
+type simulation struct {
+	handler *plugin.ScheduleHandler
+	session *framework.Session
+	order   []*api.JobInfo
+}
+
+// Run schedule the pending pods of the snapshot one job after another with the handler, the same way the
+// allocate action does with the plugin alone: each task goes to the best scored node passing the predicate,
+// and the placements of a job are rolled back when fewer than MinAvailable tasks are placed
+func Run(sh *plugin.ScheduleHandler, snapshot *Snapshot) (*Result, error) {
+	if sh == nil || snapshot == nil {
+		return nil, errors.New(util.ArgumentError)
+	}
+	sim, err := newSimulation(sh, snapshot)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	for _, job := range sim.order {
+		if jobResult, ok := sim.scheduleJob(job); ok {
+			result.Jobs = append(result.Jobs, jobResult)
+		}
+	}
+	return result, nil
+}
+
+func newSimulation(sh *plugin.ScheduleHandler, snapshot *Snapshot) (*simulation, error) {
+	ssn := &framework.Session{
+		UID:   sessionID,
+		Jobs:  map[api.JobID]*api.JobInfo{},
+		Nodes: map[string]*api.NodeInfo{},
+	}
+	for _, node := range snapshot.Nodes {
+		nodeInfo := api.NewNodeInfo(node)
+		ssn.Nodes[node.Name] = nodeInfo
+		ssn.NodeList = append(ssn.NodeList, nodeInfo)
+	}
+	sort.Slice(ssn.NodeList, func(i, j int) bool {
+		return ssn.NodeList[i].Name < ssn.NodeList[j].Name
+	})
+
+	podGroups := make(map[string]*PodGroup, len(snapshot.PodGroups))
+	for _, pg := range snapshot.PodGroups {
+		podGroups[pg.Namespace+"/"+pg.Name] = pg
+	}
+	jobTasks := make(map[api.JobID][]*api.TaskInfo)
+	jobGroups := make(map[api.JobID]string)
+	for _, pod := range snapshot.Pods {
+		if pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		preparePod(pod)
+		groupName := podGroupName(pod)
+		jobID := api.JobID(pod.Namespace + "/" + groupName)
+		task := api.NewTaskInfo(pod)
+		task.Job = jobID
+		jobTasks[jobID] = append(jobTasks[jobID], task)
+		jobGroups[jobID] = groupName
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		nodeInfo, ok := ssn.Nodes[pod.Spec.NodeName]
+		if !ok {
+			klog.V(util.LogWarningLevel).Infof("node %s of pod %s/%s is not in snapshot.",
+				pod.Spec.NodeName, pod.Namespace, pod.Name)
+			continue
+		}
+		if err := nodeInfo.AddTask(task); err != nil {
+			return nil, fmt.Errorf("add pod %s/%s to node %s failed: %v", pod.Namespace, pod.Name, nodeInfo.Name, err)
+		}
+	}
+	for jobID, tasks := range jobTasks {
+		job := api.NewJobInfo(jobID, tasks...)
+		initJobPodGroup(job, tasks[0].Namespace, jobGroups[jobID], podGroups)
+		ssn.Jobs[jobID] = job
+	}
+
+	if err := sh.InitXPUSession(ssn); err != nil {
+		return nil, err
+	}
+	plugin.LoadXPUNodes(snapshot.XPUNodes)
+	return &simulation{handler: sh, session: ssn, order: jobOrder(ssn.Jobs)}, nil
+}
+
+// preparePod fill what the plugin relies on and a hand written pod may miss
+func preparePod(pod *v1.Pod) {
+	if pod.UID == "" {
+		pod.UID = types.UID(pod.Namespace + "/" + pod.Name)
+	}
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+}
+
+func podGroupName(pod *v1.Pod) string {
+	if name, ok := pod.Annotations[groupNameAnnotation]; ok && name != "" {
+		return name
+	}
+	return "podgroup-" + pod.Name
+}
+
+// initJobPodGroup set the PodGroup of the job from the snapshot, a job without one in the snapshot
+// needs a single task like a bare pod does
+func initJobPodGroup(job *api.JobInfo, namespace string, name string, podGroups map[string]*PodGroup) {
+	pg := &api.PodGroup{}
+	pg.Name = name
+	pg.Namespace = namespace
+	pg.Spec.MinMember = 1
+	pg.Spec.Queue = defaultQueue
+	if snapshotPG, ok := podGroups[namespace+"/"+name]; ok {
+		pg.UID = snapshotPG.UID
+		pg.Labels = snapshotPG.Labels
+		pg.Annotations = snapshotPG.Annotations
+		pg.OwnerReferences = snapshotPG.OwnerReferences
+		pg.CreationTimestamp = snapshotPG.CreationTimestamp
+		if snapshotPG.Spec.MinMember > 0 {
+			pg.Spec.MinMember = snapshotPG.Spec.MinMember
+		}
+		if snapshotPG.Spec.Queue != "" {
+			pg.Spec.Queue = snapshotPG.Spec.Queue
+		}
+	}
+	pg.Status.Phase = util.PodGroupPending
+	job.Name = name
+	job.Namespace = namespace
+	job.Queue = api.QueueID(pg.Spec.Queue)
+	job.MinAvailable = pg.Spec.MinMember
+	job.CreationTimestamp = pg.CreationTimestamp
+	job.PodGroup = pg
+}
+
+// jobOrder the jobs in creation order, the name breaking ties
+func jobOrder(jobs map[api.JobID]*api.JobInfo) []*api.JobInfo {
+	order := make([]*api.JobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		order = append(order, job)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		ti, tj := order[i].CreationTimestamp, order[j].CreationTimestamp
+		if !ti.Equal(&tj) {
+			return ti.Before(&tj)
+		}
+		return order[i].UID < order[j].UID
+	})
+	return order
+}
+
+// pendingTasks the xpu tasks of the job without a node, in name order
+func pendingTasks(job *api.JobInfo, sJob *plugin.SchedulerJob) []*api.TaskInfo {
+	var tasks []*api.TaskInfo
+	for _, task := range job.Tasks {
+		if task.NodeName == "" && plugin.IsXPUTask(sJob, task) {
+			tasks = append(tasks, task)
+		}
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].Name < tasks[j].Name
+	})
+	return tasks
+}
+
+// scheduleJob place the pending xpu tasks of the job, false if the job has none
+func (s *simulation) scheduleJob(job *api.JobInfo) (JobResult, bool) {
+	sJob, ok := s.handler.Jobs[job.UID]
+	if !ok {
+		return JobResult{}, false
+	}
+	tasks := pendingTasks(job, sJob)
+	if len(tasks) == 0 {
+		return JobResult{}, false
+	}
+	result := JobResult{
+		Name:         job.Name,
+		Namespace:    job.Namespace,
+		Queue:        string(job.Queue),
+		MinAvailable: job.MinAvailable,
+	}
+	if valid := s.handler.JobValid(job); valid != nil {
+		result.ReasonType, result.Reason = valid.Reason, valid.Message
+		for _, task := range tasks {
+			result.Tasks = append(result.Tasks, TaskResult{Name: task.Name, Namespace: task.Namespace})
+		}
+		return result, true
+	}
+
+	var placed []*api.TaskInfo
+	for _, task := range tasks {
+		taskResult := s.scheduleTask(sJob, task)
+		if taskResult.Node != "" {
+			placed = append(placed, task)
+		}
+		result.Tasks = append(result.Tasks, taskResult)
+	}
+	if sJob.IsJobReady(job) {
+		result.Scheduled = true
+		return result, true
+	}
+
+	for _, task := range placed {
+		s.rollbackTask(task)
+	}
+	for i := range result.Tasks {
+		result.Tasks[i].Node, result.Tasks[i].Score, result.Tasks[i].Devices = "", 0, nil
+	}
+	reasonType, summary := sJob.UnschedulableReason.Summary()
+	if summary == "" {
+		reasonType, summary = plugin.ReasonNotEnoughCards, fmt.Sprintf("%d of %d pending tasks placed, "+
+			"min available %d", len(placed), len(tasks), job.MinAvailable)
+	}
+	result.ReasonType, result.Reason = string(reasonType), summary
+	return result, true
+}
+
+// scheduleTask run the predicate of the task on every node, then allocate the devices of the best
+// scored node passing it
+func (s *simulation) scheduleTask(sJob *plugin.SchedulerJob, task *api.TaskInfo) TaskResult {
+	taskResult := TaskResult{Name: task.Name, Namespace: task.Namespace}
+	taskReason := &plugin.UnschedulableReason{NodeFailures: map[plugin.NodeFailure]map[string]struct{}{},
+		Mutex: &sync.Mutex{}}
+	var fitNodes []*api.NodeInfo
+	for _, node := range s.session.NodeList {
+		if err := s.handler.NodePredicate(task, node); err != nil {
+			taskReason.AddNodeFailure(node.Name, err)
+			sJob.AddNodeFailure(node.Name, err)
+			continue
+		}
+		fitNodes = append(fitNodes, node)
+	}
+	if len(fitNodes) == 0 {
+		_, taskResult.Reason = taskReason.Summary()
+		return taskResult
+	}
+
+	scores, err := s.handler.BatchNodeOrderFn(task, fitNodes)
+	if err != nil {
+		taskResult.Reason = err.Error()
+		return taskResult
+	}
+	best := fitNodes[0]
+	for _, node := range fitNodes[1:] {
+		if scores[node.Name] > scores[best.Name] {
+			best = node
+		}
+	}
+
+	task.NodeName = best.Name
+	task.Status = api.Allocated
+	s.handler.XPUAllocateFunc(task, s.session)
+	xpuTask := sJob.Tasks[task.UID]
+	xpuTask.Lock()
+	allocated := xpuTask.Allocated
+	xpuTask.Unlock()
+	if !allocated {
+		task.NodeName, task.Status = "", api.Pending
+		taskResult.Reason = fmt.Sprintf("allocate xpu on node %s failed", best.Name)
+		return taskResult
+	}
+	if err := best.AddTask(task); err != nil {
+		s.handler.XPUDeallocateFunc(task, s.session)
+		task.NodeName, task.Status = "", api.Pending
+		taskResult.Reason = fmt.Sprintf("add task to node %s failed: %v", best.Name, err)
+		return taskResult
+	}
+	taskResult.Node = best.Name
+	taskResult.Score = scores[best.Name]
+	taskResult.Devices = podDevices(task.Pod)
+	return taskResult
+}
+
+// rollbackTask give back the devices of a task placed by the simulation
+func (s *simulation) rollbackTask(task *api.TaskInfo) {
+	node, ok := s.session.Nodes[task.NodeName]
+	if !ok {
+		return
+	}
+	s.handler.XPUDeallocateFunc(task, s.session)
+	if err := node.RemoveTask(task); err != nil {
+		klog.V(util.LogWarningLevel).Infof("remove task %s from node %s failed: %v", task.Name, node.Name, err)
+	}
+	task.NodeName, task.Status = "", api.Pending
+}
+
+// podDevices the devices the plugin assigned to the pod
+func podDevices(pod *v1.Pod) plugin.PodDevices {
+	for _, anno := range []string{util.AssignedGPUsToPodAnnotations, util.AssignedNPUsToPodAnnotations} {
+		if value, ok := pod.Annotations[anno]; ok {
+			return plugin.DecodePodDevices(value)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package simulator replays the xpu scheduler plugin on a snapshot of the cluster without a cluster
+package simulator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/klog/v2"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/devicecodec"
+	"volcano.sh/volcano/pkg/scheduler/plugins/xpu-scheduler-plugin/util"
+)
+
+const (
+	kindNode      = "Node"
+	kindPod       = "Pod"
+	kindPodGroup  = "PodGroup"
+	kindList      = "List"
+	decodeBufSize = 4096
+)
+
+// PodGroup the part of a volcano PodGroup the simulation needs
+type PodGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              PodGroupSpec `json:"spec,omitempty"`
+}
+
+// PodGroupSpec the gang requirement and the queue of a PodGroup
+type PodGroupSpec struct {
+	MinMember int32  `json:"minMember,omitempty"`
+	Queue     string `json:"queue,omitempty"`
+}
+
+// Snapshot the objects of a cluster the simulation runs on. The pods with a node name are placed
+// already, the others are pending and scheduled by the simulation
+type Snapshot struct {
+	Nodes     []*v1.Node
+	Pods      []*v1.Pod
+	PodGroups []*PodGroup
+	XPUNodes  []devicecodec.XPUNode
+}
+
+// LoadSnapshot read the objects of the YAML or JSON files, a directory is read file by file in name order.
+// A file holds one or several documents, each one an object or a List of objects as printed by kubectl
+func LoadSnapshot(paths []string) (*Snapshot, error) {
+	snapshot := &Snapshot{}
+	for _, path := range paths {
+		files, err := snapshotFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			if err := snapshot.loadFile(file); err != nil {
+				return nil, fmt.Errorf("load %s failed: %v", file, err)
+			}
+		}
+	}
+	if len(snapshot.Nodes) == 0 {
+		return nil, errors.New("no node in snapshot")
+	}
+	return snapshot, nil
+}
+
+func snapshotFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, entry := range entries {
+		ext := strings.ToLower(filepath.Ext(entry.Name()))
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+		files = append(files, filepath.Join(path, entry.Name()))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (s *Snapshot) loadFile(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	decoder := yamlutil.NewYAMLOrJSONDecoder(f, decodeBufSize)
+	for {
+		var doc json.RawMessage
+		if err := decoder.Decode(&doc); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if len(doc) == 0 || string(doc) == "null" {
+			continue
+		}
+		if err := s.addObject(doc); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Snapshot) addObject(data []byte) error {
+	var object struct {
+		metav1.TypeMeta `json:",inline"`
+		Items           []json.RawMessage `json:"items"`
+	}
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	switch object.Kind {
+	case kindList, kindNode + kindList, kindPod + kindList, kindPodGroup + kindList, devicecodec.XPUNodeKind + kindList:
+		for _, item := range object.Items {
+			if err := s.addObject(item); err != nil {
+				return err
+			}
+		}
+	case kindNode:
+		node := &v1.Node{}
+		if err := json.Unmarshal(data, node); err != nil {
+			return err
+		}
+		s.Nodes = append(s.Nodes, node)
+	case kindPod:
+		pod := &v1.Pod{}
+		if err := json.Unmarshal(data, pod); err != nil {
+			return err
+		}
+		s.Pods = append(s.Pods, pod)
+	case kindPodGroup:
+		pg := &PodGroup{}
+		if err := json.Unmarshal(data, pg); err != nil {
+			return err
+		}
+		s.PodGroups = append(s.PodGroups, pg)
+	case devicecodec.XPUNodeKind:
+		xpuNode := devicecodec.XPUNode{}
+		if err := json.Unmarshal(data, &xpuNode); err != nil {
+			return err
+		}
+		s.XPUNodes = append(s.XPUNodes, xpuNode)
+	default:
+		klog.V(util.LogWarningLevel).Infof("snapshot object of kind %q is ignored.", object.Kind)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package util defines data structure and provide util function for xpu scheduler plugin implementation
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// NodeBandwidthLevel a level of the node label hierarchy, two nodes with the same value of Label
+// are linked by Bandwidth
+type NodeBandwidthLevel struct {
+	Label     string
+	Bandwidth int
+}
+
+var (
+	// XPUTopologyNodeBandwidthLevels the node label hierarchy from the closest level, for example
+	// same switch, same rack then same spine
+	XPUTopologyNodeBandwidthLevels []NodeBandwidthLevel
+)
+
+// ParseNodeBandwidthLevels parse levels like "example.com/switch=400,example.com/rack=200"
+func ParseNodeBandwidthLevels(value string) ([]NodeBandwidthLevel, error) {
+	var levels []NodeBandwidthLevel
+	for _, item := range strings.Split(value, Comma) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, "=")
+		if len(parts) != Base2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid node bandwidth level %q", item)
+		}
+		bandwidth, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || bandwidth < 0 {
+			return nil, fmt.Errorf("invalid bandwidth of node bandwidth level %q", item)
+		}
+		levels = append(levels, NodeBandwidthLevel{Label: strings.TrimSpace(parts[0]), Bandwidth: bandwidth})
+	}
+	return levels, nil
+}
+
+// NodeBandwidth bandwidth between the nodes of a session. The XPUTopologyNodeBandwidth matrix overrides
+// the bandwidth derived from the XPUTopologyNodeBandwidthLevels hierarchy, each pair is computed once
+type NodeBandwidth struct {
+	labels    map[string]map[string]string
+	overrides map[string]map[string]int
+	levels    []NodeBandwidthLevel
+	cache     map[string]int
+	max       int
+	sync.Mutex
+}
+
+// NewNodeBandwidth the bandwidth between the nodes of the labels, keyed by node name
+func NewNodeBandwidth(labels map[string]map[string]string) *NodeBandwidth {
+	b := &NodeBandwidth{
+		labels:    labels,
+		overrides: XPUTopologyNodeBandwidth,
+		levels:    XPUTopologyNodeBandwidthLevels,
+		cache:     map[string]int{},
+	}
+	for _, bandwidths := range b.overrides {
+		for _, bandwidth := range bandwidths {
+			if bandwidth > b.max {
+				b.max = bandwidth
+			}
+		}
+	}
+	for _, level := range b.levels {
+		if level.Bandwidth > b.max {
+			b.max = level.Bandwidth
+		}
+	}
+	return b
+}
+
+// IsEmpty check whether no bandwidth between nodes is configured
+func (b *NodeBandwidth) IsEmpty() bool {
+	return b == nil || (len(b.overrides) == 0 && len(b.levels) == 0)
+}
+
+// Max the largest bandwidth between two nodes
+func (b *NodeBandwidth) Max() int {
+	if b == nil {
+		return 0
+	}
+	return b.max
+}
+
+// Get the bandwidth between the two nodes, 0 if they share no level and have no override
+func (b *NodeBandwidth) Get(nodei string, nodej string) int {
+	if b == nil {
+		return 0
+	}
+	_, oki := b.overrides[nodei]
+	_, okj := b.overrides[nodej]
+	if oki && okj {
+		return b.overrides[nodei][nodej]
+	}
+	key := nodei + "/" + nodej
+	if nodej < nodei {
+		key = nodej + "/" + nodei
+	}
+	b.Lock()
+	defer b.Unlock()
+	if bandwidth, ok := b.cache[key]; ok {
+		return bandwidth
+	}
+	bandwidth := b.levelBandwidth(nodei, nodej)
+	b.cache[key] = bandwidth
+	return bandwidth
+}
+
+// levelBandwidth the bandwidth of the closest level the two nodes share
+func (b *NodeBandwidth) levelBandwidth(nodei string, nodej string) int {
+	labelsi, labelsj := b.labels[nodei], b.labels[nodej]
+	for _, level := range b.levels {
+		valuei, oki := labelsi[level.Label]
+		valuej, okj := labelsj[level.Label]
+		if oki && okj && valuei == valuej {
+			return level.Bandwidth
+		}
+	}
+	return 0
+}
@@ -0,0 +1,89 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package util defines data structure and provide util function for xpu scheduler plugin implementation
+package util
+
+import (
+	"sort"
+	"strings"
+
+	"k8s.io/api/core/v1"
+)
+
+// XPUTypeRequest the card types a container accepts. Preferred lists the acceptable types in preference
+// order, any type not excluded is acceptable when it is empty. Excluded lists the types never accepted
+type XPUTypeRequest struct {
+	Preferred []string
+	Excluded  []string
+}
+
+// IsEmpty check whether the request has no card type constraint
+func (r XPUTypeRequest) IsEmpty() bool {
+	return len(r.Preferred) == 0 && len(r.Excluded) == 0
+}
+
+// Accept check whether a card of the type meets the request
+func (r XPUTypeRequest) Accept(cardType string) bool {
+	for _, excluded := range r.Excluded {
+		if excluded == cardType {
+			return false
+		}
+	}
+	return len(r.Preferred) == 0 || r.Rank(cardType) >= 0
+}
+
+// Rank position of the type in the preference order, -1 if it is not listed
+func (r XPUTypeRequest) Rank(cardType string) int {
+	for i, preferred := range r.Preferred {
+		if preferred == cardType {
+			return i
+		}
+	}
+	return -1
+}
+
+// Penalty how far the type is from the most preferred one in range [0, 1], 0 when there is no preference
+func (r XPUTypeRequest) Penalty(cardType string) float64 {
+	rank := r.Rank(cardType)
+	if len(r.Preferred) < Base2 || rank <= 0 {
+		return 0
+	}
+	return float64(rank) / float64(len(r.Preferred)-1)
+}
+
+// String the request like "L20,A100,!T4"
+func (r XPUTypeRequest) String() string {
+	types := append([]string{}, r.Preferred...)
+	for _, excluded := range r.Excluded {
+		types = append(types, "!"+excluded)
+	}
+	return strings.Join(types, Comma)
+}
+
+// GetXPUTypeRequest get the card types configured on the container. Every xpuType limit names an acceptable
+// type, its value gives the preference order, the lowest first. Every xpuExcludeType limit names an excluded type
+func GetXPUTypeRequest(container *v1.Container, xpuType string, xpuExcludeType string) XPUTypeRequest {
+	request := XPUTypeRequest{}
+	ranks := map[string]int64{}
+	for k, v := range container.Resources.Limits {
+		switch {
+		case strings.HasPrefix(string(k), xpuType):
+			cardType := strings.TrimPrefix(string(k), xpuType)
+			request.Preferred = append(request.Preferred, cardType)
+			ranks[cardType] = v.Value()
+		case strings.HasPrefix(string(k), xpuExcludeType):
+			request.Excluded = append(request.Excluded, strings.TrimPrefix(string(k), xpuExcludeType))
+		}
+	}
+	sort.Slice(request.Preferred, func(i, j int) bool {
+		ri, rj := ranks[request.Preferred[i]], ranks[request.Preferred[j]]
+		if ri != rj {
+			return ri < rj
+		}
+		return request.Preferred[i] < request.Preferred[j]
+	})
+	sort.Strings(request.Excluded)
+	return request
+}
@@ -20,8 +20,11 @@ type XPUJob struct {
 	SelectServers     string
 	XPUTaskNum        int
 	SchedulingTaskNum int
-	ReqXPUName        string
-	ReqXPUNum         int
+	// ReqXPUName the first of ReqXPUNames
+	ReqXPUName string
+	// ReqXPUNames xpu names requested by the tasks, more than one when the job mixes gpu and npu tasks
+	ReqXPUNames []string
+	ReqXPUNum   int
 	// ReqXPUInterBandwidth for minimum bandwidth rate between pods.
 	// ReqXPUInterBandwidth[taskName1][taskName2]=50 means bandwidth between
 	// pod in task1 and pod in task2 should larger than 50.
@@ -37,7 +40,9 @@ type XPUJob struct {
 type TopologyScheduleXPUs struct {
 	// AllocateXPUs containerName: xpu id list
 	AllocateXPUs []int
-	NodeName     string
+	// ContainerXPUs xpu id list of each xpu container in container order
+	ContainerXPUs [][]int
+	NodeName      string
 }
 
 // GetXPUTaskNumInJob get the XPU task number in one job. for some task has no XPU.
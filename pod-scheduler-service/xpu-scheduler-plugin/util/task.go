@@ -24,6 +24,8 @@ type XPUTask struct {
 	IsVXPUTask bool
 	//ReqXPUIntraBandwidth for minimum bandwidth rate between pod's xpus
 	ReqXPUIntraBandwidth int
+	// ContainerRequests xpu requests of the containers applying xpu devices, in container order
+	ContainerRequests []ContainerRequest
 	// Selector the same as job
 	Selector   map[string]string
 	Annotation map[string]string
@@ -31,6 +33,8 @@ type XPUTask struct {
 	NodeName   string
 	PodStatus  v1.PodPhase
 	ScoreMap   map[string]float64
+	// Allocated the task got xpu devices in the current session
+	Allocated bool
 	sync.Mutex
 }
 
@@ -38,15 +42,32 @@ type XPUTask struct {
 type TaskResource struct {
 	ReqXPUName          string
 	ReqXPUNum           int
-	ReqXPUType          string
+	ReqXPUType          XPUTypeRequest
 	ReqXPUCores         int
 	ReqXPUMem           int
 	ReqXPUMemPercentage int
+	// ReqXPUUUID the devices the pod is pinned on or kept away from
+	ReqXPUUUID XPUUUIDRequest
+	// ReqXPUQos the qos class of the container
+	ReqXPUQos string
+	// ReqXPUQosConflicts the qos classes the container may not share a card with
+	ReqXPUQosConflicts []string
+	// ReservedXPUs indexes of the devices reserved for a starving topology job the container may not use
+	ReservedXPUs map[int]struct{}
 }
 
 // ContainerResource for xpu container
 type ContainerResource TaskResource
 
+// ContainerRequest for the topology request of one xpu container
+type ContainerRequest struct {
+	ReqXPUNum   int
+	ReqXPUType  XPUTypeRequest
+	ReqXPUCores int
+	//ReqXPUIntraBandwidth for minimum bandwidth rate between container's xpus
+	ReqXPUIntraBandwidth int
+}
+
 // UpdatePodPendingReason update pod pending reason.
 func (xTask *XPUTask) UpdatePodPendingReason(taskInfo *api.TaskInfo, reason string) error {
 	if xTask == nil {
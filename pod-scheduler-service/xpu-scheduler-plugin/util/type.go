@@ -26,8 +26,6 @@ const (
 	XPUHexKilo = 1000
 	// MapInitNum for map init length.
 	MapInitNum = 3
-	// XPUDeviceLen for xpu device param number.
-	XPUDeviceLen = 7
 	// Base2 for const 2.
 	Base2 = 2
 	// Base10 for const 10.
@@ -77,12 +75,19 @@ const (
 
 	// VGPUName for GPU card
 	VGPUName = "huawei.com/vgpu-number"
-	// VGPUType for GPU card, for example: huawei.com/vgpu-type.L20: 1
+	// VGPUType for GPU card, for example: huawei.com/vgpu-type.L20: 1, several types are tried in the
+	// order of their values, for example: huawei.com/vgpu-type.L20: 1 and huawei.com/vgpu-type.A100: 2
 	VGPUType = "huawei.com/vgpu-type."
+	// VGPUExcludeType for GPU card type never to use, for example: huawei.com/vgpu-exclude-type.T4: 1
+	VGPUExcludeType = "huawei.com/vgpu-exclude-type."
+	// VGPUQos for the qos class of a vgpu container, for example: huawei.com/vgpu-qos.burstable: 1
+	VGPUQos = "huawei.com/vgpu-qos."
 	// VGPUCore for vgpu core
 	VGPUCore = "huawei.com/vgpu-cores"
 	// VGPUMemory for vgpu memory
 	VGPUMemory = "huawei.com/vgpu-memory.1Gi"
+	// VGPUMemoryMiB for vgpu memory in MiB, not to be mixed with VGPUMemory in a pod
+	VGPUMemoryMiB = "huawei.com/vgpu-memory.1Mi"
 	// NvidiaGPUDevice device type supported by the device plugin
 	NvidiaGPUDevice = "GPU"
 	// NodeGPURegisterAnnotation for gpu register annotation
@@ -102,10 +107,16 @@ const (
 	VNPUName = "huawei.com/vnpu-number"
 	// VNPUType for NPU card, for example: huawei.com/vnpu-type.310P: 1
 	VNPUType = "huawei.com/vnpu-type."
+	// VNPUExcludeType for NPU card type never to use, for example: huawei.com/vnpu-exclude-type.310P: 1
+	VNPUExcludeType = "huawei.com/vnpu-exclude-type."
+	// VNPUQos for the qos class of a vnpu container, for example: huawei.com/vnpu-qos.burstable: 1
+	VNPUQos = "huawei.com/vnpu-qos."
 	// VNPUCore for vnpu core
 	VNPUCore = "huawei.com/vnpu-cores"
 	// VNPUMemory for vnpu memory
 	VNPUMemory = "huawei.com/vnpu-memory.1Gi"
+	// VNPUMemoryMiB for vnpu memory in MiB, not to be mixed with VNPUMemory in a pod
+	VNPUMemoryMiB = "huawei.com/vnpu-memory.1Mi"
 	// AscendNPUDevice device type supported by the device plugin
 	AscendNPUDevice = "NPU"
 	// NodeNPURegisterAnnotation for npu register annotation
@@ -136,9 +147,33 @@ const (
 	XPUTopologyInterBandwidthAnnotation = "huawei.com/inter-bandwidth"
 	// TaskSpec set origin task name for pod
 	TaskSpec = "volcano.sh/task-spec"
+	// XPUUseUUIDAnnotation pin the pod on the devices of the uuids split by ",", for example: GPU-a,GPU-b
+	XPUUseUUIDAnnotation = "huawei.com/use-xpu-uuid"
+	// XPUNoUseUUIDAnnotation keep the pod away from the devices of the uuids split by ","
+	XPUNoUseUUIDAnnotation = "huawei.com/nouse-xpu-uuid"
+	// XPUNumaNodesAnnotation the NUMA nodes the CPUs of the pod are bound to split by ",", for example: 0,1
+	XPUNumaNodesAnnotation = "huawei.com/numa-nodes"
+	// XPUResizeAnnotation resize the vxpu limits of running containers in place, split by ";" for each container,
+	// for example: c1:cores=50,memory=4;c2:memoryMi=2048
+	XPUResizeAnnotation = "huawei.com/vxpu-resize"
+	// XPUResizeHandledAnnotation the resize request the scheduler handled last
+	XPUResizeHandledAnnotation = "huawei.com/vxpu-resize-handled"
+	// XPUResizeStatusAnnotation status of the last resize request
+	XPUResizeStatusAnnotation = "huawei.com/vxpu-resize-status"
+	// XPUResizeMessageAnnotation why the last resize request was rejected or failed
+	XPUResizeMessageAnnotation = "huawei.com/vxpu-resize-message"
+	// XPUResizeAccepted the resize fits the devices and is charged on them, the device plugin applies it next
+	XPUResizeAccepted = "accepted"
+	// XPUResizeRejected the resize does not fit the devices of the containers
+	XPUResizeRejected = "rejected"
+	// XPUResizeApplied the device plugin rewrote the vxpu config of the containers
+	XPUResizeApplied = "applied"
+	// XPUResizeFailed the device plugin failed to rewrite the vxpu config of the containers
+	XPUResizeFailed = "failed"
 )
 
 var (
-	// XPUTopologyNodeBandwidth bandwidth between nodes, should be an n*n matrix, n is node number in XPUTopologyNodeList
+	// XPUTopologyNodeBandwidth bandwidth between nodes, should be an n*n matrix, n is node number in XPUTopologyNodeList,
+	// it overrides the bandwidth derived from XPUTopologyNodeBandwidthLevels
 	XPUTopologyNodeBandwidth map[string]map[string]int
 )
@@ -44,17 +44,6 @@ func GetVXPUResource(container *v1.Container, resourceName string) int {
 	return 0
 }
 
-// GetXPUType get the xpu device type if configured
-func GetXPUType(container *v1.Container, xpuType string) string {
-	for k := range container.Resources.Limits {
-		if strings.HasPrefix(string(k), xpuType) {
-			device := strings.TrimPrefix(string(k), xpuType)
-			return device
-		}
-	}
-	return ""
-}
-
 // ConvertMatrix2Map convert matrix to map
 func ConvertMatrix2Map(matrix []string, elementList []string) (map[string]map[string]int, error) {
 	matrixMap := make(map[string]map[string]int)
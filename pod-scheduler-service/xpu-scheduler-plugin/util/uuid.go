@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package util defines data structure and provide util function for xpu scheduler plugin implementation
+package util
+
+import (
+	"strings"
+)
+
+// XPUUUIDRequest the physical devices a pod is pinned on by Use, any device if it is empty,
+// and the devices it is kept away from by NoUse
+type XPUUUIDRequest struct {
+	Use   []string
+	NoUse []string
+}
+
+// IsEmpty check whether the request has no device constraint
+func (r XPUUUIDRequest) IsEmpty() bool {
+	return len(r.Use) == 0 && len(r.NoUse) == 0
+}
+
+// Accept check whether the device of the uuid meets the request
+func (r XPUUUIDRequest) Accept(uuid string) bool {
+	if containsString(r.NoUse, uuid) {
+		return false
+	}
+	return len(r.Use) == 0 || containsString(r.Use, uuid)
+}
+
+// String the request like "use:GPU-a,GPU-b nouse:GPU-c"
+func (r XPUUUIDRequest) String() string {
+	return "use:" + strings.Join(r.Use, Comma) + " nouse:" + strings.Join(r.NoUse, Comma)
+}
+
+// GetXPUUUIDRequest get the devices the pod is pinned on or kept away from by its annotations
+func GetXPUUUIDRequest(annotations map[string]string) XPUUUIDRequest {
+	return XPUUUIDRequest{
+		Use:   splitUUIDs(annotations[XPUUseUUIDAnnotation]),
+		NoUse: splitUUIDs(annotations[XPUNoUseUUIDAnnotation]),
+	}
+}
+
+func splitUUIDs(value string) []string {
+	var uuids []string
+	for _, uuid := range strings.Split(value, Comma) {
+		if uuid = strings.TrimSpace(uuid); uuid != "" {
+			uuids = append(uuids, uuid)
+		}
+	}
+	return uuids
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
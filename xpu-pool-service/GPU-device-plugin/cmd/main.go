@@ -104,6 +104,10 @@ func start() error {
 	register := plugin.NewDeviceRegister(cache)
 	register.Start()
 
+	// 启动容器扩缩容器，将调度器已接受的 vGPU 规格原地调整写入容器的 vgpu.config
+	resizer := plugin.NewContainerResizer()
+	resizer.Start()
+
 	// 启动 PIDs 服务，提供 gRPC 服务供客户端查询进程 ID 配置
 	// 这个服务会被 client/client.go 中的客户端工具调用
 	service.Start()
@@ -147,6 +151,17 @@ func main() {
 	flag.StringVar(&resourceName, "resource-name", xpu.VxpuNumber, "resource name")
 	// GPU 类型配置文件：GPU 类型配置文件的绝对路径
 	flag.StringVar(&config.GPUTypeConfig, "gpu-type-config", "", "the abs path map of gpu type config file")
+	// 显存超分比例：节点上设备的显存超分比例，1 表示不超分
+	flag.Float64Var(&config.MemoryOversubscriptionRatio, "memory-oversubscription-ratio", 1,
+		"the memory oversubscription ratio of the devices of the node, 1 for none")
+	// 显存超分配置文件：按设备类型配置显存超分比例的配置文件绝对路径，覆盖节点的超分比例
+	flag.StringVar(&config.MemoryOversubscriptionConfig, "memory-oversubscription-config", "",
+		"the abs path of the memory oversubscription ratio per device type config file")
+	// 节点注解兼容模式：除 XPUNode 对象外，同时将设备信息注册到节点注解中，默认开启，
+	// 以便滚动升级期间仅读取节点注解的旧调度器仍能看到握手信息；所有调度器都读取 XPUNode 后可关闭
+	flag.BoolVar(&config.RegisterAnnotations, "register-annotations", true,
+		"register devices in node annotations besides the XPUNode object, "+
+			"turn it off once all the schedulers read XPUNode")
 
 	// 解析命令行参数
 	flag.Parse()
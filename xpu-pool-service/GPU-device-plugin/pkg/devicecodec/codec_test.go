@@ -0,0 +1,160 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package devicecodec
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// schedulerCodecDir the copy of the package in the scheduler plugin, the two copies must stay the same
+var schedulerCodecDir = filepath.Join("..", "..", "..", "..", "pod-scheduler-service", "xpu-scheduler-plugin",
+	"devicecodec")
+
+func TestDecodeNodeDevices(t *testing.T) {
+	tests := []struct {
+		name    string
+		str     string
+		want    []NodeDevice
+		wantErr bool
+	}{
+		{name: "legacy", str: "0,GPU-a,10,40960,A100,true,0:1,GPU-b,10,40960,A100,false,1:",
+			want: []NodeDevice{
+				{Index: 0, Id: "GPU-a", Count: 10, Memory: 40960, Type: "A100", Health: true, Numa: 0},
+				{Index: 1, Id: "GPU-b", Count: 10, Memory: 40960, Type: "A100", Health: false, Numa: 1},
+			}},
+		{name: "versioned", str: `{"version":2,"devices":[{"index":0,"id":"GPU-a","count":10,"memory":40960,` +
+			`"type":"A100","health":true,"numa":0,"memoryRatio":1.5}]}`,
+			want: []NodeDevice{
+				{Index: 0, Id: "GPU-a", Count: 10, Memory: 40960, Type: "A100", Health: true, Numa: 0,
+					MemoryRatio: 1.5},
+			}},
+		{name: "versioned with spaces", str: ` {"version":2,"devices":[]}`, want: []NodeDevice{}},
+		{name: "legacy wrong field number", str: "0,GPU-a,10,40960,A100,true:", wantErr: true},
+		{name: "legacy bad number", str: "0,GPU-a,ten,40960,A100,true,0:", wantErr: true},
+		{name: "legacy without separator", str: "0,GPU-a,10,40960,A100,true,0", wantErr: true},
+		{name: "versioned without version", str: `{"devices":[]}`, wantErr: true},
+		{name: "versioned broken json", str: `{"version":2,"devices":[`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeNodeDevices(tt.str)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeNodeDevices(%q) error %v, want error %v", tt.str, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodeNodeDevices(%q) = %+v, want %+v", tt.str, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodePodDevices(t *testing.T) {
+	tests := []struct {
+		name    string
+		str     string
+		want    [][]ContainerDevice
+		wantErr bool
+	}{
+		{name: "empty", str: " ", want: [][]ContainerDevice{}},
+		{name: "legacy", str: "0,GPU-a,A100,1024,30,0:;1,GPU-b,A100,2048,50,1:",
+			want: [][]ContainerDevice{
+				{{Index: 0, UUID: "GPU-a", Type: "A100", UsedMemory: 1024, UsedCores: 30, Vid: 0}},
+				{{Index: 1, UUID: "GPU-b", Type: "A100", UsedMemory: 2048, UsedCores: 50, Vid: 1}},
+			}},
+		{name: "legacy containers joined by comma", str: "0,GPU-a,A100,1024,30,0:,1,GPU-b,A100,2048,50,1:",
+			want: [][]ContainerDevice{
+				{{Index: 0, UUID: "GPU-a", Type: "A100", UsedMemory: 1024, UsedCores: 30, Vid: 0}},
+				{{Index: 1, UUID: "GPU-b", Type: "A100", UsedMemory: 2048, UsedCores: 50, Vid: 1}},
+			}},
+		{name: "versioned", str: `{"version":2,"containers":[[{"index":0,"uuid":"GPU-a","type":"A100",` +
+			`"usedMemory":1024,"usedCores":0,"vid":3,"qos":"best-effort"}],[]]}`,
+			want: [][]ContainerDevice{
+				{{Index: 0, UUID: "GPU-a", Type: "A100", UsedMemory: 1024, UsedCores: 0, Vid: 3, Qos: "best-effort"}},
+				{},
+			}},
+		{name: "legacy wrong field number", str: "0,GPU-a,A100,1024,30:", wantErr: true},
+		{name: "legacy bad number", str: "0,GPU-a,A100,1024,thirty,0:", wantErr: true},
+		{name: "versioned without version", str: `{"containers":[]}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodePodDevices(tt.str)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodePodDevices(%q) error %v, want error %v", tt.str, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DecodePodDevices(%q) = %+v, want %+v", tt.str, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestEncodeDecodeRoundTrip the encoded annotations decode to the same devices
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	nodeDevices := []NodeDevice{{Index: 0, Id: "GPU-a", Count: 10, Memory: 40960, Type: "A100", Health: true,
+		MemoryRatio: 2}}
+	str, err := EncodeNodeDevices(nodeDevices)
+	if err != nil {
+		t.Fatalf("EncodeNodeDevices: %v", err)
+	}
+	if got, err := DecodeNodeDevices(str); err != nil || !reflect.DeepEqual(got, nodeDevices) {
+		t.Errorf("node devices %+v, %v after round trip, want %+v", got, err, nodeDevices)
+	}
+	containers := [][]ContainerDevice{{{Index: 1, UUID: "GPU-b", Type: "A100", UsedMemory: 1024, UsedCores: 20,
+		Vid: 70, Qos: "burstable"}}, nil}
+	if str, err = EncodePodDevices(containers); err != nil {
+		t.Fatalf("EncodePodDevices: %v", err)
+	}
+	want := [][]ContainerDevice{containers[0], {}}
+	if got, err := DecodePodDevices(str); err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("pod devices %+v, %v after round trip, want %+v", got, err, want)
+	}
+}
+
+// TestSchedulerCopyInSync the scheduler plugin keeps a copy of the package, it must not drift from this one
+func TestSchedulerCopyInSync(t *testing.T) {
+	if _, err := os.Stat(schedulerCodecDir); err != nil {
+		t.Skipf("scheduler copy of devicecodec not found: %v", err)
+	}
+	sources := func(dir string) map[string][]byte {
+		files, err := filepath.Glob(filepath.Join(dir, "*.go"))
+		if err != nil {
+			t.Fatalf("list %s: %v", dir, err)
+		}
+		contents := make(map[string][]byte, len(files))
+		for _, file := range files {
+			if strings.HasSuffix(file, "_test.go") {
+				continue
+			}
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("read %s: %v", file, err)
+			}
+			contents[filepath.Base(file)] = data
+		}
+		return contents
+	}
+	local, scheduler := sources("."), sources(schedulerCodecDir)
+	for name, data := range local {
+		other, ok := scheduler[name]
+		if !ok {
+			t.Errorf("%s is missing in %s", name, schedulerCodecDir)
+			continue
+		}
+		if !bytes.Equal(data, other) {
+			t.Errorf("%s differs from the copy in %s", name, schedulerCodecDir)
+		}
+	}
+	for name := range scheduler {
+		if _, ok := local[name]; !ok {
+			t.Errorf("%s of %s is missing here", name, schedulerCodecDir)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package devicecodec
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	// XPUNodeGroup api group of the XPUNode custom resource
+	XPUNodeGroup = "xpu.huawei.com"
+	// XPUNodeVersion api version of the XPUNode custom resource
+	XPUNodeVersion = "v1"
+	// XPUNodeResource plural name of the XPUNode custom resource
+	XPUNodeResource = "xpunodes"
+	// XPUNodeKind kind of the XPUNode custom resource
+	XPUNodeKind = "XPUNode"
+	// XPUNodesPath api path listing the XPUNode objects, the resource is cluster scoped
+	XPUNodesPath = "/apis/" + XPUNodeGroup + "/" + XPUNodeVersion + "/" + XPUNodeResource
+)
+
+// XPUNodeSpec node and device type the XPUNode object describes
+type XPUNodeSpec struct {
+	NodeName   string `json:"nodeName"`
+	DeviceType string `json:"deviceType"`
+}
+
+// XPUNodeStatus device inventory reported by the device plugin through the status subresource
+type XPUNodeStatus struct {
+	Devices []NodeDevice `json:"devices,omitempty"`
+	// Topology bandwidth matrix between the devices, indexed by the device index
+	Topology      [][]int `json:"topology,omitempty"`
+	DriverVersion string  `json:"driverVersion,omitempty"`
+	CudaVersion   int     `json:"cudaVersion,omitempty"`
+	// HeartbeatTime last time the device plugin reported the status
+	HeartbeatTime time.Time `json:"heartbeatTime"`
+}
+
+// XPUNode the XPUNode custom resource, only the fields used by the components are decoded
+type XPUNode struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Spec   XPUNodeSpec   `json:"spec"`
+	Status XPUNodeStatus `json:"status"`
+}
+
+// XPUNodeList list of the XPUNode custom resources
+type XPUNodeList struct {
+	Items []XPUNode `json:"items"`
+}
+
+// XPUNodeName name of the XPUNode object of a node and device type
+func XPUNodeName(nodeName string, deviceType string) string {
+	return nodeName + "-" + strings.ToLower(deviceType)
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+package devicecodec
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// xpuNodeCRDs the copies of the XPUNode custom resource definition installed with the device plugin
+var xpuNodeCRDs = []string{
+	filepath.Join("..", "..", "..", "install", "yaml", "xpunode-crd.yaml"),
+	filepath.Join("..", "..", "..", "install", "helm", "gpupool", "crds", "xpu.huawei.com_xpunodes.yaml"),
+}
+
+// loadXPUNodeSchema read the openAPIV3Schema of the v1 version of the XPUNode custom resource definition
+func loadXPUNodeSchema(t *testing.T, path string) map[string]interface{} {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	var crd interface{}
+	if err := yaml.Unmarshal(data, &crd); err != nil {
+		t.Fatalf("parse %s: %v", path, err)
+	}
+	crd = toStringKeys(crd)
+	versions, _ := lookup(crd, "spec", "versions").([]interface{})
+	for _, version := range versions {
+		if lookup(version, "name") != XPUNodeVersion {
+			continue
+		}
+		if schema, ok := lookup(version, "schema", "openAPIV3Schema").(map[string]interface{}); ok {
+			return schema
+		}
+	}
+	t.Fatalf("%s has no schema of version %s", path, XPUNodeVersion)
+	return nil
+}
+
+// toStringKeys convert the maps decoded by yaml.v2 to the maps decoded by encoding/json
+func toStringKeys(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(value))
+		for k, item := range value {
+			m[k.(string)] = toStringKeys(item)
+		}
+		return m
+	case []interface{}:
+		for i := range value {
+			value[i] = toStringKeys(value[i])
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+func lookup(v interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// prune drop the fields the structural schema does not know, as the api server does on write
+func prune(v interface{}, schema map[string]interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		properties, ok := schema["properties"].(map[string]interface{})
+		if !ok {
+			return value
+		}
+		for k, item := range value {
+			property, ok := properties[k].(map[string]interface{})
+			if !ok {
+				delete(value, k)
+				continue
+			}
+			value[k] = prune(item, property)
+		}
+		return value
+	case []interface{}:
+		items, ok := schema["items"].(map[string]interface{})
+		if !ok {
+			return value
+		}
+		for i := range value {
+			value[i] = prune(value[i], items)
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+// TestXPUNodeCRDRoundTrip the XPUNode status written by the device plugin reads back the same
+// once stored through the custom resource definition
+func TestXPUNodeCRDRoundTrip(t *testing.T) {
+	node := XPUNode{
+		Spec: XPUNodeSpec{NodeName: "node-1", DeviceType: "NVIDIA"},
+		Status: XPUNodeStatus{
+			Devices: []NodeDevice{
+				{Index: 0, Id: "GPU-a", Count: 10, Memory: 40960, Type: "A100", Health: true, Numa: 0,
+					MemoryRatio: 1.5},
+				{Index: 1, Id: "GPU-b", Count: 10, Memory: 40960, Type: "A100", Health: false, Numa: 1},
+			},
+			Topology:      [][]int{{0, 300}, {300, 0}},
+			DriverVersion: "535.104.05",
+			CudaVersion:   12020,
+			HeartbeatTime: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		},
+	}
+	node.Metadata.Name = XPUNodeName(node.Spec.NodeName, node.Spec.DeviceType)
+	for _, path := range xpuNodeCRDs {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			schema := loadXPUNodeSchema(t, path)
+			data, err := json.Marshal(node)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			var object interface{}
+			if err := json.Unmarshal(data, &object); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			// metadata is not part of the schema, the api server keeps it
+			metadata := lookup(object, "metadata")
+			object = prune(object, schema)
+			object.(map[string]interface{})["metadata"] = metadata
+			if data, err = json.Marshal(object); err != nil {
+				t.Fatalf("marshal pruned: %v", err)
+			}
+			var got XPUNode
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("unmarshal pruned: %v", err)
+			}
+			want, _ := json.Marshal(node)
+			if gotData, _ := json.Marshal(got); string(gotData) != string(want) {
+				t.Errorf("XPUNode changed through the CRD:\n got %s\nwant %s", gotData, want)
+			}
+		})
+	}
+}
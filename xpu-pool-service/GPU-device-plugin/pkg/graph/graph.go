@@ -54,4 +54,25 @@
 		 result = append(result, strings.Join(tokens, ","))
 	 }
 	 return strings.Join(result, ";")
- }
\ No newline at end of file
+ }
+ // Deserialize parses the string made by Serializer back into a graph.
+ func Deserialize(s string) (TopologyGraph, error) {
+	 if s == "" {
+		 return TopologyGraph{}, nil
+	 }
+	 rows := strings.Split(s, ";")
+	 graph := make(TopologyGraph, 0, len(rows))
+	 for _, row := range rows {
+		 tokens := strings.Split(row, ",")
+		 values := make([]int, 0, len(tokens))
+		 for _, token := range tokens {
+			 v, err := strconv.Atoi(token)
+			 if err != nil {
+				 return nil, err
+			 }
+			 values = append(values, v)
+		 }
+		 graph = append(graph, values)
+	 }
+	 return graph, nil
+ }
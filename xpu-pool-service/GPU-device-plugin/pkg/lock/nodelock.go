@@ -16,6 +16,7 @@ import (
 
 	"huawei.com/vxpu-device-plugin/pkg/log"
 	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -37,13 +38,21 @@ func getUptime() (string, error) {
 	return eles[0], nil
 }
 
-var kubeClient kubernetes.Interface
+var (
+	kubeClient    kubernetes.Interface
+	dynamicClient dynamic.Interface
+)
 
 // GetClient return a k8s client connection to apiserver
 func GetClient() kubernetes.Interface {
 	return kubeClient
 }
 
+// GetDynamicClient return a k8s dynamic client connection to apiserver, used for the custom resources
+func GetDynamicClient() dynamic.Interface {
+	return dynamicClient
+}
+
 // NewClient create a k8s client connection to apiserver
 func NewClient() error {
 	kubeConfig := os.Getenv("KUBECONFIG")
@@ -58,7 +67,11 @@ func NewClient() error {
 		}
 	}
 	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return err
+	}
 	kubeClient = client
+	dynamicClient, err = dynamic.NewForConfig(config)
 	return err
 }
 
@@ -16,4 +16,15 @@ var (
 	GPUTypeConfig string
 	// GPUTypeMap mapping between gpu types and abbreviations
 	GPUTypeMap map[string]string
+	// MemoryOversubscriptionRatio memory oversubscription ratio of the devices of the node, 1 for none
+	MemoryOversubscriptionRatio float64
+	// MemoryOversubscriptionConfig The absolute path of the memory oversubscription ratio config file
+	MemoryOversubscriptionConfig string
+	// MemoryOversubscriptionMap mapping between registered device types and memory oversubscription ratios,
+	// overriding MemoryOversubscriptionRatio
+	MemoryOversubscriptionMap map[string]float64
+	// RegisterAnnotations also register the devices in the node annotations besides the XPUNode object,
+	// for the schedulers not reading XPUNode. It is on by default so that the older schedulers keep seeing
+	// the handshake during a rolling upgrade, it may be turned off once all the schedulers read XPUNode
+	RegisterAnnotations bool
 )
@@ -10,6 +10,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net"
 	"os"
 	"path"
@@ -229,9 +230,16 @@ const (
 	configFilePerm   = 0644
 	pidsSockDir      = "/var/lib/xpu"
 	xpuPath          = "/opt/xpu"
+	percentage       = 100
+	qosGuaranteed    = "guaranteed"
+	qosBestEffort    = "best-effort"
 )
 
-func writeVxpuConfig(dir string, usedMem, usedCores int32) error {
+// writeVxpuConfig write the limits of the container, usedMem is in MiB whichever unit the pod requested.
+// memRatio is the memory oversubscription ratio of the devices, written in percent, and qos the qos class
+// the core limiter applies usedCores with. The config is written aside and renamed over the old one, so
+// the running container resized in place never reads it half written
+func writeVxpuConfig(dir string, usedMem, usedCores int32, memRatio float64, qos string) error {
 	err := os.MkdirAll(dir, containerDirPerm)
 	if err != nil {
 		log.Errorf("mkdir vxpu config dir error: %v", err)
@@ -239,7 +247,8 @@ func writeVxpuConfig(dir string, usedMem, usedCores int32) error {
 	}
 
 	vxpuConfigFilePath := filepath.Clean(filepath.Join(dir, xpu.VxpuConfigFileName))
-	vxpuConfig, err := os.OpenFile(vxpuConfigFilePath, os.O_WRONLY|os.O_CREATE, configFilePerm)
+	tmpFilePath := vxpuConfigFilePath + ".tmp"
+	vxpuConfig, err := os.OpenFile(tmpFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, configFilePerm)
 	if err != nil {
 		log.Errorf("create vxpu config file error: %v", err)
 		return err
@@ -247,12 +256,17 @@ func writeVxpuConfig(dir string, usedMem, usedCores int32) error {
 	defer vxpuConfig.Close()
 
 	w := bufio.NewWriter(vxpuConfig)
-	_, err = w.WriteString(fmt.Sprint("UsedMem:", usedMem, "\nUsedCores:", usedCores, "\n"))
+	_, err = w.WriteString(fmt.Sprint("UsedMem:", usedMem, "\nUsedCores:", usedCores,
+		"\nMemRatio:", int(math.Round(memRatio*percentage)), "\nQosClass:", qos, "\n"))
 	if err != nil {
 		log.Errorf("bufio Writer WriteString error: %v", err)
 		return err
 	}
-	return w.Flush()
+	if err = w.Flush(); err != nil {
+		log.Errorf("bufio Writer Flush error: %v", err)
+		return err
+	}
+	return os.Rename(tmpFilePath, vxpuConfigFilePath)
 }
 
 // WriteVxpuIdsConfig write vxpu ids assigned to the container to vxpu-ids.config
@@ -276,9 +290,30 @@ func WriteVxpuIdsConfig(dir string, contDevs types.ContainerDevices) error {
 	return w.Flush()
 }
 
+// containerMemoryRatio the largest memory oversubscription ratio of the devices of the container
+func containerMemoryRatio(contDevs types.ContainerDevices) float64 {
+	ratio := 1.0
+	for _, dev := range contDevs {
+		ratio = math.Max(ratio, xpu.DeviceMemoryOversubscriptionRatio(dev.UUID))
+	}
+	return ratio
+}
+
+// containerQos the qos class of the container, derived from its cores when the scheduler did not record it
+func containerQos(contDevs types.ContainerDevices) string {
+	if contDevs[0].Qos != "" {
+		return contDevs[0].Qos
+	}
+	if contDevs[0].Usedcores == 0 {
+		return qosBestEffort
+	}
+	return qosGuaranteed
+}
+
 func createDirAndWriteFile(podId, containerName string, contDevs types.ContainerDevices) error {
 	vxpuConfigDirInHost := filepath.Clean(filepath.Join(configBaseDir, podId, containerName))
-	err := writeVxpuConfig(vxpuConfigDirInHost, contDevs[0].Usedmem, contDevs[0].Usedcores)
+	err := writeVxpuConfig(vxpuConfigDirInHost, contDevs[0].Usedmem, contDevs[0].Usedcores,
+		containerMemoryRatio(contDevs), containerQos(contDevs))
 	if err != nil {
 		log.Errorf("write vxpu config error: %v, podId: %s, containerName: %s", err, podId, containerName)
 		return err
@@ -13,6 +13,7 @@ import (
 
 	"gopkg.in/yaml.v2"
 
+	"huawei.com/vxpu-device-plugin/pkg/devicecodec"
 	"huawei.com/vxpu-device-plugin/pkg/graph"
 	"huawei.com/vxpu-device-plugin/pkg/log"
 	"huawei.com/vxpu-device-plugin/pkg/plugin/config"
@@ -26,7 +27,7 @@ const (
 	registerInterval  = 30
 )
 
-// DeviceRegister register and patch vxpu information to the node annotation
+// DeviceRegister register vxpu information to the XPUNode object, and to the node annotation in compatibility mode
 type DeviceRegister struct {
 	deviceCache      *DeviceCache
 	topologyProvider graph.TopologyProvider
@@ -50,13 +51,49 @@ func (r *DeviceRegister) apiDevices() []*types.DeviceInfo {
 	return xpu.GetDeviceInfo(devs)
 }
 
-func (r *DeviceRegister) registerInAnnotation() error {
+// register report the devices in the XPUNode object, the failure of it is tolerated when the
+// devices are registered in the node annotations as well
+func (r *DeviceRegister) register() error {
 	devices := r.apiDevices()
+	topology := r.topologyProvider.Topology()
+	err := r.registerInXPUNode(devices, topology)
+	if !config.RegisterAnnotations {
+		return err
+	}
+	if err != nil {
+		log.Warningf("register XPUNode failed: %v, devices are registered in node annotations only", err)
+	}
+	return r.registerInAnnotation(devices, topology)
+}
+
+func (r *DeviceRegister) registerInXPUNode(devices []*types.DeviceInfo, topology string) error {
+	status := devicecodec.XPUNodeStatus{HeartbeatTime: time.Now()}
+	for _, val := range devices {
+		status.Devices = append(status.Devices, devicecodec.NodeDevice{Index: int(val.Index), Id: val.Id,
+			Count: int(val.Count), Memory: uint64(val.Devmem), Type: val.Type, Health: val.Health, Numa: int(val.Numa),
+			MemoryRatio: val.MemoryRatio})
+	}
+	topologyGraph, err := graph.Deserialize(topology)
+	if err != nil {
+		log.Warningf("parse topology %s failed: %v", topology, err)
+	} else {
+		status.Topology = topologyGraph
+	}
+	driverVersion, cudaVersion, err := xpu.GetVersionInfo()
+	if err != nil {
+		log.Warningf("get version info failed: %v", err)
+	}
+	status.DriverVersion = driverVersion
+	status.CudaVersion = cudaVersion
+	return util.UpdateXPUNodeStatus(config.NodeName, xpu.DeviceType, status)
+}
+
+func (r *DeviceRegister) registerInAnnotation(devices []*types.DeviceInfo, topology string) error {
 	encodedDevices := util.EncodeNodeDevices(devices)
 	annotations := map[string]string{
 		xpu.NodeVXPURegister:  encodedDevices,
 		xpu.NodeVXPUHandshake: "Reported_" + time.Now().Format("2006.01.02 15:04:05"),
-		xpu.NodeXpuTopology:   r.topologyProvider.Topology(),
+		xpu.NodeXpuTopology:   topology,
 	}
 
 	log.Infoln("Reporting devices", encodedDevices, "in", time.Now().Format("2006.01.02 15:04:05"))
@@ -103,9 +140,12 @@ func (r *DeviceRegister) watchAndRegister() {
 	if len(config.GPUTypeConfig) != 0 {
 		loadGPUTypeConf()
 	}
+	if len(config.MemoryOversubscriptionConfig) != 0 {
+		loadMemoryOversubscriptionConf()
+	}
 	lastSucceed := true
 	for {
-		err := r.registerInAnnotation()
+		err := r.register()
 		if err != nil {
 			if lastSucceed == false {
 				break
@@ -139,3 +179,23 @@ func unmarshalGPUTypeConf(confStr string) {
 	}
 	log.Infof("unmarshal gpu type succeed, content: %v", config.GPUTypeMap)
 }
+
+func loadMemoryOversubscriptionConf() {
+	confData, err := os.ReadFile(config.MemoryOversubscriptionConfig)
+	if err != nil {
+		log.Errorf("Failed to read memory oversubscription config in '%s', err: %v",
+			config.MemoryOversubscriptionConfig, err)
+		return
+	}
+	conf := strings.TrimSpace(string(confData))
+	unmarshalMemoryOversubscriptionConf(conf)
+}
+
+func unmarshalMemoryOversubscriptionConf(confStr string) {
+	config.MemoryOversubscriptionMap = make(map[string]float64)
+	if err := yaml.Unmarshal([]byte(confStr), &config.MemoryOversubscriptionMap); err != nil {
+		log.Errorf("Failed to unmarshal memory oversubscription yaml, err: %v", err)
+		return
+	}
+	log.Infof("unmarshal memory oversubscription succeed, content: %v", config.MemoryOversubscriptionMap)
+}
@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2024. All rights reserved.
+ */
+
+// Package plugin implements vxpu device plugin
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+
+	"huawei.com/vxpu-device-plugin/pkg/log"
+	"huawei.com/vxpu-device-plugin/pkg/plugin/types"
+	"huawei.com/vxpu-device-plugin/pkg/plugin/util"
+	"huawei.com/vxpu-device-plugin/pkg/plugin/xpu"
+)
+
+const (
+	resizeInterval = 5
+)
+
+// ContainerResizer apply the in-place resizes accepted by the scheduler to the vxpu config of the containers
+type ContainerResizer struct{}
+
+// NewContainerResizer new a container resizer instance
+func NewContainerResizer() *ContainerResizer {
+	return &ContainerResizer{}
+}
+
+// Start apply the accepted resizes periodically
+func (r *ContainerResizer) Start() {
+	go r.watchAndResize()
+}
+
+func (r *ContainerResizer) watchAndResize() {
+	log.Infof("into watchAndResize")
+	for {
+		r.resize()
+		time.Sleep(time.Second * resizeInterval)
+	}
+}
+
+// resize rewrite the vxpu config of the running pods whose resize was accepted, the status is reported on the pod
+func (r *ContainerResizer) resize() {
+	podList, err := util.ListRunningPods()
+	if err != nil {
+		log.Errorf("get pods in current node error: %v", err)
+		return
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Annotations[types.VXPUResizeStatus] != types.VXPUResizeAccepted {
+			continue
+		}
+		annos := map[string]string{types.VXPUResizeStatus: types.VXPUResizeApplied, types.VXPUResizeMessage: ""}
+		if err := resizePod(pod); err != nil {
+			log.Errorf("resize pod %s/%s failed: %v", pod.Namespace, pod.Name, err)
+			annos[types.VXPUResizeStatus] = types.VXPUResizeFailed
+			annos[types.VXPUResizeMessage] = err.Error()
+		} else {
+			log.Infof("resize pod %s/%s applied", pod.Namespace, pod.Name)
+		}
+		if err := util.PatchPodAnnotations(pod, annos); err != nil {
+			log.Errorf("patch resize status of pod %s/%s failed: %v", pod.Namespace, pod.Name, err)
+		}
+	}
+}
+
+// resizePod rewrite the vxpu config of the containers of the pod with the limits of their assigned devices,
+// the direct library of the containers reloads it
+func resizePod(pod *v1.Pod) error {
+	for containerName, contDevs := range util.GetContainerDevices(pod) {
+		if contDevs[0].Type != xpu.DeviceType {
+			continue
+		}
+		if contDevs[0].Usedmem == 0 {
+			return fmt.Errorf("container %s resized to 0 memory", containerName)
+		}
+		dir := filepath.Clean(filepath.Join(configBaseDir, string(pod.UID), containerName))
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("vxpu config dir of container %s: %v", containerName, err)
+		}
+		err := writeVxpuConfig(dir, contDevs[0].Usedmem, contDevs[0].Usedcores,
+			containerMemoryRatio(contDevs), containerQos(contDevs))
+		if err != nil {
+			return fmt.Errorf("write vxpu config of container %s: %v", containerName, err)
+		}
+	}
+	return nil
+}
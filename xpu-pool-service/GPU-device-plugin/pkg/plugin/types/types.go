@@ -18,6 +18,18 @@ const (
 	// DeviceBindSuccess bind phase success
 	DeviceBindSuccess = "success"
 
+	// VXPUResizeStatus status of the last in-place resize of the vxpu limits of the pod
+	VXPUResizeStatus = "huawei.com/vxpu-resize-status"
+	// VXPUResizeMessage why the last in-place resize was rejected or failed
+	VXPUResizeMessage = "huawei.com/vxpu-resize-message"
+
+	// VXPUResizeAccepted resize accepted by the scheduler, to be applied by the device plugin
+	VXPUResizeAccepted = "accepted"
+	// VXPUResizeApplied resize applied to the vxpu config of the containers
+	VXPUResizeApplied = "applied"
+	// VXPUResizeFailed resize failed to be applied to the vxpu config of the containers
+	VXPUResizeFailed = "failed"
+
 	// VXPULockName lockname used to lock a node
 	VXPULockName = "vxpu"
 )
@@ -30,6 +42,7 @@ type ContainerDevice struct {
 	Usedmem   int32
 	Usedcores int32
 	Vid       int32
+	Qos       string
 }
 
 // ContainerDevices description of all vxpus in the container
@@ -40,33 +53,35 @@ type PodDevices []ContainerDevices
 
 // DeviceInfo description of xpu registered in the node annotation
 type DeviceInfo struct {
-	Index  int32  `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
-	Id     string `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
-	Count  int32  `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
-	Devmem int32  `protobuf:"varint,4,opt,name=devmem,proto3" json:"devmem,omitempty"`
-	Type   string `protobuf:"bytes,5,opt,name=type,proto3" json:"type,omitempty"`
-	Health bool   `protobuf:"varint,6,opt,name=health,proto3" json:"health,omitempty"`
-	Numa   int32  `protobuf:"varint,7,opt,name=numa,proto3" json:"numa,omitempty"`
+	Index       int32   `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Id          string  `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Count       int32   `protobuf:"varint,3,opt,name=count,proto3" json:"count,omitempty"`
+	Devmem      int32   `protobuf:"varint,4,opt,name=devmem,proto3" json:"devmem,omitempty"`
+	Type        string  `protobuf:"bytes,5,opt,name=type,proto3" json:"type,omitempty"`
+	Health      bool    `protobuf:"varint,6,opt,name=health,proto3" json:"health,omitempty"`
+	Numa        int32   `protobuf:"varint,7,opt,name=numa,proto3" json:"numa,omitempty"`
+	MemoryRatio float64 `protobuf:"fixed64,8,opt,name=memoryRatio,proto3" json:"memoryRatio,omitempty"`
 }
 
 // XPUDevice description of xpu
 type XPUDevice struct {
-	Index             int32
-	Id                string
-	Type              string
-	Health            bool
-	Count             uint32
-	MemoryTotal       uint64
-	MemoryUsed        uint64
-	MemoryUtilization float64
-	XpuUtilization    float64
-	NodeName          string
-	NodeIp            string
-	DriverVersion     string
-	FrameworkVersion  int
-	PowerUsage        uint32
-	Temperature       uint32
-	VxpuDeviceList    VxpuDevices
+	Index                int32
+	Id                   string
+	Type                 string
+	Health               bool
+	Count                uint32
+	MemoryTotal          uint64
+	MemoryOversubscribed uint64
+	MemoryUsed           uint64
+	MemoryUtilization    float64
+	XpuUtilization       float64
+	NodeName             string
+	NodeIp               string
+	DriverVersion        string
+	FrameworkVersion     int
+	PowerUsage           uint32
+	Temperature          uint32
+	VxpuDeviceList       VxpuDevices
 }
 
 // VxpuDevices description of all vxpus in the pod
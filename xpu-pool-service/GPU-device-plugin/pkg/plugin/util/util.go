@@ -11,7 +11,6 @@ import (
 	"errors"
 	"fmt"
 	"math"
-	"reflect"
 	"strconv"
 	"strings"
 
@@ -20,6 +19,7 @@ import (
 	"k8s.io/apimachinery/pkg/fields"
 	k8stypes "k8s.io/apimachinery/pkg/types"
 
+	"huawei.com/vxpu-device-plugin/pkg/devicecodec"
 	"huawei.com/vxpu-device-plugin/pkg/lock"
 	"huawei.com/vxpu-device-plugin/pkg/log"
 	"huawei.com/vxpu-device-plugin/pkg/plugin/config"
@@ -28,7 +28,6 @@ import (
 )
 
 const (
-	deviceLength = 7
 	// PodAnnotationMaxLength pod annotation max data length 2MB
 	PodAnnotationMaxLength = 1024 * 1024
 	// BaseDec base size
@@ -102,57 +101,47 @@ func getBindTime(pod v1.Pod) (uint64, bool) {
 	return bindTime, true
 }
 
-// EncodeNodeDevices encode a node's xpus info to string
+// EncodeNodeDevices encode a node's xpus info to the versioned annotation
 func EncodeNodeDevices(dlist []*types.DeviceInfo) string {
-	var encodedNodeDevices strings.Builder
+	devices := make([]devicecodec.NodeDevice, 0, len(dlist))
 	for _, val := range dlist {
-		encodedNodeDevices.Write([]byte(strconv.Itoa(int(val.Index))))
-		encodedNodeDevices.Write([]byte(","))
-		encodedNodeDevices.Write([]byte(val.Id))
-		encodedNodeDevices.Write([]byte(","))
-		encodedNodeDevices.Write([]byte(strconv.Itoa(int(val.Count))))
-		encodedNodeDevices.Write([]byte(","))
-		encodedNodeDevices.Write([]byte(strconv.Itoa(int(val.Devmem))))
-		encodedNodeDevices.Write([]byte(","))
-		encodedNodeDevices.Write([]byte(val.Type))
-		encodedNodeDevices.Write([]byte(","))
-		encodedNodeDevices.Write([]byte(strconv.FormatBool(val.Health)))
-		encodedNodeDevices.Write([]byte(","))
-		encodedNodeDevices.Write([]byte(strconv.Itoa(int(val.Numa))))
-		encodedNodeDevices.Write([]byte(":"))
-	}
-	log.Infoln("Encoded node Devices:", encodedNodeDevices.String())
-	return encodedNodeDevices.String()
+		devices = append(devices, devicecodec.NodeDevice{Index: int(val.Index), Id: val.Id, Count: int(val.Count),
+			Memory: uint64(val.Devmem), Type: val.Type, Health: val.Health, Numa: int(val.Numa),
+			MemoryRatio: val.MemoryRatio})
+	}
+	encodedNodeDevices, err := devicecodec.EncodeNodeDevices(devices)
+	if err != nil {
+		log.Errorf("encode node devices failed: %v", err)
+		return ""
+	}
+	log.Infoln("Encoded node Devices:", encodedNodeDevices)
+	return encodedNodeDevices
 }
 
 // EncodeContainerDevices encode vxpu resource request of a container to string
 func EncodeContainerDevices(cd types.ContainerDevices) string {
-	var encodedContainerDevices strings.Builder
-	for _, val := range cd {
-		encodedContainerDevices.Write([]byte(strconv.Itoa(int(val.Index))))
-		encodedContainerDevices.Write([]byte(","))
-		encodedContainerDevices.Write([]byte(val.UUID))
-		encodedContainerDevices.Write([]byte(","))
-		encodedContainerDevices.Write([]byte(val.Type))
-		encodedContainerDevices.Write([]byte(","))
-		encodedContainerDevices.Write([]byte(strconv.Itoa(int(val.Usedmem))))
-		encodedContainerDevices.Write([]byte(","))
-		encodedContainerDevices.Write([]byte(strconv.Itoa(int(val.Usedcores))))
-		encodedContainerDevices.Write([]byte(","))
-		encodedContainerDevices.Write([]byte(strconv.Itoa(int(val.Vid))))
-		encodedContainerDevices.Write([]byte(":"))
-	}
-	log.Infoln("Encoded container Devices:", encodedContainerDevices.String())
-	return encodedContainerDevices.String()
+	return EncodePodDevices(types.PodDevices{cd})
 }
 
-// EncodePodDevices encode vxpu resource request of a pod to string
+// EncodePodDevices encode vxpu resource request of a pod to the versioned annotation
 func EncodePodDevices(pd types.PodDevices) string {
-	var ss []string
+	containers := make([][]devicecodec.ContainerDevice, 0, len(pd))
 	for _, cd := range pd {
-		ss = append(ss, EncodeContainerDevices(cd))
+		devices := make([]devicecodec.ContainerDevice, 0, len(cd))
+		for _, val := range cd {
+			devices = append(devices, devicecodec.ContainerDevice{Index: int(val.Index), UUID: val.UUID,
+				Type: val.Type, UsedMemory: uint64(val.Usedmem), UsedCores: int(val.Usedcores), Vid: int(val.Vid),
+				Qos: val.Qos})
+		}
+		containers = append(containers, devices)
 	}
-	return strings.Join(ss, ",")
+	encodedPodDevices, err := devicecodec.EncodePodDevices(containers)
+	if err != nil {
+		log.Errorf("encode pod devices failed: %v", err)
+		return ""
+	}
+	log.Infoln("Encoded pod Devices:", encodedPodDevices)
+	return encodedPodDevices
 }
 
 // GetXPUDevice get XPUDevice info
@@ -162,123 +151,108 @@ func GetXPUDevice(str string, ip string) map[string]*types.XPUDevice {
 	if err != nil {
 		log.Infof("GetVersionInfo error %v", err)
 	}
+	setXPUDevicesNodeInfo(deviceMap, ip, driverVersion, FrameworkVersion)
+	return deviceMap
+}
+
+func setXPUDevicesNodeInfo(deviceMap map[string]*types.XPUDevice, ip string, driverVersion string,
+	frameworkVersion int) {
 	for _, device := range deviceMap {
 		device.NodeIp = ip
 		device.NodeName = config.NodeName
 		device.DriverVersion = driverVersion
-		device.FrameworkVersion = FrameworkVersion
+		device.FrameworkVersion = frameworkVersion
 	}
-	return deviceMap
 }
 
-// DecodeNodeDevices decode the node device from string
+// DecodeNodeDevices decode the node device from string, the legacy format is accepted as well
 func DecodeNodeDevices(str string) map[string]*types.XPUDevice {
 	deviceMap := make(map[string]*types.XPUDevice)
-	if !strings.Contains(str, ":") {
-		log.Errorf("decode node device failed, wrong annos: %s", str)
+	devices, err := devicecodec.DecodeNodeDevices(str)
+	if err != nil {
+		log.Errorf("decode node device failed: %v", err)
 		return deviceMap
 	}
-	tmp := strings.Split(str, ":")
-	for _, val := range tmp {
-		if !strings.Contains(val, ",") {
-			continue
-		}
-		items := strings.Split(val, ",")
-		if len(items) != deviceLength {
-			log.Warningf("device string is wrong, device: %s", items)
-			continue
-		}
-		index, err := strconv.Atoi(items[0])
-		if err != nil {
-			continue
-		}
-		count, err := strconv.Atoi(items[2])
-		if err != nil {
-			continue
-		}
-		devmem, err := strconv.Atoi(items[3])
-		if err != nil {
-			continue
-		}
-		health, err := strconv.ParseBool(items[5])
-		if err != nil {
-			continue
-		}
-		i := types.XPUDevice{
-			Index:          int32(index),
-			Id:             items[1],
-			Type:           items[4],
-			Count:          uint32(count),
-			MemoryTotal:    uint64(devmem),
-			Health:         health,
-			VxpuDeviceList: types.VxpuDevices{},
+	return newXPUDeviceMap(devices)
+}
+
+// newXPUDeviceMap build the xpu devices keyed by uuid from the registered devices
+func newXPUDeviceMap(devices []devicecodec.NodeDevice) map[string]*types.XPUDevice {
+	deviceMap := make(map[string]*types.XPUDevice, len(devices))
+	for _, val := range devices {
+		deviceMap[val.Id] = &types.XPUDevice{
+			Index:                int32(val.Index),
+			Id:                   val.Id,
+			Type:                 val.Type,
+			Count:                uint32(val.Count),
+			MemoryTotal:          val.Memory,
+			MemoryOversubscribed: val.MemoryCapacity(),
+			Health:               val.Health,
+			VxpuDeviceList:       types.VxpuDevices{},
 		}
-		deviceMap[items[1]] = &i
 	}
 	return deviceMap
 }
 
 // DecodeContainerDevices decode xpu resource request of a container from string
 func DecodeContainerDevices(str string) types.ContainerDevices {
-	if len(str) == 0 {
+	pd := DecodePodDevices(str)
+	if len(pd) == 0 {
 		return types.ContainerDevices{}
 	}
-	cd := strings.Split(str, ":")
-	contdev := types.ContainerDevices{}
-	for _, val := range cd {
-		if strings.Contains(val, ",") == false {
-			continue
-		}
-		fields := strings.Split(val, ",")
-		tmpdev := types.ContainerDevice{}
-		if len(fields) != reflect.TypeOf(tmpdev).NumField() {
-			log.Fatalln("DecodeContainerDevices invalid parameter:", str)
-			return types.ContainerDevices{}
-		}
-		index, err := strconv.Atoi(fields[0])
-		if err != nil {
-			log.Fatalln("DecodeContainerDevices invalid parameter:", str)
-			return types.ContainerDevices{}
-		}
-		tmpdev.Index = int32(index)
-		tmpdev.UUID = fields[1]
-		tmpdev.Type = fields[2]
-		devmem, err := strconv.Atoi(fields[3])
-		if err != nil {
-			log.Fatalln("DecodeContainerDevices invalid parameter:", str)
-			return types.ContainerDevices{}
-		}
-		tmpdev.Usedmem = int32(devmem)
-		devcores, err := strconv.Atoi(fields[4])
-		if err != nil {
-			log.Fatalln("DecodeContainerDevices invalid parameter:", str)
-			return types.ContainerDevices{}
-		}
-		tmpdev.Usedcores = int32(devcores)
-		vid, err := strconv.Atoi(fields[5])
-		if err != nil {
-			log.Fatalln("DecodeContainerDevices invalid parameter:", str)
-			return types.ContainerDevices{}
-		}
-		tmpdev.Vid = int32(vid)
-		contdev = append(contdev, tmpdev)
-	}
-	return contdev
+	return pd[0]
 }
 
-// DecodePodDevices decode xpu resource request of a pod from string
+// DecodePodDevices decode xpu resource request of a pod from string, the legacy format is accepted as well
 func DecodePodDevices(str string) types.PodDevices {
-	if len(str) == 0 {
+	containers, err := devicecodec.DecodePodDevices(str)
+	if err != nil {
+		log.Errorf("DecodePodDevices invalid parameter: %v", err)
 		return types.PodDevices{}
 	}
-	var pd types.PodDevices
-	for _, s := range strings.Split(str, ";") {
-		cd := DecodeContainerDevices(s)
+	pd := make(types.PodDevices, 0, len(containers))
+	for _, devices := range containers {
+		cd := types.ContainerDevices{}
+		for _, val := range devices {
+			cd = append(cd, types.ContainerDevice{Index: int32(val.Index), UUID: val.UUID, Type: val.Type,
+				Usedmem: int32(val.UsedMemory), Usedcores: int32(val.UsedCores), Vid: int32(val.Vid), Qos: val.Qos})
+		}
 		pd = append(pd, cd)
 	}
 	return pd
 }
 
+// GetContainerDevices get the xpu devices assigned to each vxpu container of the pod, keyed by container name
+func GetContainerDevices(p *v1.Pod) map[string]types.ContainerDevices {
+	res := make(map[string]types.ContainerDevices)
+	for vxpuIdx, cd := range DecodePodDevices(p.Annotations[xpu.AssignedIDs]) {
+		idx := getContainerIdxByVxpuIdx(p, vxpuIdx)
+		if idx == -1 || len(cd) == 0 {
+			continue
+		}
+		res[p.Spec.Containers[idx].Name] = cd
+	}
+	return res
+}
+
+// IsResized check whether the vxpu limits of the pod were resized in place, the assigned devices
+// then hold the limits of the containers instead of the resource limits
+func IsResized(p *v1.Pod) bool {
+	status := p.Annotations[types.VXPUResizeStatus]
+	return status == types.VXPUResizeAccepted || status == types.VXPUResizeApplied
+}
+
+// ListRunningPods list the running pods on the current node
+func ListRunningPods() (*v1.PodList, error) {
+	selector := fields.SelectorFromSet(fields.Set{
+		"spec.nodeName": config.NodeName,
+		"status.phase":  string(v1.PodRunning),
+	})
+	return ListPods(metav1.ListOptions{
+		FieldSelector: selector.String(),
+	})
+}
+
 func getContainerIdxByVxpuIdx(p *v1.Pod, vxpuIdx int) int {
 	foundVxpuIdx := -1
 	for i, container := range p.Spec.Containers {
@@ -291,6 +265,9 @@ func getContainerIdxByVxpuIdx(p *v1.Pod, vxpuIdx int) int {
 			continue
 		}
 		_, ok = container.Resources.Limits[xpu.VxpuMemory]
+		if !ok {
+			_, ok = container.Resources.Limits[xpu.VxpuMemoryMiB]
+		}
 		if ok {
 			foundVxpuIdx++
 			if foundVxpuIdx == vxpuIdx {
@@ -302,8 +279,8 @@ func getContainerIdxByVxpuIdx(p *v1.Pod, vxpuIdx int) int {
 	return -1
 }
 
-// Get xvpu limit info of the container
-func getVxpuLimit(resourceList v1.ResourceList) (int64, int64, int64) {
+// Get xvpu limit info of the container, the memory is returned in MiB
+func getVxpuLimit(resourceList v1.ResourceList) (int64, int64, int64, error) {
 	var number int64 = 0
 	var core int64 = 0
 	var mem int64 = 0
@@ -314,10 +291,17 @@ func getVxpuLimit(resourceList v1.ResourceList) (int64, int64, int64) {
 	if vxpuCore, ok := resourceList[xpu.VxpuCore]; ok {
 		core = vxpuCore.Value()
 	}
-	if vxpuMem, ok := resourceList[xpu.VxpuMemory]; ok {
-		mem = vxpuMem.Value()
-	}
-	return number, core, mem
+	vxpuMem, hasGiB := resourceList[xpu.VxpuMemory]
+	vxpuMemMiB, hasMiB := resourceList[xpu.VxpuMemoryMiB]
+	switch {
+	case hasGiB && hasMiB:
+		return 0, 0, 0, fmt.Errorf("both %s and %s are set", xpu.VxpuMemory, xpu.VxpuMemoryMiB)
+	case hasGiB:
+		mem = vxpuMem.Value() * 1024
+	case hasMiB:
+		mem = vxpuMemMiB.Value()
+	}
+	return number, core, mem, nil
 }
 
 // GetNextDeviceRequest get next xpu resource request of container in a pod
@@ -471,12 +455,18 @@ func PatchPodAnnotations(pod *v1.Pod, annotations map[string]string) error {
 	return err
 }
 
-// GetXpus description get xpu info on the node
+// GetXpus description get xpu info on the node, from its XPUNode object or else its annotation
 func GetXPUs() (map[string]*types.XPUDevice, error) {
 	node, err := GetNode(config.NodeName)
 	if err != nil {
 		return nil, err
 	}
+	ip := getNodeIp(node)
+	if xpuNode, err := GetXPUNode(config.NodeName, xpu.DeviceType); err == nil {
+		deviceMap := newXPUDeviceMap(xpuNode.Status.Devices)
+		setXPUDevicesNodeInfo(deviceMap, ip, xpuNode.Status.DriverVersion, xpuNode.Status.CudaVersion)
+		return deviceMap, nil
+	}
 	annos, ok := node.ObjectMeta.Annotations[xpu.NodeVXPURegister]
 	if !ok {
 		errMsg := fmt.Sprintf("node %s annotation %s is not exists",
@@ -484,7 +474,6 @@ func GetXPUs() (map[string]*types.XPUDevice, error) {
 		log.Errorf(errMsg)
 		return nil, errors.New(errMsg)
 	}
-	ip := getNodeIp(node)
 	return GetXPUDevice(annos, ip), nil
 }
 
@@ -500,13 +489,7 @@ func getNodeIp(node *v1.Node) string {
 
 // GetVgpus get all the xpu device info of the node
 func GetVxpus() (types.VxpuDevices, map[string][]uint32, error) {
-	selector := fields.SelectorFromSet(fields.Set{
-		"spec.nodeName": config.NodeName,
-		"status.phase":  string(v1.PodRunning),
-	})
-	podList, err := ListPods(metav1.ListOptions{
-		FieldSelector: selector.String(),
-	})
+	podList, err := ListRunningPods()
 	if err != nil {
 		log.Errorf("get pods in current node error: %v", err)
 		return nil, nil, err
@@ -524,7 +507,11 @@ func GetVxpus() (types.VxpuDevices, map[string][]uint32, error) {
 		pdevices := DecodePodDevices(pod.Annotations[xpu.AssignedIDs])
 		pi := 0
 		for _, cs := range pod.Spec.Containers {
-			number, core, mem := getVxpuLimit(cs.Resources.Limits)
+			number, core, mem, err := getVxpuLimit(cs.Resources.Limits)
+			if err != nil {
+				log.Warningf("vxpu limit error, pod uid: %v, container name: %s: %v", pod.UID, cs.Name, err)
+				continue
+			}
 			// If the container has not configured vxpu number
 			// it means that the container has no vxpu.
 			if number == 0 {
@@ -540,13 +527,16 @@ func GetVxpus() (types.VxpuDevices, map[string][]uint32, error) {
 				log.Warningf("vxpu assigned info error, pod uid: %v, container name: %s", pod.UID, cs.Name)
 				continue
 			}
+			if IsResized(&pod) {
+				core, mem = int64(pdevices[pi][0].Usedcores), int64(pdevices[pi][0].Usedmem)
+			}
 			for i := 0; i < int(number); i++ {
 				dev := types.VxpuDevice{
 					Id:              fmt.Sprintf("%s-%d", pdevices[pi][i].UUID, pdevices[pi][i].Vid),
 					GpuId:           pdevices[pi][i].UUID,
 					PodUID:          string(pod.UID),
 					ContainerName:   cs.Name,
-					VxpuMemoryLimit: mem * 1024,
+					VxpuMemoryLimit: mem,
 					VxpuCoreLimit:   core,
 				}
 				res = append(res, dev)
@@ -0,0 +1,114 @@
+/*
+ * Copyright (c) Huawei Technologies Co., Ltd. 2024-2025. All rights reserved.
+ */
+
+// Package util implements util function for device plugin
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+
+	"huawei.com/vxpu-device-plugin/pkg/devicecodec"
+	"huawei.com/vxpu-device-plugin/pkg/lock"
+	"huawei.com/vxpu-device-plugin/pkg/log"
+)
+
+var xpuNodeResource = schema.GroupVersionResource{
+	Group:    devicecodec.XPUNodeGroup,
+	Version:  devicecodec.XPUNodeVersion,
+	Resource: devicecodec.XPUNodeResource,
+}
+
+// GetXPUNode get the XPUNode object of the node and device type
+func GetXPUNode(nodeName string, deviceType string) (*devicecodec.XPUNode, error) {
+	if lock.GetDynamicClient() == nil {
+		return nil, errors.New("dynamic client is not initialized")
+	}
+	obj, err := lock.GetDynamicClient().Resource(xpuNodeResource).Get(context.Background(),
+		devicecodec.XPUNodeName(nodeName, deviceType), metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data, err := obj.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	xpuNode := &devicecodec.XPUNode{}
+	if err = json.Unmarshal(data, xpuNode); err != nil {
+		return nil, err
+	}
+	return xpuNode, nil
+}
+
+// UpdateXPUNodeStatus report the status of the XPUNode object of the node and device type,
+// the object is created first if it does not exist, owned by the node to go away with it
+func UpdateXPUNodeStatus(nodeName string, deviceType string, status devicecodec.XPUNodeStatus) error {
+	if lock.GetDynamicClient() == nil {
+		return errors.New("dynamic client is not initialized")
+	}
+	client := lock.GetDynamicClient().Resource(xpuNodeResource)
+	name := devicecodec.XPUNodeName(nodeName, deviceType)
+	_, err := client.Get(context.Background(), name, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		err = createXPUNode(nodeName, deviceType)
+	}
+	if err != nil {
+		log.Errorf("get XPUNode %s failed, %v", name, err)
+		return err
+	}
+	type patchStatus struct {
+		Status devicecodec.XPUNodeStatus `json:"status"`
+	}
+	bytes, err := json.Marshal(patchStatus{Status: status})
+	if err != nil {
+		return err
+	}
+	_, err = client.Patch(context.Background(), name, k8stypes.MergePatchType, bytes, metav1.PatchOptions{}, "status")
+	if err != nil {
+		log.Errorf("patch XPUNode %s status failed, %v", name, err)
+	}
+	return err
+}
+
+func createXPUNode(nodeName string, deviceType string) error {
+	node, err := GetNode(nodeName)
+	if err != nil {
+		return err
+	}
+	xpuNode := &unstructured.Unstructured{}
+	xpuNode.SetAPIVersion(xpuNodeResource.GroupVersion().String())
+	xpuNode.SetKind(devicecodec.XPUNodeKind)
+	xpuNode.SetName(devicecodec.XPUNodeName(nodeName, deviceType))
+	xpuNode.SetOwnerReferences([]metav1.OwnerReference{{
+		APIVersion: "v1",
+		Kind:       "Node",
+		Name:       node.Name,
+		UID:        node.UID,
+	}})
+	spec, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&devicecodec.XPUNodeSpec{
+		NodeName:   nodeName,
+		DeviceType: deviceType,
+	})
+	if err != nil {
+		return err
+	}
+	if err = unstructured.SetNestedMap(xpuNode.Object, spec, "spec"); err != nil {
+		return err
+	}
+	_, err = lock.GetDynamicClient().Resource(xpuNodeResource).Create(context.Background(), xpuNode,
+		metav1.CreateOptions{})
+	if err != nil && !k8serrors.IsAlreadyExists(err) {
+		return err
+	}
+	log.Infof("XPUNode %s created", xpuNode.GetName())
+	return nil
+}
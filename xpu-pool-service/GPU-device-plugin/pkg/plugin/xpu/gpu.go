@@ -34,7 +34,9 @@ const (
 	// VxpuCore vxpu core resource name
 	VxpuCore = "huawei.com/vgpu-cores"
 	// VxpuMemory vxpu memory resource name
-	VxpuMemory                      = "huawei.com/vgpu-memory.1Gi"
+	VxpuMemory = "huawei.com/vgpu-memory.1Gi"
+	// VxpuMemoryMiB vxpu memory resource name in MiB, a container sets either it or VxpuMemory
+	VxpuMemoryMiB                   = "huawei.com/vgpu-memory.1Mi"
 	microSecond                     = 1000 * 1000
 	milliwatts                      = 1000
 	eventWaitTimeout                = 5000
@@ -195,15 +197,17 @@ func GetDeviceInfo(devs []*Device) []*types.DeviceInfo {
 			log.Warningf("get numa information for device %d failed: %s", dev.LogicID, err)
 		}
 		registeredMem := int32(memInfo.Total / 1024 / 1024)
+		deviceType := fmt.Sprintf("%v-%v", DeviceType, resolveDeviceName(name))
 		log.Infof("nvml registered deviceId", dev.ID, "memory", registeredMem, "name", name)
 		res = append(res, &types.DeviceInfo{
-			Index:  dev.LogicID,
-			Id:     dev.ID,
-			Count:  int32(config.DeviceSplitCount),
-			Devmem: registeredMem,
-			Type:   fmt.Sprintf("%v-%v", DeviceType, resolveDeviceName(name)),
-			Health: dev.Health == v1beta1.Healthy,
-			Numa:   int32(numa),
+			Index:       dev.LogicID,
+			Id:          dev.ID,
+			Count:       int32(config.DeviceSplitCount),
+			Devmem:      registeredMem,
+			Type:        deviceType,
+			Health:      dev.Health == v1beta1.Healthy,
+			Numa:        int32(numa),
+			MemoryRatio: MemoryOversubscriptionRatio(deviceType),
 		})
 	}
 	return res
@@ -235,6 +239,36 @@ func resolveDeviceName(deviceName string) string {
 	return strings.ReplaceAll(deviceName, " ", "")
 }
 
+// MemoryOversubscriptionRatio the memory oversubscription ratio of the devices of the registered type,
+// the ratio of the type if it is configured, otherwise the ratio of the node. Ratios below 1 are read as 1
+func MemoryOversubscriptionRatio(deviceType string) float64 {
+	ratio := config.MemoryOversubscriptionRatio
+	if typeRatio, ok := config.MemoryOversubscriptionMap[deviceType]; ok {
+		ratio = typeRatio
+	}
+	if ratio < 1 {
+		log.Warningf("invalid memory oversubscription ratio %v of device type %s, not oversubscribed",
+			ratio, deviceType)
+		return 1
+	}
+	return ratio
+}
+
+// DeviceMemoryOversubscriptionRatio the memory oversubscription ratio of the device of the uuid
+func DeviceMemoryOversubscriptionRatio(uuid string) float64 {
+	ndev, ret := gonvml.DeviceGetHandleByUUID(uuid)
+	if ret != gonvml.Success {
+		log.Warningf("get device handle of %s failed, use the memory oversubscription ratio of the node", uuid)
+		return MemoryOversubscriptionRatio("")
+	}
+	name, ret := ndev.GetName()
+	if ret != gonvml.Success {
+		log.Warningf("get name of %s failed, use the memory oversubscription ratio of the node", uuid)
+		return MemoryOversubscriptionRatio("")
+	}
+	return MemoryOversubscriptionRatio(fmt.Sprintf("%v-%v", DeviceType, resolveDeviceName(name)))
+}
+
 // GetVisibleDevices get visible devices for container env
 func GetVisibleDevices(devReq types.ContainerDevices) string {
 	visibleDevices := make([]string, 0)
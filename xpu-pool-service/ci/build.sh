@@ -35,6 +35,15 @@ function strip_gotest_codes() {
     go mod tidy
 }
 
+function check_devicecodec() {
+    # the scheduler plugin keeps a copy of the device codec, the two copies must not drift
+    local scheduler_codec="${WORK_DIR}/../../pod-scheduler-service/xpu-scheduler-plugin/devicecodec"
+    if [ ! -d "${scheduler_codec}" ]; then
+        return
+    fi
+    diff -r -x '*_test.go' "${WORK_DIR}/../GPU-device-plugin/pkg/devicecodec" "${scheduler_codec}"
+}
+
 function compile_device_plugin() {
     # strip gomoney related codes to make SwInfoTree happy
     strip_gotest_codes "${WORK_DIR}/../GPU-device-plugin/"
@@ -92,6 +101,7 @@ function main() {
     prepare
     handle_spdlog
     compile_client
+    check_devicecodec
     compile_device_plugin
     compile_xpu_exporter
     strip_symbols
@@ -4,17 +4,30 @@
 #ifndef RESOURCE_CONFIG_H
 #define RESOURCE_CONFIG_H
 
+#include <atomic>
 #include <cstddef>
+#include <filesystem>
 #include <string>
 #include "common.h"
 #include "xpu_manager.h"
 
+// QosClass how the computing power quota of the container is applied. A guaranteed container
+// never goes beyond its quota, a burstable one may use the computing power the others leave idle,
+// a best-effort one has no quota
+enum class QosClass {
+    GUARANTEED,
+    BURSTABLE,
+    BEST_EFFORT,
+};
+
 class ResourceConfig {
 public:
     ResourceConfig(XpuManager &xpu) : xpu_(xpu)
     {}
     int Initialize();
     int LoadVxpuConfig();
+    // load the vxpu config again when the device plugin rewrote it to resize the container in place
+    int Reload();
 
     size_t MemoryQuota() const
     {
@@ -36,15 +49,31 @@ public:
         return computingPower_;
     }
 
+    // the memory of the devices is oversubscribed, MemoryQuota may not be backed by physical memory
+    bool MemoryOversubscribed() const
+    {
+        return memoryRatio_ > PERCENT_MAX;
+    }
+
+    QosClass Qos() const
+    {
+        return qos_;
+    }
+
 TESTABLE_PRIVATE:
     int ParseLineByConfigName(const std::string& line, const std::string& configName,
         unsigned long& value, unsigned int maxValue);
+    int ParseQosClass(const std::string& line);
 
     XpuManager &xpu_;
-    size_t memory_ = 0;         // Bytes
-    unsigned int computingPower_ = 0; // %
-    bool limitMemory_ = false;
-    bool limitComputingPower_ = false;
+    // the limits are read by the hooks while the watcher thread reloads them
+    std::atomic<size_t> memory_{0};         // Bytes
+    std::atomic<unsigned int> computingPower_{0}; // %
+    std::atomic<unsigned int> memoryRatio_{PERCENT_MAX}; // %
+    std::atomic<QosClass> qos_{QosClass::GUARANTEED};
+    std::atomic<bool> limitMemory_{false};
+    std::atomic<bool> limitComputingPower_{false};
+    std::filesystem::file_time_type lastModified_;
 };
 
 #endif
\ No newline at end of file
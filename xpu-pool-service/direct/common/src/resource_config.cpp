@@ -17,6 +17,20 @@ int ResourceConfig::Initialize()
         log_debug("{} no exist, client is running in host", xpu_.ConfigPath());
         return RET_SUCC;
     }
+    error_code ec;
+    lastModified_ = filesystem::last_write_time(xpu_.ConfigPath(), ec);
+    return LoadVxpuConfig();
+}
+
+int ResourceConfig::Reload()
+{
+    error_code ec;
+    auto modified = filesystem::last_write_time(xpu_.ConfigPath(), ec);
+    if (ec || modified == lastModified_) {
+        return RET_SUCC;
+    }
+    lastModified_ = modified;
+    log_info("{} modified, reload it", xpu_.ConfigPath());
     return LoadVxpuConfig();
 }
 
@@ -40,10 +54,31 @@ int ResourceConfig::ParseLineByConfigName(const string& line, const string& conf
     return RET_SUCC;
 }
 
+int ResourceConfig::ParseQosClass(const string& line)
+{
+    const string configName = "QosClass";
+    string valueStr = line.substr(configName.size() + 1);  // configName:
+    if (valueStr == "guaranteed") {
+        qos_ = QosClass::GUARANTEED;
+    } else if (valueStr == "burstable") {
+        qos_ = QosClass::BURSTABLE;
+    } else if (valueStr == "best-effort") {
+        qos_ = QosClass::BEST_EFFORT;
+    } else {
+        log_err("parse {} failed, unknown class {}", configName, valueStr);
+        return RET_FAIL;
+    }
+    return RET_SUCC;
+}
+
 /*
 * Format in vgpu config:
 * UsedMem:xxx
 * UsedCores:yyy
+* MemRatio:zzz
+* QosClass:guaranteed|burstable|best-effort
+* MemRatio is the memory oversubscription ratio of the devices in percent and QosClass the qos
+* class of the container, they are optional and written by newer device plugins only
 */
 int ResourceConfig::LoadVxpuConfig()
 {
@@ -82,8 +117,25 @@ int ResourceConfig::LoadVxpuConfig()
     // if computingPower is 0, don't limit computingPower
     limitComputingPower_ = (computingPower_ != 0);
 
+    unsigned long memoryRatioValue;
+    while (getline(file, line)) {
+        if (line.rfind("MemRatio", 0) == 0) {
+            ret = ParseLineByConfigName(line, "MemRatio", memoryRatioValue, UINT_MAX);
+            if (ret) {
+                return ret;
+            }
+            memoryRatio_ = static_cast<unsigned int>(memoryRatioValue);
+        } else if (line.rfind("QosClass", 0) == 0) {
+            ret = ParseQosClass(line);
+            if (ret) {
+                return ret;
+            }
+        }
+    }
+
     log_info("parse {} over, the configs are as follows: ", xpu_.ConfigPath());
-    log_info("limitMemory {}, limitComputingPower {}, memory {}, computingPower {}",
-        limitMemory_, limitComputingPower_, memory_, computingPower_);
+    log_info("limitMemory {}, limitComputingPower {}, memory {}, computingPower {}, memoryRatio {}, qos {}",
+        limitMemory_.load(), limitComputingPower_.load(), memory_.load(), computingPower_.load(),
+        memoryRatio_.load(), static_cast<int>(qos_.load()));
     return RET_SUCC;
 }
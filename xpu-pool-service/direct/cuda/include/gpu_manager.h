@@ -16,6 +16,8 @@ public:
   {}
   int InitXpu() override;
   int ComputingPowerUsed(int idx, unsigned int &used);
+  // deviceUsed is the computing power used by all the processes on the device, the container's included
+  int ComputingPowerUsed(int idx, unsigned int &used, unsigned int &deviceUsed);
   int MemoryUsed(size_t &used) override;
   int CurrentDevice() override;
   int DeviceCount() override {
@@ -1,4 +1,5 @@
 #include "gpu_core_limiter.h"
+#include <algorithm>
 #include "log.h"
 
 using namespace xpu;
@@ -52,7 +53,8 @@ long GpuCoreLimiter::PidController::CalculateDelay(int diff)
 int GpuCoreLimiter::UpdateDelay(int idx)
 {
   unsigned int used;
-  int ret = gpu_.ComputingPowerUsed(idx, used);
+  unsigned int deviceUsed;
+  int ret = gpu_.ComputingPowerUsed(idx, used, deviceUsed);
   if (ret != RET_SUCC) {
     return ret;
   }
@@ -60,6 +62,11 @@ int GpuCoreLimiter::UpdateDelay(int idx)
   long tmpDelay;
   int diff = 0;
   unsigned int upLimit = config_.ComputingPowerQuota();
+  // a burstable container may use the computing power the other processes leave idle beyond its quota
+  if (config_.Qos() == QosClass::BURSTABLE) {
+    unsigned int othersUsed = deviceUsed > used ? deviceUsed - used : 0;
+    upLimit = std::max(upLimit, PERCENT_MAX - othersUsed);
+  }
   diff = used - upLimit;
   tmpDelay = pidController_.CalculateDelay(diff) + GetDelay(idx);
   if (tmpDelay < 0) {
@@ -75,6 +82,8 @@ void GpuCoreLimiter::ComputingPowerWatcherThread()
 {
   while (!watcherEnd_) {
     std::this_thread::sleep_for(UPDATE_PERIOD);
+    // pick up the limits of an in-place resize
+    config_.Reload();
 
     if (!config_.LimitComputingPower()) {
       continue;
@@ -96,7 +105,8 @@ void GpuCoreLimiter::ComputingPowerWatcherThread()
 
 int GpuCoreLimiter::ComputingPowerWatcherInit()
 {
-  if (!config_.LimitComputingPower()) {
+  // the watcher also reloads the memory limit of a resized container
+  if (!config_.LimitComputingPower() && !config_.LimitMemory()) {
     return RET_SUCC;
   }
   if (watcher_.joinable()) {
@@ -105,6 +105,11 @@ int GpuManager::MemoryUsed(size_t& used) {
 }
 
 int GpuManager::ComputingPowerUsed(int idx, unsigned int& used) {
+  unsigned int deviceUsed;
+  return ComputingPowerUsed(idx, used, deviceUsed);
+}
+
+int GpuManager::ComputingPowerUsed(int idx, unsigned int& used, unsigned int& deviceUsed) {
   nvmlDevice_t dev = GetNvmlHandle(idx);
   if (dev == GpuManager::INVALID_NVML_HANDLE) {
     return RET_FAIL;
@@ -136,15 +141,18 @@ int GpuManager::ComputingPowerUsed(int idx, unsigned int& used) {
   }
 
   unsigned int rate = 0;
+  unsigned int deviceRate = 0;
   for (unsigned int i = 0; i < procNum; i++) {
     if (procSample[i].timeStamp < checkTime) {
       return RET_FAIL;
     }
+    deviceRate += procSample[i].smUtil;
     int containerPid = pid_.GetContainerPid(static_cast<int>(procSample[i].pid));
     if (containerPid != pid_.INVALID_PID) {
       rate += procSample[i].smUtil;
     }
   }
   used = std::clamp(rate, PERCENT_MIN, PERCENT_MAX);
+  deviceUsed = std::clamp(deviceRate, PERCENT_MIN, PERCENT_MAX);
   return RET_SUCC;
 }
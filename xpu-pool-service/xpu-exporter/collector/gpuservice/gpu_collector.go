@@ -56,6 +56,8 @@ var (
 		"number of gpus", nodeLabel, nil)
 	xpuGpuMemoryDesc = prometheus.NewDesc("xpu_gpu_mem",
 		"memory size of gpu", gpuLabel, nil)
+	xpuGpuMemoryOversubscribedDesc = prometheus.NewDesc("xpu_gpu_mem_oversubscribed",
+		"memory size of gpu the vgpus may be granted with oversubscription", gpuLabel, nil)
 	xpuGpuPowerUsageDesc = prometheus.NewDesc("xpu_gpu_power_usage",
 		"power usage of gpu, the unit is milliwatts", gpuLabel, nil)
 	xpuGpuTemperatureDesc = prometheus.NewDesc("xpu_gpu_temperature",
@@ -70,8 +72,9 @@ var (
 		"real time quantity of vgpu pods", []string{nodeName, nodeIp, gpuUUid}, nil)
 
 	descriptions = []*prometheus.Desc{versionInfoDesc, xpuGpuUtilizationDesc, xpuGpuMemoryUtilizationDesc,
-		xpuGpuStatusDesc, xpuGpuNumberDesc, xpuGpuMemoryDesc, xpuGpuPowerUsageDesc, xpuGpuTemperatureDesc,
-		xpuVgpuUtilizationDesc, xpuVgpuMemoryUtilizationDesc, xpuVgpuNumberDesc, xpuVgpuPodNumberDesc}
+		xpuGpuStatusDesc, xpuGpuNumberDesc, xpuGpuMemoryDesc, xpuGpuMemoryOversubscribedDesc, xpuGpuPowerUsageDesc,
+		xpuGpuTemperatureDesc, xpuVgpuUtilizationDesc, xpuVgpuMemoryUtilizationDesc, xpuVgpuNumberDesc,
+		xpuVgpuPodNumberDesc}
 )
 
 const (
@@ -177,6 +180,14 @@ func updateGpuDeviceInfo(ch chan<- prometheus.Metric, gpu *utils.XPUDevice) {
 	ch <- prometheus.MustNewConstMetric(xpuGpuMemoryDesc, prometheus.GaugeValue,
 		float64(gpu.MemoryTotal), []string{gpu.Id, gpu.NodeName, gpu.NodeIp, strconv.Itoa(int(gpu.Index)),
 			gpu.Type, gpu.DriverVersion, strconv.Itoa(gpu.FrameworkVersion)}...)
+	// device plugins not reporting the oversubscription grant the physical memory only
+	memoryOversubscribed := gpu.MemoryOversubscribed
+	if memoryOversubscribed == 0 {
+		memoryOversubscribed = gpu.MemoryTotal
+	}
+	ch <- prometheus.MustNewConstMetric(xpuGpuMemoryOversubscribedDesc, prometheus.GaugeValue,
+		float64(memoryOversubscribed), []string{gpu.Id, gpu.NodeName, gpu.NodeIp, strconv.Itoa(int(gpu.Index)),
+			gpu.Type, gpu.DriverVersion, strconv.Itoa(gpu.FrameworkVersion)}...)
 	ch <- prometheus.MustNewConstMetric(xpuGpuPowerUsageDesc, prometheus.GaugeValue,
 		float64(gpu.PowerUsage), []string{gpu.Id, gpu.NodeName, gpu.NodeIp, strconv.Itoa(int(gpu.Index)),
 			gpu.Type, gpu.DriverVersion, strconv.Itoa(gpu.FrameworkVersion)}...)
@@ -13,6 +13,7 @@ type XPUDevice struct {
 	Health        bool
 	Count         uint32
 	MemoryTotal   uint64
+	MemoryOversubscribed uint64
 	MemoryUsed    uint64
 	MemoryUtilization float64
 	XpuUtilization float64